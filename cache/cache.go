@@ -3,6 +3,7 @@ package cache
 import (
 	"context"
 	"errors"
+	"fmt"
 	"time"
 )
 
@@ -16,6 +17,23 @@ var (
 	ErrConnectionFailed = errors.New("failed to connect to cache")
 	// ErrInvalidValue is returned when value is invalid or corrupted
 	ErrInvalidValue = errors.New("invalid cache value")
+	// ErrInvalidConfig is returned when the cache configuration is inconsistent
+	ErrInvalidConfig = errors.New("invalid cache configuration")
+	// ErrTxFailed is returned when a watched key changed before EXEC
+	ErrTxFailed = errors.New("cache transaction failed, watched key changed")
+	// ErrTimeout is returned when the cache did not answer in time, it also
+	// matches ErrConnectionFailed
+	ErrTimeout = errors.New("cache operation timed out")
+	// ErrScript is returned when a script fails to load or run
+	ErrScript = errors.New("cache script failed")
+	// ErrWrongType is returned when a key holds another kind of value, it
+	// also matches ErrInvalidValue
+	ErrWrongType = errors.New("cache key holds the wrong kind of value")
+	// ErrCanceled is returned when the caller cancelled the context
+	ErrCanceled = errors.New("cache operation canceled")
+	// ErrUnsupported is returned when the client lacks an optional
+	// capability such as KeyScanner
+	ErrUnsupported = errors.New("cache operation not supported by client")
 )
 
 type (
@@ -32,6 +50,22 @@ type (
 		Err error
 	}
 
+	TimeoutError struct {
+		Err error
+	}
+
+	ScriptError struct {
+		Err error
+	}
+
+	WrongTypeError struct {
+		Key string
+	}
+
+	CanceledError struct {
+		Err error
+	}
+
 	PipelineCmd interface {
 		Val() int64
 		Err() error
@@ -42,28 +76,145 @@ type (
 		Err() error
 	}
 
+	PipelineStringCmd interface {
+		Val() string
+		Err() error
+	}
+
+	PipelineBoolCmd interface {
+		Val() bool
+		Err() error
+	}
+
+	PipelineMapCmd interface {
+		Val() map[string]string
+		Err() error
+	}
+
+	PipelineStatusCmd interface {
+		Err() error
+	}
+
+	// Pipeline queues commands and sends them in one round trip on Exec.
+	// Results are only available after Exec returns.
 	Pipeline interface {
+		Get(ctx context.Context, key string) PipelineStringCmd
+		Set(ctx context.Context, key string, value interface{}, expiration time.Duration) PipelineStatusCmd
+		Del(ctx context.Context, keys ...string) PipelineCmd
 		Incr(ctx context.Context, key string) PipelineCmd
 		TTL(ctx context.Context, key string) PipelineDurationCmd
+		Expire(ctx context.Context, key string, expiration time.Duration) PipelineBoolCmd
+		HGet(ctx context.Context, key, field string) PipelineStringCmd
+		HGetAll(ctx context.Context, key string) PipelineMapCmd
+		HSet(ctx context.Context, key string, values map[string]interface{}) PipelineCmd
+		HDel(ctx context.Context, key string, fields ...string) PipelineCmd
+		HIncrBy(ctx context.Context, key, field string, incr int64) PipelineCmd
+		// Len returns the number of queued commands
+		Len() int
+		// Exec sends the queued commands. A missing key in Get or HGet is
+		// not an Exec error, it is reported by the command's own Err.
 		Exec(ctx context.Context) error
+		Discard()
+	}
+
+	// Tx reads watched keys and commits writes with MULTI/EXEC
+	Tx interface {
+		Get(ctx context.Context, key string) (string, error)
+		HGetAll(ctx context.Context, key string) (map[string]string, error)
+		TTL(ctx context.Context, key string) (time.Duration, error)
+		// TxPipelined queues the commands added in fn and executes them
+		// atomically. It fails with ErrTxFailed if a watched key changed.
+		// fn must not call Exec.
+		TxPipelined(ctx context.Context, fn func(pipe Pipeline) error) error
+	}
+
+	TxFunc func(tx Tx) error
+
+	// ScriptFunc is the Go equivalent of a Lua script, for clients that
+	// cannot run Lua. It must only touch the cache through c.
+	ScriptFunc func(ctx context.Context, c Client, keys []string, args []interface{}) (interface{}, error)
+
+	// ScriptRegistrar is implemented by clients that emulate Eval, such as
+	// the in-memory client. Callers owning a script register its Go
+	// equivalent when the client supports it.
+	ScriptRegistrar interface {
+		RegisterScript(script string, fn ScriptFunc)
+	}
+
+	// KeyScanner is implemented by clients that can list keys. Scanning
+	// walks the whole keyspace, keep it to admin tools.
+	KeyScanner interface {
+		// ScanKeys returns every key matching a glob pattern such as
+		// KeyFamily.Pattern, in no particular order
+		ScanKeys(ctx context.Context, pattern string) ([]string, error)
+	}
+
+	// ClusterReporter is implemented by clients that can run against a
+	// cluster, where commands and scripts must keep to one hash slot
+	ClusterReporter interface {
+		IsCluster() bool
+	}
+
+	// ZMember is a sorted set member with its score
+	ZMember struct {
+		Member string
+		Score  float64
+	}
+
+	// ZRangeBy bounds a score range query. Min and Max accept the redis
+	// syntax, e.g. "-inf", "+inf" or "(10" for an exclusive bound.
+	// Count <= 0 returns every match.
+	ZRangeBy struct {
+		Min    string
+		Max    string
+		Offset int64
+		Count  int64
 	}
 
 	Client interface {
 		Get(ctx context.Context, key string) (string, error)
 		Set(ctx context.Context, key string, value interface{}, expiration time.Duration) error
+		// SetNX sets the key only when it does not exist yet and reports whether it was set
+		SetNX(ctx context.Context, key string, value interface{}, expiration time.Duration) (bool, error)
+		// Del returns the number of keys removed
+		Del(ctx context.Context, keys ...string) (int64, error)
+		// Exists returns how many of the given keys exist
+		Exists(ctx context.Context, keys ...string) (int64, error)
+		// MGet returns the found keys only, missing keys are left out of the map
+		MGet(ctx context.Context, keys ...string) (map[string]string, error)
+		MSet(ctx context.Context, values map[string]interface{}, expiration time.Duration) error
 		Incr(ctx context.Context, key string) (int64, error)
 		TTL(ctx context.Context, key string) (time.Duration, error)
 		Expire(ctx context.Context, key string, expiration time.Duration) error
+
+		HGet(ctx context.Context, key, field string) (string, error)
+		HGetAll(ctx context.Context, key string) (map[string]string, error)
+		// HSet returns the number of fields added, updated fields are not counted
+		HSet(ctx context.Context, key string, values map[string]interface{}) (int64, error)
+		HDel(ctx context.Context, key string, fields ...string) (int64, error)
+		HIncrBy(ctx context.Context, key, field string, incr int64) (int64, error)
+
+		SAdd(ctx context.Context, key string, members ...string) (int64, error)
+		SRem(ctx context.Context, key string, members ...string) (int64, error)
+		SMembers(ctx context.Context, key string) ([]string, error)
+		SCard(ctx context.Context, key string) (int64, error)
+
+		ZAdd(ctx context.Context, key string, members ...ZMember) (int64, error)
+		ZRem(ctx context.Context, key string, members ...string) (int64, error)
+		ZScore(ctx context.Context, key, member string) (float64, error)
+		ZCard(ctx context.Context, key string) (int64, error)
+		// ZRange returns members by rank, lowest score first
+		ZRange(ctx context.Context, key string, start, stop int64) ([]ZMember, error)
+		ZRangeByScore(ctx context.Context, key string, by ZRangeBy) ([]ZMember, error)
+		ZRemRangeByScore(ctx context.Context, key, min, max string) (int64, error)
+
 		Pipeline() Pipeline
+		// TxPipeline wraps the queued commands in MULTI/EXEC
+		TxPipeline() Pipeline
+		// Watch runs fn with keys watched for optimistic locking
+		Watch(ctx context.Context, fn TxFunc, keys ...string) error
 		Eval(ctx context.Context, script string, keys []string, args []interface{}) (interface{}, error)
 	}
-
-	Config struct {
-		Host     string
-		Port     string
-		Password string
-		DB       int
-	}
 )
 
 func (e *KeyNotFoundError) Error() string {
@@ -96,6 +247,84 @@ func (e *ConnectionError) Is(target error) bool {
 	return target == ErrConnectionFailed
 }
 
+func (e *ConnectionError) Unwrap() error {
+	return e.Err
+}
+
+func (e *TimeoutError) Error() string {
+	if e.Err == nil {
+		return ErrTimeout.Error()
+	}
+	return "cache operation timed out: " + e.Err.Error()
+}
+
+func (e *TimeoutError) IsCacheError() bool {
+	return true
+}
+
+// Is matches ErrConnectionFailed too, a slow cache is as unusable as an
+// unreachable one
+func (e *TimeoutError) Is(target error) bool {
+	return target == ErrTimeout || target == ErrConnectionFailed
+}
+
+func (e *TimeoutError) Unwrap() error {
+	return e.Err
+}
+
+func (e *ScriptError) Error() string {
+	if e.Err == nil {
+		return ErrScript.Error()
+	}
+	return "cache script failed: " + e.Err.Error()
+}
+
+func (e *ScriptError) IsCacheError() bool {
+	return true
+}
+
+func (e *ScriptError) Is(target error) bool {
+	return target == ErrScript
+}
+
+func (e *ScriptError) Unwrap() error {
+	return e.Err
+}
+
+func (e *WrongTypeError) Error() string {
+	if e.Key == "" {
+		return ErrWrongType.Error()
+	}
+	return "cache key holds the wrong kind of value: " + e.Key
+}
+
+func (e *WrongTypeError) IsCacheError() bool {
+	return true
+}
+
+func (e *WrongTypeError) Is(target error) bool {
+	return target == ErrWrongType || target == ErrInvalidValue
+}
+
+func (e *CanceledError) Error() string {
+	if e.Err == nil {
+		return ErrCanceled.Error()
+	}
+	return "cache operation canceled: " + e.Err.Error()
+}
+
+func (e *CanceledError) IsCacheError() bool {
+	return true
+}
+
+func (e *CanceledError) Is(target error) bool {
+	return target == ErrCanceled
+}
+
+func (e *CanceledError) Unwrap() error {
+	return e.Err
+}
+
 // Helper functions to check error types
 func IsKeyNotFound(err error) bool {
 	if err == nil {
@@ -111,6 +340,34 @@ func IsConnectionError(err error) bool {
 	return errors.Is(err, ErrConnectionFailed)
 }
 
+func IsTimeout(err error) bool {
+	if err == nil {
+		return false
+	}
+	return errors.Is(err, ErrTimeout)
+}
+
+func IsScriptError(err error) bool {
+	if err == nil {
+		return false
+	}
+	return errors.Is(err, ErrScript)
+}
+
+func IsWrongType(err error) bool {
+	if err == nil {
+		return false
+	}
+	return errors.Is(err, ErrWrongType)
+}
+
+func IsCanceled(err error) bool {
+	if err == nil {
+		return false
+	}
+	return errors.Is(err, ErrCanceled)
+}
+
 // Helper functions to create errors
 func NewKeyNotFoundError(key string) error {
 	return &KeyNotFoundError{Key: key}
@@ -119,3 +376,52 @@ func NewKeyNotFoundError(key string) error {
 func NewConnectionError(err error) error {
 	return &ConnectionError{Err: err}
 }
+
+func NewTimeoutError(err error) error {
+	return &TimeoutError{Err: err}
+}
+
+func NewScriptError(err error) error {
+	return &ScriptError{Err: err}
+}
+
+func NewWrongTypeError(key string) error {
+	return &WrongTypeError{Key: key}
+}
+
+func NewCanceledError(err error) error {
+	return &CanceledError{Err: err}
+}
+
+// Transact runs fn through Watch and retries it while the watched keys
+// keep changing, up to maxRetries extra attempts
+func Transact(ctx context.Context, c Client, maxRetries int, fn TxFunc, keys ...string) error {
+	var err error
+	for attempt := 0; attempt <= maxRetries; attempt += 1 {
+		err = c.Watch(ctx, fn, keys...)
+		if !errors.Is(err, ErrTxFailed) {
+			return err
+		}
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+	}
+	return err
+}
+
+// IsCluster reports whether c talks to a cluster, false for clients that
+// cannot tell
+func IsCluster(c Client) bool {
+	reporter, ok := c.(ClusterReporter)
+	return ok && reporter.IsCluster()
+}
+
+// ScanKeys lists the keys matching pattern, failing with ErrUnsupported
+// when c cannot scan
+func ScanKeys(ctx context.Context, c Client, pattern string) ([]string, error) {
+	scanner, ok := c.(KeyScanner)
+	if !ok {
+		return nil, fmt.Errorf("%w: %T cannot scan keys", ErrUnsupported, c)
+	}
+	return scanner.ScanKeys(ctx, pattern)
+}
@@ -0,0 +1,344 @@
+// Package cachetest provides cache.Client helpers for tests
+package cachetest
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/brianwu291/go-learn/cache"
+)
+
+type (
+	// Faults describes what FaultyClient injects. Operation names are the
+	// lowercase method names, e.g. "get", "eval" or "pipeline".
+	Faults struct {
+		// Latency delays every call, a context that ends first wins
+		Latency time.Duration
+		// ErrorRate is the share of calls, 0 to 1, failing with Err
+		ErrorRate float64
+		// Err defaults to a cache.ConnectionError
+		Err error
+		// Operations always fail the named operations with the given error
+		Operations map[string]error
+		// PipelineErr fails every queued pipeline command from index
+		// PipelineFailAfter on. The commands before it are still applied.
+		PipelineErr       error
+		PipelineFailAfter int
+		// Seed makes ErrorRate reproducible, zero uses 1
+		Seed int64
+	}
+
+	// FaultyClient wraps a cache.Client and injects latency and errors
+	FaultyClient struct {
+		client cache.Client
+
+		mu     sync.Mutex
+		faults Faults
+		rand   *rand.Rand
+		calls  map[string]int
+	}
+)
+
+// ErrInjected is the cause of the default injected ConnectionError
+var ErrInjected = errors.New("injected cache fault")
+
+func NewFaultyClient(client cache.Client, faults Faults) *FaultyClient {
+	c := &FaultyClient{
+		client: client,
+		calls:  make(map[string]int),
+	}
+	c.SetFaults(faults)
+	return c
+}
+
+// SetFaults replaces the injected faults, e.g. to let a cache recover
+func (c *FaultyClient) SetFaults(faults Faults) {
+	if faults.Err == nil {
+		faults.Err = cache.NewConnectionError(ErrInjected)
+	}
+	if faults.Seed == 0 {
+		faults.Seed = 1
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.faults = faults
+	c.rand = rand.New(rand.NewSource(faults.Seed))
+}
+
+// Calls returns how often the operation was called, failed calls included
+func (c *FaultyClient) Calls(operation string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.calls[operation]
+}
+
+// RegisterScript forwards to clients that emulate Eval
+func (c *FaultyClient) RegisterScript(script string, fn cache.ScriptFunc) {
+	if registrar, ok := c.client.(cache.ScriptRegistrar); ok {
+		registrar.RegisterScript(script, fn)
+	}
+}
+
+// IsCluster forwards to the wrapped client
+func (c *FaultyClient) IsCluster() bool {
+	return cache.IsCluster(c.client)
+}
+
+// ScanKeys forwards to clients that can scan
+func (c *FaultyClient) ScanKeys(ctx context.Context, pattern string) ([]string, error) {
+	if err := c.inject(ctx, "scan"); err != nil {
+		return nil, err
+	}
+	return cache.ScanKeys(ctx, c.client, pattern)
+}
+
+// inject counts the call, waits out the latency and picks the error to
+// return, if any
+func (c *FaultyClient) inject(ctx context.Context, operation string) error {
+	c.mu.Lock()
+	c.calls[operation] += 1
+	faults := c.faults
+	err, failOperation := faults.Operations[operation]
+	failRandom := faults.ErrorRate > 0 && c.rand.Float64() < faults.ErrorRate
+	c.mu.Unlock()
+
+	if faults.Latency > 0 {
+		timer := time.NewTimer(faults.Latency)
+		defer timer.Stop()
+
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				return cache.NewTimeoutError(ctx.Err())
+			}
+			return cache.NewCanceledError(ctx.Err())
+		}
+	}
+
+	switch {
+	case failOperation:
+		return err
+	case failRandom:
+		return faults.Err
+	default:
+		return nil
+	}
+}
+
+func (c *FaultyClient) pipelineFaults() (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.faults.PipelineFailAfter, c.faults.PipelineErr
+}
+
+func (c *FaultyClient) Get(ctx context.Context, key string) (string, error) {
+	if err := c.inject(ctx, "get"); err != nil {
+		return "", err
+	}
+	return c.client.Get(ctx, key)
+}
+
+func (c *FaultyClient) Set(ctx context.Context, key string, value interface{}, expiration time.Duration) error {
+	if err := c.inject(ctx, "set"); err != nil {
+		return err
+	}
+	return c.client.Set(ctx, key, value, expiration)
+}
+
+func (c *FaultyClient) SetNX(ctx context.Context, key string, value interface{}, expiration time.Duration) (bool, error) {
+	if err := c.inject(ctx, "setnx"); err != nil {
+		return false, err
+	}
+	return c.client.SetNX(ctx, key, value, expiration)
+}
+
+func (c *FaultyClient) Del(ctx context.Context, keys ...string) (int64, error) {
+	if err := c.inject(ctx, "del"); err != nil {
+		return 0, err
+	}
+	return c.client.Del(ctx, keys...)
+}
+
+func (c *FaultyClient) Exists(ctx context.Context, keys ...string) (int64, error) {
+	if err := c.inject(ctx, "exists"); err != nil {
+		return 0, err
+	}
+	return c.client.Exists(ctx, keys...)
+}
+
+func (c *FaultyClient) MGet(ctx context.Context, keys ...string) (map[string]string, error) {
+	if err := c.inject(ctx, "mget"); err != nil {
+		return nil, err
+	}
+	return c.client.MGet(ctx, keys...)
+}
+
+func (c *FaultyClient) MSet(ctx context.Context, values map[string]interface{}, expiration time.Duration) error {
+	if err := c.inject(ctx, "mset"); err != nil {
+		return err
+	}
+	return c.client.MSet(ctx, values, expiration)
+}
+
+func (c *FaultyClient) Incr(ctx context.Context, key string) (int64, error) {
+	if err := c.inject(ctx, "incr"); err != nil {
+		return 0, err
+	}
+	return c.client.Incr(ctx, key)
+}
+
+func (c *FaultyClient) TTL(ctx context.Context, key string) (time.Duration, error) {
+	if err := c.inject(ctx, "ttl"); err != nil {
+		return 0, err
+	}
+	return c.client.TTL(ctx, key)
+}
+
+func (c *FaultyClient) Expire(ctx context.Context, key string, expiration time.Duration) error {
+	if err := c.inject(ctx, "expire"); err != nil {
+		return err
+	}
+	return c.client.Expire(ctx, key, expiration)
+}
+
+func (c *FaultyClient) HGet(ctx context.Context, key, field string) (string, error) {
+	if err := c.inject(ctx, "hget"); err != nil {
+		return "", err
+	}
+	return c.client.HGet(ctx, key, field)
+}
+
+func (c *FaultyClient) HGetAll(ctx context.Context, key string) (map[string]string, error) {
+	if err := c.inject(ctx, "hgetall"); err != nil {
+		return nil, err
+	}
+	return c.client.HGetAll(ctx, key)
+}
+
+func (c *FaultyClient) HSet(ctx context.Context, key string, values map[string]interface{}) (int64, error) {
+	if err := c.inject(ctx, "hset"); err != nil {
+		return 0, err
+	}
+	return c.client.HSet(ctx, key, values)
+}
+
+func (c *FaultyClient) HDel(ctx context.Context, key string, fields ...string) (int64, error) {
+	if err := c.inject(ctx, "hdel"); err != nil {
+		return 0, err
+	}
+	return c.client.HDel(ctx, key, fields...)
+}
+
+func (c *FaultyClient) HIncrBy(ctx context.Context, key, field string, incr int64) (int64, error) {
+	if err := c.inject(ctx, "hincrby"); err != nil {
+		return 0, err
+	}
+	return c.client.HIncrBy(ctx, key, field, incr)
+}
+
+func (c *FaultyClient) SAdd(ctx context.Context, key string, members ...string) (int64, error) {
+	if err := c.inject(ctx, "sadd"); err != nil {
+		return 0, err
+	}
+	return c.client.SAdd(ctx, key, members...)
+}
+
+func (c *FaultyClient) SRem(ctx context.Context, key string, members ...string) (int64, error) {
+	if err := c.inject(ctx, "srem"); err != nil {
+		return 0, err
+	}
+	return c.client.SRem(ctx, key, members...)
+}
+
+func (c *FaultyClient) SMembers(ctx context.Context, key string) ([]string, error) {
+	if err := c.inject(ctx, "smembers"); err != nil {
+		return nil, err
+	}
+	return c.client.SMembers(ctx, key)
+}
+
+func (c *FaultyClient) SCard(ctx context.Context, key string) (int64, error) {
+	if err := c.inject(ctx, "scard"); err != nil {
+		return 0, err
+	}
+	return c.client.SCard(ctx, key)
+}
+
+func (c *FaultyClient) ZAdd(ctx context.Context, key string, members ...cache.ZMember) (int64, error) {
+	if err := c.inject(ctx, "zadd"); err != nil {
+		return 0, err
+	}
+	return c.client.ZAdd(ctx, key, members...)
+}
+
+func (c *FaultyClient) ZRem(ctx context.Context, key string, members ...string) (int64, error) {
+	if err := c.inject(ctx, "zrem"); err != nil {
+		return 0, err
+	}
+	return c.client.ZRem(ctx, key, members...)
+}
+
+func (c *FaultyClient) ZScore(ctx context.Context, key, member string) (float64, error) {
+	if err := c.inject(ctx, "zscore"); err != nil {
+		return 0, err
+	}
+	return c.client.ZScore(ctx, key, member)
+}
+
+func (c *FaultyClient) ZCard(ctx context.Context, key string) (int64, error) {
+	if err := c.inject(ctx, "zcard"); err != nil {
+		return 0, err
+	}
+	return c.client.ZCard(ctx, key)
+}
+
+func (c *FaultyClient) ZRange(ctx context.Context, key string, start, stop int64) ([]cache.ZMember, error) {
+	if err := c.inject(ctx, "zrange"); err != nil {
+		return nil, err
+	}
+	return c.client.ZRange(ctx, key, start, stop)
+}
+
+func (c *FaultyClient) ZRangeByScore(ctx context.Context, key string, by cache.ZRangeBy) ([]cache.ZMember, error) {
+	if err := c.inject(ctx, "zrangebyscore"); err != nil {
+		return nil, err
+	}
+	return c.client.ZRangeByScore(ctx, key, by)
+}
+
+func (c *FaultyClient) ZRemRangeByScore(ctx context.Context, key, min, max string) (int64, error) {
+	if err := c.inject(ctx, "zremrangebyscore"); err != nil {
+		return 0, err
+	}
+	return c.client.ZRemRangeByScore(ctx, key, min, max)
+}
+
+func (c *FaultyClient) Pipeline() cache.Pipeline {
+	return newFaultyPipeline(c, "pipeline", c.client.Pipeline)
+}
+
+func (c *FaultyClient) TxPipeline() cache.Pipeline {
+	return newFaultyPipeline(c, "txpipeline", c.client.TxPipeline)
+}
+
+func (c *FaultyClient) Watch(ctx context.Context, fn cache.TxFunc, keys ...string) error {
+	if err := c.inject(ctx, "watch"); err != nil {
+		return err
+	}
+	return c.client.Watch(ctx, fn, keys...)
+}
+
+func (c *FaultyClient) Eval(ctx context.Context, script string, keys []string, args []interface{}) (interface{}, error) {
+	if err := c.inject(ctx, "eval"); err != nil {
+		return nil, err
+	}
+	return c.client.Eval(ctx, script, keys, args)
+}
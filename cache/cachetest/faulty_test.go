@@ -0,0 +1,104 @@
+package cachetest
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/brianwu291/go-learn/cache"
+	"github.com/brianwu291/go-learn/cache/memory"
+)
+
+func TestFaultyClient(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Fails chosen operations only", func(t *testing.T) {
+		client := NewFaultyClient(memory.NewClient(), Faults{
+			Operations: map[string]error{"get": cache.NewTimeoutError(nil)},
+		})
+
+		require.NoError(t, client.Set(ctx, "key", "value", time.Minute))
+		_, err := client.Get(ctx, "key")
+		assert.True(t, cache.IsTimeout(err))
+		assert.Equal(t, 1, client.Calls("get"))
+
+		client.SetFaults(Faults{})
+		val, err := client.Get(ctx, "key")
+		require.NoError(t, err)
+		assert.Equal(t, "value", val)
+	})
+
+	t.Run("Fails a share of calls", func(t *testing.T) {
+		tests := []struct {
+			rate float64
+			want int
+		}{
+			{rate: 0, want: 0},
+			{rate: 1, want: 100},
+		}
+
+		for _, tt := range tests {
+			client := NewFaultyClient(memory.NewClient(), Faults{ErrorRate: tt.rate})
+			failed := 0
+			for i := 0; i < 100; i++ {
+				if _, err := client.Incr(ctx, "counter"); err != nil {
+					assert.True(t, cache.IsConnectionError(err))
+					assert.True(t, errors.Is(err, ErrInjected))
+					failed += 1
+				}
+			}
+			assert.Equal(t, tt.want, failed, "rate %v", tt.rate)
+		}
+
+		client := NewFaultyClient(memory.NewClient(), Faults{ErrorRate: 0.5, Seed: 42})
+		failed := 0
+		for i := 0; i < 1000; i++ {
+			if _, err := client.Incr(ctx, "counter"); err != nil {
+				failed += 1
+			}
+		}
+		assert.InDelta(t, 500, failed, 100)
+	})
+
+	t.Run("Adds latency until the context ends", func(t *testing.T) {
+		client := NewFaultyClient(memory.NewClient(), Faults{Latency: 20 * time.Millisecond})
+
+		start := time.Now()
+		_, err := client.Exists(ctx, "key")
+		require.NoError(t, err)
+		assert.GreaterOrEqual(t, time.Since(start), 20*time.Millisecond)
+
+		short, cancel := context.WithTimeout(ctx, time.Millisecond)
+		defer cancel()
+		_, err = client.Exists(short, "key")
+		assert.True(t, cache.IsTimeout(err))
+	})
+
+	t.Run("Applies pipelines partially", func(t *testing.T) {
+		store := memory.NewClient()
+		pipelineErr := cache.NewConnectionError(ErrInjected)
+		client := NewFaultyClient(store, Faults{PipelineErr: pipelineErr, PipelineFailAfter: 2})
+
+		pipe := client.Pipeline()
+		first := pipe.Incr(ctx, "a")
+		second := pipe.Set(ctx, "b", "1", time.Minute)
+		third := pipe.Incr(ctx, "c")
+		assert.Equal(t, 3, pipe.Len())
+
+		err := pipe.Exec(ctx)
+		assert.Equal(t, pipelineErr, err)
+		require.NoError(t, first.Err())
+		assert.Equal(t, int64(1), first.Val())
+		require.NoError(t, second.Err())
+		assert.Equal(t, pipelineErr, third.Err())
+		assert.Zero(t, third.Val())
+
+		n, err := store.Exists(ctx, "a", "b", "c")
+		require.NoError(t, err)
+		assert.Equal(t, int64(2), n)
+	})
+}
@@ -0,0 +1,263 @@
+package cachetest
+
+import (
+	"context"
+	"time"
+
+	"github.com/brianwu291/go-learn/cache"
+)
+
+type (
+	// faultyPipeline holds commands back until Exec, so it can apply only
+	// the ones before PipelineFailAfter and fail the rest
+	faultyPipeline struct {
+		client    *FaultyClient
+		operation string
+		newInner  func() cache.Pipeline
+		queued    []func(inner cache.Pipeline)
+		failed    []func(err error)
+	}
+
+	// faultyCmd reports the injected error, or the inner command's result
+	// once Exec ran
+	faultyCmd struct {
+		err error
+	}
+
+	intCmd struct {
+		faultyCmd
+		inner cache.PipelineCmd
+	}
+
+	durationCmd struct {
+		faultyCmd
+		inner cache.PipelineDurationCmd
+	}
+
+	stringCmd struct {
+		faultyCmd
+		inner cache.PipelineStringCmd
+	}
+
+	boolCmd struct {
+		faultyCmd
+		inner cache.PipelineBoolCmd
+	}
+
+	mapCmd struct {
+		faultyCmd
+		inner cache.PipelineMapCmd
+	}
+
+	statusCmd struct {
+		faultyCmd
+		inner cache.PipelineStatusCmd
+	}
+)
+
+func newFaultyPipeline(client *FaultyClient, operation string, newInner func() cache.Pipeline) *faultyPipeline {
+	return &faultyPipeline{
+		client:    client,
+		operation: operation,
+		newInner:  newInner,
+	}
+}
+
+func (p *faultyPipeline) queue(apply func(inner cache.Pipeline), fail func(err error)) {
+	p.queued = append(p.queued, apply)
+	p.failed = append(p.failed, fail)
+}
+
+func (p *faultyPipeline) Get(ctx context.Context, key string) cache.PipelineStringCmd {
+	cmd := &stringCmd{}
+	p.queue(func(inner cache.Pipeline) { cmd.inner = inner.Get(ctx, key) }, cmd.fail)
+	return cmd
+}
+
+func (p *faultyPipeline) Set(ctx context.Context, key string, value interface{}, expiration time.Duration) cache.PipelineStatusCmd {
+	cmd := &statusCmd{}
+	p.queue(func(inner cache.Pipeline) { cmd.inner = inner.Set(ctx, key, value, expiration) }, cmd.fail)
+	return cmd
+}
+
+func (p *faultyPipeline) Del(ctx context.Context, keys ...string) cache.PipelineCmd {
+	cmd := &intCmd{}
+	p.queue(func(inner cache.Pipeline) { cmd.inner = inner.Del(ctx, keys...) }, cmd.fail)
+	return cmd
+}
+
+func (p *faultyPipeline) Incr(ctx context.Context, key string) cache.PipelineCmd {
+	cmd := &intCmd{}
+	p.queue(func(inner cache.Pipeline) { cmd.inner = inner.Incr(ctx, key) }, cmd.fail)
+	return cmd
+}
+
+func (p *faultyPipeline) TTL(ctx context.Context, key string) cache.PipelineDurationCmd {
+	cmd := &durationCmd{}
+	p.queue(func(inner cache.Pipeline) { cmd.inner = inner.TTL(ctx, key) }, cmd.fail)
+	return cmd
+}
+
+func (p *faultyPipeline) Expire(ctx context.Context, key string, expiration time.Duration) cache.PipelineBoolCmd {
+	cmd := &boolCmd{}
+	p.queue(func(inner cache.Pipeline) { cmd.inner = inner.Expire(ctx, key, expiration) }, cmd.fail)
+	return cmd
+}
+
+func (p *faultyPipeline) HGet(ctx context.Context, key, field string) cache.PipelineStringCmd {
+	cmd := &stringCmd{}
+	p.queue(func(inner cache.Pipeline) { cmd.inner = inner.HGet(ctx, key, field) }, cmd.fail)
+	return cmd
+}
+
+func (p *faultyPipeline) HGetAll(ctx context.Context, key string) cache.PipelineMapCmd {
+	cmd := &mapCmd{}
+	p.queue(func(inner cache.Pipeline) { cmd.inner = inner.HGetAll(ctx, key) }, cmd.fail)
+	return cmd
+}
+
+func (p *faultyPipeline) HSet(ctx context.Context, key string, values map[string]interface{}) cache.PipelineCmd {
+	cmd := &intCmd{}
+	p.queue(func(inner cache.Pipeline) { cmd.inner = inner.HSet(ctx, key, values) }, cmd.fail)
+	return cmd
+}
+
+func (p *faultyPipeline) HDel(ctx context.Context, key string, fields ...string) cache.PipelineCmd {
+	cmd := &intCmd{}
+	p.queue(func(inner cache.Pipeline) { cmd.inner = inner.HDel(ctx, key, fields...) }, cmd.fail)
+	return cmd
+}
+
+func (p *faultyPipeline) HIncrBy(ctx context.Context, key, field string, incr int64) cache.PipelineCmd {
+	cmd := &intCmd{}
+	p.queue(func(inner cache.Pipeline) { cmd.inner = inner.HIncrBy(ctx, key, field, incr) }, cmd.fail)
+	return cmd
+}
+
+func (p *faultyPipeline) Len() int {
+	return len(p.queued)
+}
+
+func (p *faultyPipeline) Discard() {
+	p.queued = nil
+	p.failed = nil
+}
+
+// Exec applies the commands before PipelineFailAfter through the wrapped
+// client and fails the rest with PipelineErr
+func (p *faultyPipeline) Exec(ctx context.Context) error {
+	queued, failed := p.queued, p.failed
+	p.Discard()
+
+	if err := p.client.inject(ctx, p.operation); err != nil {
+		for _, fail := range failed {
+			fail(err)
+		}
+		return err
+	}
+
+	failAfter, pipelineErr := p.client.pipelineFaults()
+	applied := len(queued)
+	if pipelineErr != nil && failAfter < applied {
+		applied = failAfter
+	}
+
+	if applied > 0 {
+		inner := p.newInner()
+		for _, apply := range queued[:applied] {
+			apply(inner)
+		}
+		if err := inner.Exec(ctx); err != nil {
+			return err
+		}
+	}
+
+	for _, fail := range failed[applied:] {
+		fail(pipelineErr)
+	}
+	if applied < len(queued) {
+		return pipelineErr
+	}
+	return nil
+}
+
+func (c *faultyCmd) fail(err error) {
+	c.err = err
+}
+
+func (c *intCmd) Val() int64 {
+	if c.inner == nil {
+		return 0
+	}
+	return c.inner.Val()
+}
+
+func (c *intCmd) Err() error {
+	if c.err != nil || c.inner == nil {
+		return c.err
+	}
+	return c.inner.Err()
+}
+
+func (c *durationCmd) Val() time.Duration {
+	if c.inner == nil {
+		return 0
+	}
+	return c.inner.Val()
+}
+
+func (c *durationCmd) Err() error {
+	if c.err != nil || c.inner == nil {
+		return c.err
+	}
+	return c.inner.Err()
+}
+
+func (c *stringCmd) Val() string {
+	if c.inner == nil {
+		return ""
+	}
+	return c.inner.Val()
+}
+
+func (c *stringCmd) Err() error {
+	if c.err != nil || c.inner == nil {
+		return c.err
+	}
+	return c.inner.Err()
+}
+
+func (c *boolCmd) Val() bool {
+	if c.inner == nil {
+		return false
+	}
+	return c.inner.Val()
+}
+
+func (c *boolCmd) Err() error {
+	if c.err != nil || c.inner == nil {
+		return c.err
+	}
+	return c.inner.Err()
+}
+
+func (c *mapCmd) Val() map[string]string {
+	if c.inner == nil {
+		return nil
+	}
+	return c.inner.Val()
+}
+
+func (c *mapCmd) Err() error {
+	if c.err != nil || c.inner == nil {
+		return c.err
+	}
+	return c.inner.Err()
+}
+
+func (c *statusCmd) Err() error {
+	if c.err != nil || c.inner == nil {
+		return c.err
+	}
+	return c.inner.Err()
+}
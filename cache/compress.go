@@ -0,0 +1,234 @@
+package cache
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+type (
+	// ValueCodec is implemented by clients that transform values on the way
+	// in and out. Helpers that write through Eval, like TaggedClient, use it
+	// so scripted writes are encoded the same way as Set.
+	ValueCodec interface {
+		EncodeValue(value interface{}) (interface{}, error)
+		DecodeValue(value string) (string, error)
+	}
+
+	CompressionConfig struct {
+		// Threshold is the smallest value in bytes worth compressing, zero
+		// uses 1KB
+		Threshold int
+		// Level is a compress/gzip level, zero uses gzip.DefaultCompression
+		Level int
+	}
+
+	CompressionStats struct {
+		// Values counts every string value written
+		Values     int64 `json:"values"`
+		Compressed int64 `json:"compressed"`
+		// RawBytes and StoredBytes compare value sizes before and after
+		RawBytes    int64   `json:"rawBytes"`
+		StoredBytes int64   `json:"storedBytes"`
+		SavedRatio  float64 `json:"savedRatio"`
+	}
+
+	// CompressedClient gzips string values from Threshold bytes up. Stored
+	// values start with a header, so values written before compression was
+	// enabled, or below the threshold, are still read back as they are.
+	//
+	// Only Get, Set, SetNX, MGet and MSet are transformed. Hashes, sets,
+	// pipelines and Eval see the stored bytes.
+	CompressedClient struct {
+		Client
+		config CompressionConfig
+
+		mu    sync.Mutex
+		stats CompressionStats
+	}
+)
+
+// compressedHeader marks gzipped values. Text and JSON never start with a
+// NUL byte, so plain values cannot be mistaken for compressed ones.
+const compressedHeader = "\x00gz1"
+
+const defaultCompressionThreshold = 1024
+
+func NewCompressedClient(client Client, config CompressionConfig) *CompressedClient {
+	if config.Threshold <= 0 {
+		config.Threshold = defaultCompressionThreshold
+	}
+	if config.Level == 0 {
+		config.Level = gzip.DefaultCompression
+	}
+
+	return &CompressedClient{
+		Client: client,
+		config: config,
+	}
+}
+
+// RegisterScript forwards to clients that emulate Eval
+func (c *CompressedClient) RegisterScript(script string, fn ScriptFunc) {
+	if registrar, ok := c.Client.(ScriptRegistrar); ok {
+		registrar.RegisterScript(script, fn)
+	}
+}
+
+// IsCluster forwards to the wrapped client
+func (c *CompressedClient) IsCluster() bool {
+	return IsCluster(c.Client)
+}
+
+// ScanKeys forwards to clients that can scan
+func (c *CompressedClient) ScanKeys(ctx context.Context, pattern string) ([]string, error) {
+	return ScanKeys(ctx, c.Client, pattern)
+}
+
+// Stats returns the size savings so far
+func (c *CompressedClient) Stats() CompressionStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	stats := c.stats
+	if stats.RawBytes > 0 {
+		stats.SavedRatio = 1 - float64(stats.StoredBytes)/float64(stats.RawBytes)
+	}
+	return stats
+}
+
+// EncodeValue compresses strings and byte slices at or above the
+// threshold, other values pass through untouched
+func (c *CompressedClient) EncodeValue(value interface{}) (interface{}, error) {
+	var raw []byte
+	switch v := value.(type) {
+	case string:
+		raw = []byte(v)
+	case []byte:
+		raw = v
+	default:
+		return value, nil
+	}
+
+	stored, compressed := raw, false
+	if len(raw) >= c.config.Threshold {
+		gzipped, err := c.compress(raw)
+		if err != nil {
+			return nil, err
+		}
+		// incompressible data stays plain
+		if len(gzipped) < len(raw) {
+			stored, compressed = gzipped, true
+		}
+	}
+
+	c.record(len(raw), len(stored), compressed)
+	return stored, nil
+}
+
+// DecodeValue returns plain values as they are and decompresses values
+// carrying the header
+func (c *CompressedClient) DecodeValue(value string) (string, error) {
+	if len(value) < len(compressedHeader) || value[:len(compressedHeader)] != compressedHeader {
+		return value, nil
+	}
+
+	reader, err := gzip.NewReader(bytes.NewReader([]byte(value[len(compressedHeader):])))
+	if err != nil {
+		return "", fmt.Errorf("%w: corrupt compressed value: %w", ErrInvalidValue, err)
+	}
+	defer reader.Close()
+
+	raw, err := io.ReadAll(reader)
+	if err != nil {
+		return "", fmt.Errorf("%w: corrupt compressed value: %w", ErrInvalidValue, err)
+	}
+	return string(raw), nil
+}
+
+func (c *CompressedClient) compress(raw []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteString(compressedHeader)
+
+	writer, err := gzip.NewWriterLevel(&buf, c.config.Level)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidConfig, err)
+	}
+	if _, err := writer.Write(raw); err != nil {
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (c *CompressedClient) record(rawSize, storedSize int, compressed bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.stats.Values += 1
+	c.stats.RawBytes += int64(rawSize)
+	c.stats.StoredBytes += int64(storedSize)
+	if compressed {
+		c.stats.Compressed += 1
+	}
+}
+
+func (c *CompressedClient) Get(ctx context.Context, key string) (string, error) {
+	val, err := c.Client.Get(ctx, key)
+	if err != nil {
+		return "", err
+	}
+	return c.DecodeValue(val)
+}
+
+func (c *CompressedClient) Set(ctx context.Context, key string, value interface{}, expiration time.Duration) error {
+	encoded, err := c.EncodeValue(value)
+	if err != nil {
+		return err
+	}
+	return c.Client.Set(ctx, key, encoded, expiration)
+}
+
+func (c *CompressedClient) SetNX(ctx context.Context, key string, value interface{}, expiration time.Duration) (bool, error) {
+	encoded, err := c.EncodeValue(value)
+	if err != nil {
+		return false, err
+	}
+	return c.Client.SetNX(ctx, key, encoded, expiration)
+}
+
+// MGet drops values that fail to decode, like keys that are missing
+func (c *CompressedClient) MGet(ctx context.Context, keys ...string) (map[string]string, error) {
+	vals, err := c.Client.MGet(ctx, keys...)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(map[string]string, len(vals))
+	for key, val := range vals {
+		decoded, err := c.DecodeValue(val)
+		if err != nil {
+			continue
+		}
+		result[key] = decoded
+	}
+	return result, nil
+}
+
+func (c *CompressedClient) MSet(ctx context.Context, values map[string]interface{}, expiration time.Duration) error {
+	encoded := make(map[string]interface{}, len(values))
+	for key, value := range values {
+		v, err := c.EncodeValue(value)
+		if err != nil {
+			return err
+		}
+		encoded[key] = v
+	}
+	return c.Client.MSet(ctx, encoded, expiration)
+}
@@ -0,0 +1,107 @@
+package cache_test
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/brianwu291/go-learn/cache"
+	"github.com/brianwu291/go-learn/cache/memory"
+)
+
+func TestCompressedClient(t *testing.T) {
+	ctx := context.Background()
+	large := strings.Repeat(`{"title":"Mens Casual Premium Slim Fit T-Shirts"},`, 100)
+
+	tests := []struct {
+		name           string
+		value          string
+		wantCompressed bool
+	}{
+		{name: "Compresses values above the threshold", value: large, wantCompressed: true},
+		{name: "Keeps small values plain", value: `{"id":1}`, wantCompressed: false},
+		{name: "Keeps incompressible values plain", value: randomString(t, 2048), wantCompressed: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := memory.NewClient()
+			client := cache.NewCompressedClient(store, cache.CompressionConfig{Threshold: 512})
+
+			require.NoError(t, client.Set(ctx, "key", tt.value, time.Minute))
+
+			raw, err := store.Get(ctx, "key")
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantCompressed, raw != tt.value)
+			if tt.wantCompressed {
+				assert.Less(t, len(raw), len(tt.value))
+			}
+
+			val, err := client.Get(ctx, "key")
+			require.NoError(t, err)
+			assert.Equal(t, tt.value, val)
+		})
+	}
+
+	t.Run("Reads entries written before compression", func(t *testing.T) {
+		store := memory.NewClient()
+		require.NoError(t, store.Set(ctx, "old", large, time.Minute))
+		client := cache.NewCompressedClient(store, cache.CompressionConfig{})
+
+		val, err := client.Get(ctx, "old")
+		require.NoError(t, err)
+		assert.Equal(t, large, val)
+
+		vals, err := client.MGet(ctx, "old", "missing")
+		require.NoError(t, err)
+		assert.Equal(t, map[string]string{"old": large}, vals)
+	})
+
+	t.Run("Rejects corrupt compressed values", func(t *testing.T) {
+		store := memory.NewClient()
+		require.NoError(t, store.Set(ctx, "broken", "\x00gz1not gzip", time.Minute))
+		client := cache.NewCompressedClient(store, cache.CompressionConfig{})
+
+		_, err := client.Get(ctx, "broken")
+		assert.True(t, errors.Is(err, cache.ErrInvalidValue))
+	})
+
+	for name, store := range testClients(t) {
+		t.Run("Compresses tagged writes and reports savings/"+name, func(t *testing.T) {
+			client := cache.NewCompressedClient(store, cache.CompressionConfig{Threshold: 512})
+			tagged := cache.NewTaggedClient(client)
+
+			require.NoError(t, tagged.SetWithTags(ctx, "products", []byte(large), time.Minute, "category"))
+			require.NoError(t, client.MSet(ctx, map[string]interface{}{"small": "x", "count": 1}, time.Minute))
+
+			raw, err := store.Get(ctx, "products")
+			require.NoError(t, err)
+			assert.Less(t, len(raw), len(large))
+
+			val, err := tagged.Get(ctx, "products")
+			require.NoError(t, err)
+			assert.Equal(t, large, val)
+
+			stats := client.Stats()
+			assert.Equal(t, int64(2), stats.Values, "non string values are not counted")
+			assert.Equal(t, int64(1), stats.Compressed)
+			assert.Equal(t, int64(len(large)+1), stats.RawBytes)
+			assert.Equal(t, int64(len(raw)+1), stats.StoredBytes)
+			assert.Greater(t, stats.SavedRatio, 0.5)
+		})
+	}
+}
+
+func randomString(t *testing.T, size int) string {
+	t.Helper()
+	buf := make([]byte, size)
+	_, err := rand.Read(buf)
+	require.NoError(t, err)
+	return string(buf)
+}
@@ -0,0 +1,131 @@
+package cache
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	utils "github.com/brianwu291/go-learn/utils"
+)
+
+type (
+	// Mode is the topology the cache client connects to
+	Mode string
+
+	TLSConfig struct {
+		Enabled bool
+		// CAFile is a PEM bundle used to verify the server certificate.
+		// When empty the system roots are used.
+		CAFile string
+		// CertFile and KeyFile enable mutual TLS when both are set
+		CertFile           string
+		KeyFile            string
+		ServerName         string
+		InsecureSkipVerify bool
+	}
+
+	Config struct {
+		Mode Mode
+
+		// Host and Port address a standalone node
+		Host string
+		Port string
+		// Addrs are the sentinel addresses in sentinel mode and the seed
+		// nodes in cluster mode
+		Addrs []string
+		// MasterName is the sentinel master set name
+		MasterName       string
+		SentinelUsername string
+		SentinelPassword string
+
+		// Username enables Redis 6 ACL auth, leave empty for legacy AUTH
+		Username string
+		Password string
+		DB       int
+
+		TLS TLSConfig
+
+		PoolSize     int
+		MinIdleConns int
+		DialTimeout  time.Duration
+		ReadTimeout  time.Duration
+		WriteTimeout time.Duration
+		PoolTimeout  time.Duration
+	}
+)
+
+const (
+	ModeStandalone Mode = "standalone"
+	ModeSentinel   Mode = "sentinel"
+	ModeCluster    Mode = "cluster"
+)
+
+// NewConfigFromEnv builds a Config from REDIS_* environment variables.
+// Zero values for pool and timeout settings keep the driver defaults.
+func NewConfigFromEnv() *Config {
+	return &Config{
+		Mode:             Mode(strings.ToLower(utils.GetEnv("REDIS_MODE", string(ModeStandalone)))),
+		Host:             utils.GetEnv("REDIS_HOST", "localhost"),
+		Port:             utils.GetEnv("REDIS_PORT", "6379"),
+		Addrs:            utils.GetEnvAsSlice("REDIS_ADDRS", ",", nil),
+		MasterName:       utils.GetEnv("REDIS_MASTER_NAME", ""),
+		SentinelUsername: utils.GetEnv("REDIS_SENTINEL_USERNAME", ""),
+		SentinelPassword: utils.GetEnv("REDIS_SENTINEL_PASSWORD", ""),
+		Username:         utils.GetEnv("REDIS_USERNAME", ""),
+		Password:         utils.GetEnv("REDIS_PASSWORD", ""),
+		DB:               utils.GetEnvAsInt("REDIS_DB", 0),
+		TLS: TLSConfig{
+			Enabled:            utils.GetEnvAsBool("REDIS_TLS_ENABLED", false),
+			CAFile:             utils.GetEnv("REDIS_TLS_CA_FILE", ""),
+			CertFile:           utils.GetEnv("REDIS_TLS_CERT_FILE", ""),
+			KeyFile:            utils.GetEnv("REDIS_TLS_KEY_FILE", ""),
+			ServerName:         utils.GetEnv("REDIS_TLS_SERVER_NAME", ""),
+			InsecureSkipVerify: utils.GetEnvAsBool("REDIS_TLS_INSECURE_SKIP_VERIFY", false),
+		},
+		PoolSize:     utils.GetEnvAsInt("REDIS_POOL_SIZE", 0),
+		MinIdleConns: utils.GetEnvAsInt("REDIS_MIN_IDLE_CONNS", 0),
+		DialTimeout:  utils.GetEnvAsDuration("REDIS_DIAL_TIMEOUT", 0),
+		ReadTimeout:  utils.GetEnvAsDuration("REDIS_READ_TIMEOUT", 0),
+		WriteTimeout: utils.GetEnvAsDuration("REDIS_WRITE_TIMEOUT", 0),
+		PoolTimeout:  utils.GetEnvAsDuration("REDIS_POOL_TIMEOUT", 0),
+	}
+}
+
+// Address returns host:port of the standalone node
+func (c *Config) Address() string {
+	return fmt.Sprintf("%s:%s", c.Host, c.Port)
+}
+
+// Validate checks the topology specific settings are consistent
+func (c *Config) Validate() error {
+	switch c.Mode {
+	case "", ModeStandalone:
+		if c.Host == "" || c.Port == "" {
+			return fmt.Errorf("%w: host and port required in standalone mode", ErrInvalidConfig)
+		}
+	case ModeSentinel:
+		if c.MasterName == "" {
+			return fmt.Errorf("%w: master name required in sentinel mode", ErrInvalidConfig)
+		}
+		if len(c.Addrs) == 0 {
+			return fmt.Errorf("%w: at least one sentinel address required", ErrInvalidConfig)
+		}
+	case ModeCluster:
+		if len(c.Addrs) == 0 {
+			return fmt.Errorf("%w: at least one cluster node address required", ErrInvalidConfig)
+		}
+		if c.DB != 0 {
+			return fmt.Errorf("%w: cluster mode only supports db 0", ErrInvalidConfig)
+		}
+	default:
+		return fmt.Errorf("%w: unsupported mode %q", ErrInvalidConfig, c.Mode)
+	}
+
+	if (c.TLS.CertFile == "") != (c.TLS.KeyFile == "") {
+		return fmt.Errorf("%w: tls cert file and key file must be set together", ErrInvalidConfig)
+	}
+	if c.PoolSize < 0 || c.MinIdleConns < 0 {
+		return fmt.Errorf("%w: pool settings must not be negative", ErrInvalidConfig)
+	}
+	return nil
+}
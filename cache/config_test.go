@@ -0,0 +1,90 @@
+package cache
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewConfigFromEnv(t *testing.T) {
+	t.Setenv("REDIS_MODE", "Sentinel")
+	t.Setenv("REDIS_ADDRS", "sentinel-1:26379, sentinel-2:26379,")
+	t.Setenv("REDIS_MASTER_NAME", "mymaster")
+	t.Setenv("REDIS_USERNAME", "app")
+	t.Setenv("REDIS_PASSWORD", "secret")
+	t.Setenv("REDIS_DB", "2")
+	t.Setenv("REDIS_TLS_ENABLED", "true")
+	t.Setenv("REDIS_TLS_CA_FILE", "/etc/redis/ca.pem")
+	t.Setenv("REDIS_POOL_SIZE", "20")
+	t.Setenv("REDIS_READ_TIMEOUT", "750ms")
+
+	cfg := NewConfigFromEnv()
+
+	assert.Equal(t, ModeSentinel, cfg.Mode)
+	assert.Equal(t, []string{"sentinel-1:26379", "sentinel-2:26379"}, cfg.Addrs)
+	assert.Equal(t, "mymaster", cfg.MasterName)
+	assert.Equal(t, "app", cfg.Username)
+	assert.Equal(t, "secret", cfg.Password)
+	assert.Equal(t, 2, cfg.DB)
+	assert.True(t, cfg.TLS.Enabled)
+	assert.Equal(t, "/etc/redis/ca.pem", cfg.TLS.CAFile)
+	assert.Equal(t, 20, cfg.PoolSize)
+	assert.Equal(t, 750*time.Millisecond, cfg.ReadTimeout)
+	assert.Equal(t, time.Duration(0), cfg.DialTimeout)
+	assert.NoError(t, cfg.Validate())
+}
+
+func TestConfig_Validate(t *testing.T) {
+	tests := []struct {
+		name        string
+		config      Config
+		expectError bool
+	}{
+		{
+			name:   "Standalone defaults",
+			config: Config{Host: "localhost", Port: "6379"},
+		},
+		{
+			name:        "Standalone without host",
+			config:      Config{Mode: ModeStandalone, Port: "6379"},
+			expectError: true,
+		},
+		{
+			name:        "Sentinel without master name",
+			config:      Config{Mode: ModeSentinel, Addrs: []string{"localhost:26379"}},
+			expectError: true,
+		},
+		{
+			name:   "Cluster with seed nodes",
+			config: Config{Mode: ModeCluster, Addrs: []string{"node-1:6379", "node-2:6379"}},
+		},
+		{
+			name:        "Cluster with non zero db",
+			config:      Config{Mode: ModeCluster, Addrs: []string{"node-1:6379"}, DB: 1},
+			expectError: true,
+		},
+		{
+			name:        "Client cert without key",
+			config:      Config{Host: "localhost", Port: "6379", TLS: TLSConfig{Enabled: true, CertFile: "client.pem"}},
+			expectError: true,
+		},
+		{
+			name:        "Unknown mode",
+			config:      Config{Mode: "replica"},
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.config.Validate()
+			if tt.expectError {
+				assert.True(t, errors.Is(err, ErrInvalidConfig))
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
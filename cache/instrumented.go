@@ -0,0 +1,410 @@
+package cache
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+type (
+	// OpStats are the counters of one operation on one key family.
+	// Hits and Misses are only counted for reads.
+	OpStats struct {
+		Family           string        `json:"family"`
+		Operation        string        `json:"operation"`
+		Calls            int64         `json:"calls"`
+		Hits             int64         `json:"hits"`
+		Misses           int64         `json:"misses"`
+		ConnectionErrors int64         `json:"connectionErrors"`
+		OtherErrors      int64         `json:"otherErrors"`
+		TotalLatency     time.Duration `json:"totalLatency"`
+		MaxLatency       time.Duration `json:"maxLatency"`
+	}
+
+	// StatsProvider is what the app scrapes to export cache numbers
+	StatsProvider interface {
+		Snapshot() []OpStats
+	}
+
+	// Metrics collects OpStats in memory
+	Metrics struct {
+		mu    sync.Mutex
+		stats map[statsKey]*OpStats
+	}
+
+	statsKey struct {
+		family    string
+		operation string
+	}
+
+	// InstrumentedClient records every call of the wrapped Client into
+	// Metrics, grouped by the key family registered in the KeyBuilder
+	InstrumentedClient struct {
+		client  Client
+		keys    *KeyBuilder
+		metrics *Metrics
+	}
+
+	instrumentedPipeline struct {
+		Pipeline
+		client    *InstrumentedClient
+		operation string
+	}
+)
+
+const (
+	// UnknownFamily groups keys outside every registered family
+	UnknownFamily = "unknown"
+	// MultiKeyFamily groups batches that span families, such as pipelines
+	MultiKeyFamily = "*"
+)
+
+func NewMetrics() *Metrics {
+	return &Metrics{
+		stats: make(map[statsKey]*OpStats),
+	}
+}
+
+// Snapshot returns a copy of the counters sorted by family and operation
+func (m *Metrics) Snapshot() []OpStats {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	result := make([]OpStats, 0, len(m.stats))
+	for _, stats := range m.stats {
+		result = append(result, *stats)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Family == result[j].Family {
+			return result[i].Operation < result[j].Operation
+		}
+		return result[i].Family < result[j].Family
+	})
+	return result
+}
+
+func (m *Metrics) record(family, operation string, latency time.Duration, hits, misses int64, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key := statsKey{family: family, operation: operation}
+	stats, ok := m.stats[key]
+	if !ok {
+		stats = &OpStats{Family: family, Operation: operation}
+		m.stats[key] = stats
+	}
+
+	stats.Calls += 1
+	stats.Hits += hits
+	stats.Misses += misses
+	stats.TotalLatency += latency
+	if latency > stats.MaxLatency {
+		stats.MaxLatency = latency
+	}
+
+	switch {
+	case err == nil, IsKeyNotFound(err):
+	case IsConnectionError(err):
+		stats.ConnectionErrors += 1
+	default:
+		stats.OtherErrors += 1
+	}
+}
+
+// NewInstrumentedClient wraps client. keys may be nil, every key is then
+// reported under UnknownFamily.
+func NewInstrumentedClient(client Client, keys *KeyBuilder, metrics *Metrics) *InstrumentedClient {
+	return &InstrumentedClient{
+		client:  client,
+		keys:    keys,
+		metrics: metrics,
+	}
+}
+
+func (c *InstrumentedClient) familyOf(key string) string {
+	if c.keys == nil {
+		return UnknownFamily
+	}
+	if family, ok := c.keys.FamilyOf(key); ok {
+		return family.Name
+	}
+	return UnknownFamily
+}
+
+func (c *InstrumentedClient) familyOfKeys(keys []string) string {
+	if len(keys) == 0 {
+		return MultiKeyFamily
+	}
+	family := c.familyOf(keys[0])
+	for _, key := range keys[1:] {
+		if c.familyOf(key) != family {
+			return MultiKeyFamily
+		}
+	}
+	return family
+}
+
+func (c *InstrumentedClient) observe(operation, key string, start time.Time, err error) {
+	c.metrics.record(c.familyOf(key), operation, time.Since(start), 0, 0, err)
+}
+
+// observeRead counts a miss for KeyNotFoundError and a hit for success
+func (c *InstrumentedClient) observeRead(operation, key string, start time.Time, err error) {
+	var hits, misses int64
+	switch {
+	case err == nil:
+		hits = 1
+	case IsKeyNotFound(err):
+		misses = 1
+	}
+	c.metrics.record(c.familyOf(key), operation, time.Since(start), hits, misses, err)
+}
+
+// RegisterScript forwards to clients that emulate Eval, so wrapping does
+// not hide the capability from Locker or TaggedClient
+func (c *InstrumentedClient) RegisterScript(script string, fn ScriptFunc) {
+	if registrar, ok := c.client.(ScriptRegistrar); ok {
+		registrar.RegisterScript(script, fn)
+	}
+}
+
+// IsCluster forwards to the wrapped client
+func (c *InstrumentedClient) IsCluster() bool {
+	return IsCluster(c.client)
+}
+
+// ScanKeys forwards to clients that can scan, recorded under the pattern's
+// family
+func (c *InstrumentedClient) ScanKeys(ctx context.Context, pattern string) ([]string, error) {
+	start := time.Now()
+	keys, err := ScanKeys(ctx, c.client, pattern)
+	c.metrics.record(c.familyOf(pattern), "scan", time.Since(start), 0, 0, err)
+	return keys, err
+}
+
+func (c *InstrumentedClient) Get(ctx context.Context, key string) (string, error) {
+	start := time.Now()
+	val, err := c.client.Get(ctx, key)
+	c.observeRead("get", key, start, err)
+	return val, err
+}
+
+func (c *InstrumentedClient) Set(ctx context.Context, key string, value interface{}, expiration time.Duration) error {
+	start := time.Now()
+	err := c.client.Set(ctx, key, value, expiration)
+	c.observe("set", key, start, err)
+	return err
+}
+
+func (c *InstrumentedClient) SetNX(ctx context.Context, key string, value interface{}, expiration time.Duration) (bool, error) {
+	start := time.Now()
+	ok, err := c.client.SetNX(ctx, key, value, expiration)
+	c.observe("setnx", key, start, err)
+	return ok, err
+}
+
+func (c *InstrumentedClient) Del(ctx context.Context, keys ...string) (int64, error) {
+	start := time.Now()
+	n, err := c.client.Del(ctx, keys...)
+	c.metrics.record(c.familyOfKeys(keys), "del", time.Since(start), 0, 0, err)
+	return n, err
+}
+
+func (c *InstrumentedClient) Exists(ctx context.Context, keys ...string) (int64, error) {
+	start := time.Now()
+	n, err := c.client.Exists(ctx, keys...)
+	c.metrics.record(c.familyOfKeys(keys), "exists", time.Since(start), 0, 0, err)
+	return n, err
+}
+
+// MGet counts one hit or miss per requested key
+func (c *InstrumentedClient) MGet(ctx context.Context, keys ...string) (map[string]string, error) {
+	start := time.Now()
+	vals, err := c.client.MGet(ctx, keys...)
+	var hits, misses int64
+	if err == nil {
+		hits = int64(len(vals))
+		misses = int64(len(keys)) - hits
+	}
+	c.metrics.record(c.familyOfKeys(keys), "mget", time.Since(start), hits, misses, err)
+	return vals, err
+}
+
+func (c *InstrumentedClient) MSet(ctx context.Context, values map[string]interface{}, expiration time.Duration) error {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+
+	start := time.Now()
+	err := c.client.MSet(ctx, values, expiration)
+	c.metrics.record(c.familyOfKeys(keys), "mset", time.Since(start), 0, 0, err)
+	return err
+}
+
+func (c *InstrumentedClient) Incr(ctx context.Context, key string) (int64, error) {
+	start := time.Now()
+	n, err := c.client.Incr(ctx, key)
+	c.observe("incr", key, start, err)
+	return n, err
+}
+
+func (c *InstrumentedClient) TTL(ctx context.Context, key string) (time.Duration, error) {
+	start := time.Now()
+	ttl, err := c.client.TTL(ctx, key)
+	c.observe("ttl", key, start, err)
+	return ttl, err
+}
+
+func (c *InstrumentedClient) Expire(ctx context.Context, key string, expiration time.Duration) error {
+	start := time.Now()
+	err := c.client.Expire(ctx, key, expiration)
+	c.observe("expire", key, start, err)
+	return err
+}
+
+func (c *InstrumentedClient) HGet(ctx context.Context, key, field string) (string, error) {
+	start := time.Now()
+	val, err := c.client.HGet(ctx, key, field)
+	c.observeRead("hget", key, start, err)
+	return val, err
+}
+
+func (c *InstrumentedClient) HGetAll(ctx context.Context, key string) (map[string]string, error) {
+	start := time.Now()
+	vals, err := c.client.HGetAll(ctx, key)
+	c.observe("hgetall", key, start, err)
+	return vals, err
+}
+
+func (c *InstrumentedClient) HSet(ctx context.Context, key string, values map[string]interface{}) (int64, error) {
+	start := time.Now()
+	n, err := c.client.HSet(ctx, key, values)
+	c.observe("hset", key, start, err)
+	return n, err
+}
+
+func (c *InstrumentedClient) HDel(ctx context.Context, key string, fields ...string) (int64, error) {
+	start := time.Now()
+	n, err := c.client.HDel(ctx, key, fields...)
+	c.observe("hdel", key, start, err)
+	return n, err
+}
+
+func (c *InstrumentedClient) HIncrBy(ctx context.Context, key, field string, incr int64) (int64, error) {
+	start := time.Now()
+	n, err := c.client.HIncrBy(ctx, key, field, incr)
+	c.observe("hincrby", key, start, err)
+	return n, err
+}
+
+func (c *InstrumentedClient) SAdd(ctx context.Context, key string, members ...string) (int64, error) {
+	start := time.Now()
+	n, err := c.client.SAdd(ctx, key, members...)
+	c.observe("sadd", key, start, err)
+	return n, err
+}
+
+func (c *InstrumentedClient) SRem(ctx context.Context, key string, members ...string) (int64, error) {
+	start := time.Now()
+	n, err := c.client.SRem(ctx, key, members...)
+	c.observe("srem", key, start, err)
+	return n, err
+}
+
+func (c *InstrumentedClient) SMembers(ctx context.Context, key string) ([]string, error) {
+	start := time.Now()
+	members, err := c.client.SMembers(ctx, key)
+	c.observe("smembers", key, start, err)
+	return members, err
+}
+
+func (c *InstrumentedClient) SCard(ctx context.Context, key string) (int64, error) {
+	start := time.Now()
+	n, err := c.client.SCard(ctx, key)
+	c.observe("scard", key, start, err)
+	return n, err
+}
+
+func (c *InstrumentedClient) ZAdd(ctx context.Context, key string, members ...ZMember) (int64, error) {
+	start := time.Now()
+	n, err := c.client.ZAdd(ctx, key, members...)
+	c.observe("zadd", key, start, err)
+	return n, err
+}
+
+func (c *InstrumentedClient) ZRem(ctx context.Context, key string, members ...string) (int64, error) {
+	start := time.Now()
+	n, err := c.client.ZRem(ctx, key, members...)
+	c.observe("zrem", key, start, err)
+	return n, err
+}
+
+func (c *InstrumentedClient) ZScore(ctx context.Context, key, member string) (float64, error) {
+	start := time.Now()
+	score, err := c.client.ZScore(ctx, key, member)
+	c.observeRead("zscore", key, start, err)
+	return score, err
+}
+
+func (c *InstrumentedClient) ZCard(ctx context.Context, key string) (int64, error) {
+	start := time.Now()
+	n, err := c.client.ZCard(ctx, key)
+	c.observe("zcard", key, start, err)
+	return n, err
+}
+
+func (c *InstrumentedClient) ZRange(ctx context.Context, key string, start, stop int64) ([]ZMember, error) {
+	begin := time.Now()
+	members, err := c.client.ZRange(ctx, key, start, stop)
+	c.observe("zrange", key, begin, err)
+	return members, err
+}
+
+func (c *InstrumentedClient) ZRangeByScore(ctx context.Context, key string, by ZRangeBy) ([]ZMember, error) {
+	start := time.Now()
+	members, err := c.client.ZRangeByScore(ctx, key, by)
+	c.observe("zrangebyscore", key, start, err)
+	return members, err
+}
+
+func (c *InstrumentedClient) ZRemRangeByScore(ctx context.Context, key, min, max string) (int64, error) {
+	start := time.Now()
+	n, err := c.client.ZRemRangeByScore(ctx, key, min, max)
+	c.observe("zremrangebyscore", key, start, err)
+	return n, err
+}
+
+func (c *InstrumentedClient) Pipeline() Pipeline {
+	return &instrumentedPipeline{Pipeline: c.client.Pipeline(), client: c, operation: "pipeline"}
+}
+
+func (c *InstrumentedClient) TxPipeline() Pipeline {
+	return &instrumentedPipeline{Pipeline: c.client.TxPipeline(), client: c, operation: "txpipeline"}
+}
+
+func (c *InstrumentedClient) Watch(ctx context.Context, fn TxFunc, keys ...string) error {
+	start := time.Now()
+	err := c.client.Watch(ctx, fn, keys...)
+	c.metrics.record(c.familyOfKeys(keys), "watch", time.Since(start), 0, 0, err)
+	return err
+}
+
+func (c *InstrumentedClient) Eval(ctx context.Context, script string, keys []string, args []interface{}) (interface{}, error) {
+	start := time.Now()
+	result, err := c.client.Eval(ctx, script, keys, args)
+	family := MultiKeyFamily
+	if len(keys) > 0 {
+		family = c.familyOf(keys[0])
+	}
+	c.metrics.record(family, "eval", time.Since(start), 0, 0, err)
+	return result, err
+}
+
+func (p *instrumentedPipeline) Exec(ctx context.Context) error {
+	start := time.Now()
+	err := p.Pipeline.Exec(ctx)
+	p.client.metrics.record(MultiKeyFamily, p.operation, time.Since(start), 0, 0, err)
+	return err
+}
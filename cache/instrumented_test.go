@@ -0,0 +1,90 @@
+package cache_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/brianwu291/go-learn/cache"
+	"github.com/brianwu291/go-learn/cache/memory"
+	"github.com/brianwu291/go-learn/db/redis"
+)
+
+func findStats(stats []cache.OpStats, family, operation string) cache.OpStats {
+	for _, s := range stats {
+		if s.Family == family && s.Operation == operation {
+			return s
+		}
+	}
+	return cache.OpStats{}
+}
+
+func TestInstrumentedClient(t *testing.T) {
+	ctx := context.Background()
+	keys := cache.NewKeyBuilder("go-learn", "test")
+	products := keys.Register(cache.KeyFamily{Name: "product", Version: 1})
+
+	t.Run("Counts hits, misses and latency per family", func(t *testing.T) {
+		metrics := cache.NewMetrics()
+		client := cache.NewInstrumentedClient(memory.NewClient(), keys, metrics)
+
+		require.NoError(t, client.Set(ctx, products.Key("1"), "{}", time.Minute))
+		_, err := client.Get(ctx, products.Key("1"))
+		require.NoError(t, err)
+		_, err = client.Get(ctx, products.Key("2"))
+		assert.True(t, cache.IsKeyNotFound(err))
+		_, err = client.Get(ctx, "other")
+		assert.True(t, cache.IsKeyNotFound(err))
+		_, err = client.MGet(ctx, products.Key("1"), products.Key("3"))
+		require.NoError(t, err)
+
+		stats := metrics.Snapshot()
+		get := findStats(stats, "product", "get")
+		assert.Equal(t, int64(2), get.Calls)
+		assert.Equal(t, int64(1), get.Hits)
+		assert.Equal(t, int64(1), get.Misses)
+		assert.Zero(t, get.ConnectionErrors)
+		assert.Greater(t, get.TotalLatency, time.Duration(0))
+
+		assert.Equal(t, int64(1), findStats(stats, "product", "set").Calls)
+		assert.Equal(t, int64(1), findStats(stats, cache.UnknownFamily, "get").Misses)
+
+		mget := findStats(stats, "product", "mget")
+		assert.Equal(t, int64(1), mget.Hits)
+		assert.Equal(t, int64(1), mget.Misses)
+	})
+
+	t.Run("Tells connection errors apart from misses", func(t *testing.T) {
+		server := miniredis.RunT(t)
+		redisClient, err := redis.NewClient(&cache.Config{Host: server.Host(), Port: server.Port()})
+		require.NoError(t, err)
+		defer redisClient.Close()
+		server.Close()
+
+		metrics := cache.NewMetrics()
+		client := cache.NewInstrumentedClient(redisClient, keys, metrics)
+
+		_, err = client.Get(ctx, products.Key("1"))
+		assert.True(t, cache.IsConnectionError(err))
+
+		get := findStats(metrics.Snapshot(), "product", "get")
+		assert.Equal(t, int64(1), get.Calls)
+		assert.Zero(t, get.Misses)
+		assert.Equal(t, int64(1), get.ConnectionErrors)
+	})
+
+	t.Run("Keeps script emulation working through the wrapper", func(t *testing.T) {
+		metrics := cache.NewMetrics()
+		locker := cache.NewLocker(cache.NewInstrumentedClient(memory.NewClient(), keys, metrics))
+
+		lock, err := locker.Acquire(ctx, "job", cache.LockOptions{TTL: time.Second})
+		require.NoError(t, err)
+		require.NoError(t, lock.Release(ctx))
+
+		assert.Equal(t, int64(2), findStats(metrics.Snapshot(), cache.UnknownFamily, "eval").Calls)
+	})
+}
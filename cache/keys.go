@@ -0,0 +1,139 @@
+package cache
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+type (
+	// KeyBuilder builds keys as namespace:env:family:v<version>:parts and
+	// keeps a registry of every family in use
+	KeyBuilder struct {
+		namespace string
+		env       string
+
+		mu       sync.RWMutex
+		families map[string]KeyFamily
+	}
+
+	// KeyFamily is a group of keys sharing one shape of cached value.
+	// Bump Version whenever the cached type changes shape, old entries then
+	// become unreachable and simply expire.
+	KeyFamily struct {
+		Name        string
+		Version     int
+		TTL         time.Duration
+		Description string
+		// Protected families hold data with no other source, such as
+		// feature flags, the admin API never purges them
+		Protected bool
+
+		prefix string
+	}
+)
+
+const keySeparator = ":"
+
+func NewKeyBuilder(namespace, env string) *KeyBuilder {
+	return &KeyBuilder{
+		namespace: namespace,
+		env:       env,
+		families:  make(map[string]KeyFamily),
+	}
+}
+
+// Register adds a family to the registry and returns it ready to build
+// keys. Registering the same name and version again returns the existing
+// family, a different version for a known name panics because two shapes
+// would then share one name.
+func (b *KeyBuilder) Register(family KeyFamily) KeyFamily {
+	if family.Name == "" || family.Version <= 0 {
+		panic(fmt.Errorf("%w: key family needs a name and a positive version", ErrInvalidConfig))
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if existing, ok := b.families[family.Name]; ok {
+		if existing.Version != family.Version {
+			panic(fmt.Errorf("%w: key family %s registered with versions %d and %d",
+				ErrInvalidConfig, family.Name, existing.Version, family.Version))
+		}
+		return existing
+	}
+
+	family.prefix = b.join(family.Name, fmt.Sprintf("v%d", family.Version))
+	b.families[family.Name] = family
+	return family
+}
+
+// Families lists the registered families sorted by name
+func (b *KeyBuilder) Families() []KeyFamily {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	families := make([]KeyFamily, 0, len(b.families))
+	for _, family := range b.families {
+		families = append(families, family)
+	}
+	sort.Slice(families, func(i, j int) bool {
+		return families[i].Name < families[j].Name
+	})
+	return families
+}
+
+// Family looks up a registered family by name
+func (b *KeyBuilder) Family(name string) (KeyFamily, bool) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	family, ok := b.families[name]
+	return family, ok
+}
+
+// FamilyOf finds the registered family a key belongs to
+func (b *KeyBuilder) FamilyOf(key string) (KeyFamily, bool) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	for _, family := range b.families {
+		if strings.HasPrefix(key, family.Prefix()) {
+			return family, true
+		}
+	}
+	return KeyFamily{}, false
+}
+
+// Tag builds a namespaced, unversioned tag name for TaggedClient
+func (b *KeyBuilder) Tag(parts ...string) string {
+	return b.join(parts...)
+}
+
+func (b *KeyBuilder) join(parts ...string) string {
+	all := make([]string, 0, len(parts)+2)
+	for _, part := range append([]string{b.namespace, b.env}, parts...) {
+		if part != "" {
+			all = append(all, part)
+		}
+	}
+	return strings.Join(all, keySeparator)
+}
+
+// Key builds a key of the family, e.g. Key("all") or Key("category", "jewelery")
+func (f KeyFamily) Key(id string, parts ...string) string {
+	return f.Prefix() + strings.Join(append([]string{id}, parts...), keySeparator)
+}
+
+// Pattern matches every key of the family, for SCAN or PSUBSCRIBE
+func (f KeyFamily) Pattern() string {
+	return f.Prefix() + "*"
+}
+
+// Prefix is shared by every key of the family and ends with the separator,
+// so v1 never matches v10
+func (f KeyFamily) Prefix() string {
+	return f.prefix + keySeparator
+}
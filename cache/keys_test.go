@@ -0,0 +1,47 @@
+package cache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKeyBuilder(t *testing.T) {
+	keys := NewKeyBuilder("go-learn", "staging")
+
+	products := keys.Register(KeyFamily{Name: "fakeStore:product", Version: 2, TTL: time.Hour})
+	categories := keys.Register(KeyFamily{Name: "fakeStore:categories", Version: 1})
+
+	assert.Equal(t, "go-learn:staging:fakeStore:product:v2:42", products.Key("42"))
+	assert.Equal(t, "go-learn:staging:fakeStore:categories:v1:all", categories.Key("all"))
+	assert.Equal(t, "go-learn:staging:fakeStore:product:v2:*", products.Pattern())
+	assert.Equal(t, "go-learn:staging:fakeStore:category:jewelery", keys.Tag("fakeStore", "category", "jewelery"))
+
+	t.Run("Registering again returns the same family", func(t *testing.T) {
+		again := keys.Register(KeyFamily{Name: "fakeStore:product", Version: 2})
+		assert.Equal(t, products, again)
+	})
+
+	t.Run("Conflicting versions panic", func(t *testing.T) {
+		assert.Panics(t, func() {
+			keys.Register(KeyFamily{Name: "fakeStore:product", Version: 3})
+		})
+	})
+
+	t.Run("Registry lists families by name", func(t *testing.T) {
+		families := keys.Families()
+		assert.Len(t, families, 2)
+		assert.Equal(t, "fakeStore:categories", families[0].Name)
+		assert.Equal(t, "fakeStore:product", families[1].Name)
+
+		family, ok := keys.Family("fakeStore:product")
+		assert.True(t, ok)
+		assert.Equal(t, time.Hour, family.TTL)
+	})
+
+	t.Run("Version bump makes old keys unreachable", func(t *testing.T) {
+		v1 := NewKeyBuilder("go-learn", "staging").Register(KeyFamily{Name: "fakeStore:product", Version: 1})
+		assert.NotEqual(t, v1.Key("42"), products.Key("42"))
+	})
+}
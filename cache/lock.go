@@ -0,0 +1,283 @@
+package cache
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+type (
+	// Locker hands out leases on named locks shared by every process
+	// using the same cache
+	Locker struct {
+		client Client
+	}
+
+	LockOptions struct {
+		// TTL is the lease length, the lock frees itself if the holder dies
+		TTL time.Duration
+		// WaitTimeout bounds how long Acquire retries, zero tries once
+		WaitTimeout time.Duration
+		// RetryInterval is the pause between attempts while waiting
+		RetryInterval time.Duration
+		// AutoRenew extends the lease every TTL/3 until Release
+		AutoRenew bool
+	}
+
+	Lock struct {
+		client Client
+		key    string
+		token  string
+		fence  int64
+		ttl    time.Duration
+
+		stopOnce sync.Once
+		stop     chan struct{}
+		lost     chan struct{}
+		renewed  sync.WaitGroup
+	}
+)
+
+const (
+	lockKeyPrefix = "lock:"
+
+	defaultLockTTL           = 30 * time.Second
+	defaultLockRetryInterval = 100 * time.Millisecond
+	// minLockTTL is the smallest lease redis can hold, PX takes milliseconds
+	minLockTTL = time.Millisecond
+
+	// KEYS[1] lock key, KEYS[2] fence counter, ARGV[1] token, ARGV[2] ttl ms.
+	// Returns the new fencing token, or 0 when the lock is taken.
+	acquireLockScript = `
+    if redis.call('SET', KEYS[1], ARGV[1], 'NX', 'PX', ARGV[2]) then
+        return redis.call('INCR', KEYS[2])
+    end
+    return 0
+  `
+
+	// KEYS[1] lock key, ARGV[1] token. Only the holder may delete.
+	releaseLockScript = `
+    if redis.call('GET', KEYS[1]) == ARGV[1] then
+        return redis.call('DEL', KEYS[1])
+    end
+    return 0
+  `
+
+	// KEYS[1] lock key, ARGV[1] token, ARGV[2] ttl ms
+	renewLockScript = `
+    if redis.call('GET', KEYS[1]) == ARGV[1] then
+        return redis.call('PEXPIRE', KEYS[1], ARGV[2])
+    end
+    return 0
+  `
+)
+
+var (
+	// ErrLockNotAcquired is returned when the lock is still held by
+	// someone else after the wait timeout
+	ErrLockNotAcquired = errors.New("lock not acquired")
+	// ErrLockNotHeld is returned when releasing or renewing a lease that
+	// expired or was taken over
+	ErrLockNotHeld = errors.New("lock not held")
+)
+
+// NewLocker registers Go equivalents of the lock scripts when the client
+// cannot run Lua itself
+func NewLocker(client Client) *Locker {
+	if registrar, ok := client.(ScriptRegistrar); ok {
+		registrar.RegisterScript(acquireLockScript, acquireLockFunc)
+		registrar.RegisterScript(releaseLockScript, releaseLockFunc)
+		registrar.RegisterScript(renewLockScript, renewLockFunc)
+	}
+
+	return &Locker{
+		client: client,
+	}
+}
+
+// Acquire takes the named lock, retrying until opts.WaitTimeout passes
+// or ctx is done
+func (l *Locker) Acquire(ctx context.Context, name string, opts LockOptions) (*Lock, error) {
+	if opts.TTL <= 0 {
+		opts.TTL = defaultLockTTL
+	}
+	if opts.RetryInterval <= 0 {
+		opts.RetryInterval = defaultLockRetryInterval
+	}
+	if opts.TTL < minLockTTL {
+		return nil, fmt.Errorf("%w: lock TTL %s is under %s", ErrInvalidConfig, opts.TTL, minLockTTL)
+	}
+
+	token, err := newLockToken()
+	if err != nil {
+		return nil, err
+	}
+
+	deadline := time.Now().Add(opts.WaitTimeout)
+	for {
+		lock, err := l.tryAcquire(ctx, name, token, opts.TTL)
+		if err != nil || lock != nil {
+			if lock != nil && opts.AutoRenew {
+				lock.startRenewal()
+			}
+			return lock, err
+		}
+
+		if !time.Now().Add(opts.RetryInterval).Before(deadline) {
+			return nil, ErrLockNotAcquired
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(opts.RetryInterval):
+		}
+	}
+}
+
+func (l *Locker) tryAcquire(ctx context.Context, name, token string, ttl time.Duration) (*Lock, error) {
+	key := lockKeyPrefix + "{" + name + "}"
+	result, err := l.client.Eval(ctx, acquireLockScript,
+		[]string{key, key + ":fence"},
+		[]interface{}{token, ttl.Milliseconds()},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("acquire lock %s: %w", name, err)
+	}
+
+	fence, _ := result.(int64)
+	if fence == 0 {
+		return nil, nil
+	}
+
+	return &Lock{
+		client: l.client,
+		key:    key,
+		token:  token,
+		fence:  fence,
+		ttl:    ttl,
+		stop:   make(chan struct{}),
+		lost:   make(chan struct{}),
+	}, nil
+}
+
+// Fence is a number that grows with every acquisition of the same lock.
+// Pass it to downstream writes so they can reject a stale holder.
+func (lk *Lock) Fence() int64 {
+	return lk.fence
+}
+
+// Lost is closed when auto renewal finds the lease taken over or expired
+func (lk *Lock) Lost() <-chan struct{} {
+	return lk.lost
+}
+
+// Refresh extends the lease to ttl from now
+func (lk *Lock) Refresh(ctx context.Context, ttl time.Duration) error {
+	if ttl < minLockTTL {
+		return fmt.Errorf("%w: lock TTL %s is under %s", ErrInvalidConfig, ttl, minLockTTL)
+	}
+	result, err := lk.client.Eval(ctx, renewLockScript,
+		[]string{lk.key},
+		[]interface{}{lk.token, ttl.Milliseconds()},
+	)
+	if err != nil {
+		return fmt.Errorf("renew lock %s: %w", lk.key, err)
+	}
+	if n, _ := result.(int64); n == 0 {
+		return ErrLockNotHeld
+	}
+	return nil
+}
+
+// Release stops auto renewal and frees the lock if it is still ours
+func (lk *Lock) Release(ctx context.Context) error {
+	lk.stopOnce.Do(func() { close(lk.stop) })
+	lk.renewed.Wait()
+
+	result, err := lk.client.Eval(ctx, releaseLockScript,
+		[]string{lk.key},
+		[]interface{}{lk.token},
+	)
+	if err != nil {
+		return fmt.Errorf("release lock %s: %w", lk.key, err)
+	}
+	if n, _ := result.(int64); n == 0 {
+		return ErrLockNotHeld
+	}
+	return nil
+}
+
+func (lk *Lock) startRenewal() {
+	lk.renewed.Add(1)
+	go func() {
+		defer lk.renewed.Done()
+
+		ticker := time.NewTicker(lk.ttl / 3)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-lk.stop:
+				return
+			case <-ticker.C:
+				ctx, cancel := context.WithTimeout(context.Background(), lk.ttl/3)
+				err := lk.Refresh(ctx, lk.ttl)
+				cancel()
+				if errors.Is(err, ErrLockNotHeld) {
+					close(lk.lost)
+					return
+				}
+				if err != nil {
+					// transient failure, the lease is still valid until it
+					// expires so try again on the next tick
+					fmt.Printf("failed to renew lock %s: %+v\n", lk.key, err)
+				}
+			}
+		}
+	}()
+}
+
+func newLockToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("generate lock token: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func acquireLockFunc(ctx context.Context, c Client, keys []string, args []interface{}) (interface{}, error) {
+	ttl := time.Duration(args[1].(int64)) * time.Millisecond
+	ok, err := c.SetNX(ctx, keys[0], args[0], ttl)
+	if err != nil || !ok {
+		return int64(0), err
+	}
+	return c.Incr(ctx, keys[1])
+}
+
+func releaseLockFunc(ctx context.Context, c Client, keys []string, args []interface{}) (interface{}, error) {
+	if !holdsLock(ctx, c, keys[0], args[0]) {
+		return int64(0), nil
+	}
+	return c.Del(ctx, keys[0])
+}
+
+func renewLockFunc(ctx context.Context, c Client, keys []string, args []interface{}) (interface{}, error) {
+	if !holdsLock(ctx, c, keys[0], args[0]) {
+		return int64(0), nil
+	}
+	ttl := time.Duration(args[1].(int64)) * time.Millisecond
+	if err := c.Expire(ctx, keys[0], ttl); err != nil {
+		return nil, err
+	}
+	return int64(1), nil
+}
+
+func holdsLock(ctx context.Context, c Client, key string, token interface{}) bool {
+	current, err := c.Get(ctx, key)
+	return err == nil && current == token
+}
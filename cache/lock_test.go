@@ -0,0 +1,135 @@
+package cache_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/brianwu291/go-learn/cache"
+	"github.com/brianwu291/go-learn/cache/memory"
+	"github.com/brianwu291/go-learn/db/redis"
+)
+
+type lockTestClient struct {
+	client cache.Client
+	// advance lets leases expire, miniredis only moves its clock on demand
+	advance func(d time.Duration)
+}
+
+func lockTestClients(t *testing.T) map[string]lockTestClient {
+	t.Helper()
+
+	server := miniredis.RunT(t)
+	redisClient, err := redis.NewClient(&cache.Config{Host: server.Host(), Port: server.Port()})
+	require.NoError(t, err)
+	t.Cleanup(func() { redisClient.Close() })
+
+	return map[string]lockTestClient{
+		"redis":  {client: redisClient, advance: server.FastForward},
+		"memory": {client: memory.NewClient(), advance: time.Sleep},
+	}
+}
+
+func TestLocker(t *testing.T) {
+	for name, tc := range lockTestClients(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			locker := cache.NewLocker(tc.client)
+
+			t.Run("Excludes a second holder", func(t *testing.T) {
+				first, err := locker.Acquire(ctx, "job", cache.LockOptions{TTL: time.Second})
+				require.NoError(t, err)
+				assert.Equal(t, int64(1), first.Fence())
+
+				_, err = locker.Acquire(ctx, "job", cache.LockOptions{TTL: time.Second})
+				assert.True(t, errors.Is(err, cache.ErrLockNotAcquired))
+
+				require.NoError(t, first.Release(ctx))
+
+				second, err := locker.Acquire(ctx, "job", cache.LockOptions{TTL: time.Second})
+				require.NoError(t, err)
+				assert.Equal(t, int64(2), second.Fence())
+				require.NoError(t, second.Release(ctx))
+			})
+
+			t.Run("Waits for the holder to release", func(t *testing.T) {
+				first, err := locker.Acquire(ctx, "wait", cache.LockOptions{TTL: time.Second})
+				require.NoError(t, err)
+
+				go func() {
+					time.Sleep(50 * time.Millisecond)
+					first.Release(ctx)
+				}()
+
+				second, err := locker.Acquire(ctx, "wait", cache.LockOptions{
+					TTL:           time.Second,
+					WaitTimeout:   time.Second,
+					RetryInterval: 10 * time.Millisecond,
+				})
+				require.NoError(t, err)
+				require.NoError(t, second.Release(ctx))
+			})
+
+			t.Run("Release after takeover does not delete the new lease", func(t *testing.T) {
+				stale, err := locker.Acquire(ctx, "stale", cache.LockOptions{TTL: 50 * time.Millisecond})
+				require.NoError(t, err)
+
+				tc.advance(100 * time.Millisecond)
+				current, err := locker.Acquire(ctx, "stale", cache.LockOptions{TTL: time.Second})
+				require.NoError(t, err)
+
+				assert.True(t, errors.Is(stale.Release(ctx), cache.ErrLockNotHeld))
+				assert.Greater(t, current.Fence(), stale.Fence())
+				require.NoError(t, current.Release(ctx))
+			})
+
+			t.Run("Auto renewal keeps the lease alive", func(t *testing.T) {
+				lock, err := locker.Acquire(ctx, "renew", cache.LockOptions{
+					TTL:       90 * time.Millisecond,
+					AutoRenew: true,
+				})
+				require.NoError(t, err)
+
+				time.Sleep(200 * time.Millisecond)
+				_, err = locker.Acquire(ctx, "renew", cache.LockOptions{TTL: time.Second})
+				assert.True(t, errors.Is(err, cache.ErrLockNotAcquired))
+
+				select {
+				case <-lock.Lost():
+					t.Fatal("lease should not be lost while renewing")
+				default:
+				}
+				require.NoError(t, lock.Release(ctx))
+			})
+
+			t.Run("Rejects leases under a millisecond", func(t *testing.T) {
+				_, err := locker.Acquire(ctx, "short", cache.LockOptions{TTL: time.Microsecond, AutoRenew: true})
+				assert.ErrorIs(t, err, cache.ErrInvalidConfig)
+
+				lock, err := locker.Acquire(ctx, "short", cache.LockOptions{TTL: time.Second})
+				require.NoError(t, err)
+				defer lock.Release(ctx)
+				assert.ErrorIs(t, lock.Refresh(ctx, time.Nanosecond), cache.ErrInvalidConfig)
+			})
+
+			t.Run("Gives up when context is cancelled", func(t *testing.T) {
+				holder, err := locker.Acquire(ctx, "cancel", cache.LockOptions{TTL: time.Second})
+				require.NoError(t, err)
+				defer holder.Release(ctx)
+
+				cancelCtx, cancel := context.WithTimeout(ctx, 30*time.Millisecond)
+				defer cancel()
+				_, err = locker.Acquire(cancelCtx, "cancel", cache.LockOptions{
+					TTL:         time.Second,
+					WaitTimeout: time.Second,
+				})
+				assert.True(t, errors.Is(err, context.DeadlineExceeded))
+			})
+		})
+	}
+}
@@ -0,0 +1,768 @@
+package memory
+
+import (
+	"context"
+	"encoding"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/brianwu291/go-learn/cache"
+)
+
+type (
+	entryKind int
+
+	entry struct {
+		kind      entryKind
+		str       string
+		hash      map[string]string
+		set       map[string]struct{}
+		zset      map[string]float64
+		expiresAt time.Time
+	}
+
+	store struct {
+		mu      sync.Mutex
+		entries map[string]*entry
+		// versions outlive deleted entries so Watch notices deletes too
+		versions map[string]uint64
+		scripts  map[string]cache.ScriptFunc
+		now      func() time.Time
+
+		subscriptions map[*subscription]struct{}
+	}
+
+	// Client is an in-process cache.Client and cache.PubSub for tests and
+	// local runs. Eval only runs scripts registered with RegisterScript.
+	Client struct {
+		store *store
+		// locked is set on the view handed to scripts and transactions,
+		// which already hold the store mutex
+		locked bool
+	}
+
+	Option func(*Client)
+)
+
+const (
+	kindString entryKind = iota
+	kindHash
+	kindSet
+	kindZSet
+)
+
+var (
+	// ErrScriptNotRegistered is returned by Eval for unknown scripts,
+	// wrapped in a cache.ScriptError
+	ErrScriptNotRegistered = errors.New("script not registered with in-memory cache")
+
+	errNotInt = fmt.Errorf("%w: value is not an integer", cache.ErrInvalidValue)
+)
+
+// WithClock replaces time.Now, mainly to control expiry in tests
+func WithClock(now func() time.Time) Option {
+	return func(c *Client) {
+		c.store.now = now
+	}
+}
+
+func NewClient(opts ...Option) *Client {
+	c := &Client{
+		store: &store{
+			entries:  make(map[string]*entry),
+			versions: make(map[string]uint64),
+			scripts:  make(map[string]cache.ScriptFunc),
+			now:      time.Now,
+
+			subscriptions: make(map[*subscription]struct{}),
+		},
+	}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	return c
+}
+
+func (c *Client) lock() func() {
+	if c.locked {
+		return func() {}
+	}
+	c.store.mu.Lock()
+	return c.store.mu.Unlock
+}
+
+func (c *Client) lockedView() *Client {
+	return &Client{store: c.store, locked: true}
+}
+
+// RegisterScript provides the Go equivalent of a Lua script. The function
+// runs atomically, the client it receives must not escape the call.
+func (c *Client) RegisterScript(script string, fn cache.ScriptFunc) {
+	unlock := c.lock()
+	defer unlock()
+	c.store.scripts[script] = fn
+}
+
+func (c *Client) Eval(ctx context.Context, script string, keys []string, args []interface{}) (interface{}, error) {
+	unlock := c.lock()
+	defer unlock()
+
+	fn, ok := c.store.scripts[script]
+	if !ok {
+		return nil, cache.NewScriptError(ErrScriptNotRegistered)
+	}
+	return fn(ctx, c.lockedView(), keys, args)
+}
+
+// ScanKeys supports the * and ? wildcards of redis glob patterns
+func (c *Client) ScanKeys(ctx context.Context, pattern string) ([]string, error) {
+	unlock := c.lock()
+	defer unlock()
+
+	var keys []string
+	for key := range c.store.entries {
+		if c.store.get(key) != nil && matchPattern(pattern, key) {
+			keys = append(keys, key)
+		}
+	}
+	return keys, nil
+}
+
+func (c *Client) Get(ctx context.Context, key string) (string, error) {
+	unlock := c.lock()
+	defer unlock()
+
+	e, err := c.store.lookup(key, kindString)
+	if err != nil {
+		return "", err
+	}
+	if e == nil {
+		return "", cache.NewKeyNotFoundError(key)
+	}
+	return e.str, nil
+}
+
+func (c *Client) Set(ctx context.Context, key string, value interface{}, expiration time.Duration) error {
+	unlock := c.lock()
+	defer unlock()
+
+	c.store.set(key, toString(value), expiration)
+	return nil
+}
+
+func (c *Client) SetNX(ctx context.Context, key string, value interface{}, expiration time.Duration) (bool, error) {
+	unlock := c.lock()
+	defer unlock()
+
+	if c.store.get(key) != nil {
+		return false, nil
+	}
+	c.store.set(key, toString(value), expiration)
+	return true, nil
+}
+
+func (c *Client) Del(ctx context.Context, keys ...string) (int64, error) {
+	unlock := c.lock()
+	defer unlock()
+
+	var n int64
+	for _, key := range keys {
+		if c.store.get(key) != nil {
+			c.store.delete(key)
+			n += 1
+		}
+	}
+	return n, nil
+}
+
+func (c *Client) Exists(ctx context.Context, keys ...string) (int64, error) {
+	unlock := c.lock()
+	defer unlock()
+
+	var n int64
+	for _, key := range keys {
+		if c.store.get(key) != nil {
+			n += 1
+		}
+	}
+	return n, nil
+}
+
+func (c *Client) MGet(ctx context.Context, keys ...string) (map[string]string, error) {
+	unlock := c.lock()
+	defer unlock()
+
+	result := make(map[string]string, len(keys))
+	for _, key := range keys {
+		if e := c.store.get(key); e != nil && e.kind == kindString {
+			result[key] = e.str
+		}
+	}
+	return result, nil
+}
+
+func (c *Client) MSet(ctx context.Context, values map[string]interface{}, expiration time.Duration) error {
+	unlock := c.lock()
+	defer unlock()
+
+	for key, value := range values {
+		c.store.set(key, toString(value), expiration)
+	}
+	return nil
+}
+
+func (c *Client) Incr(ctx context.Context, key string) (int64, error) {
+	unlock := c.lock()
+	defer unlock()
+
+	e, err := c.store.lookup(key, kindString)
+	if err != nil {
+		return 0, err
+	}
+	if e == nil {
+		c.store.set(key, "1", 0)
+		return 1, nil
+	}
+
+	n, err := strconv.ParseInt(e.str, 10, 64)
+	if err != nil {
+		return 0, errNotInt
+	}
+	n += 1
+	e.str = strconv.FormatInt(n, 10)
+	c.store.touch(key)
+	return n, nil
+}
+
+// TTL follows redis: -2 for a missing key, -1 for a key without expiry,
+// otherwise the remaining time rounded to seconds
+func (c *Client) TTL(ctx context.Context, key string) (time.Duration, error) {
+	unlock := c.lock()
+	defer unlock()
+
+	e := c.store.get(key)
+	if e == nil {
+		return time.Duration(-2), nil
+	}
+	if e.expiresAt.IsZero() {
+		return time.Duration(-1), nil
+	}
+	return e.expiresAt.Sub(c.store.now()).Round(time.Second), nil
+}
+
+func (c *Client) Expire(ctx context.Context, key string, expiration time.Duration) error {
+	unlock := c.lock()
+	defer unlock()
+
+	e := c.store.get(key)
+	if e == nil {
+		return nil
+	}
+	if expiration <= 0 {
+		c.store.delete(key)
+		return nil
+	}
+	e.expiresAt = c.store.now().Add(expiration)
+	c.store.touch(key)
+	return nil
+}
+
+func (c *Client) HGet(ctx context.Context, key, field string) (string, error) {
+	unlock := c.lock()
+	defer unlock()
+
+	e, err := c.store.lookup(key, kindHash)
+	if err != nil {
+		return "", err
+	}
+	if e == nil {
+		return "", cache.NewKeyNotFoundError(key)
+	}
+	val, ok := e.hash[field]
+	if !ok {
+		return "", cache.NewKeyNotFoundError(key)
+	}
+	return val, nil
+}
+
+func (c *Client) HGetAll(ctx context.Context, key string) (map[string]string, error) {
+	unlock := c.lock()
+	defer unlock()
+
+	e, err := c.store.lookup(key, kindHash)
+	if err != nil {
+		return nil, err
+	}
+	result := make(map[string]string)
+	if e != nil {
+		for field, val := range e.hash {
+			result[field] = val
+		}
+	}
+	return result, nil
+}
+
+func (c *Client) HSet(ctx context.Context, key string, values map[string]interface{}) (int64, error) {
+	unlock := c.lock()
+	defer unlock()
+
+	e, err := c.store.lookupOrCreate(key, kindHash)
+	if err != nil {
+		return 0, err
+	}
+	var added int64
+	for field, value := range values {
+		if _, ok := e.hash[field]; !ok {
+			added += 1
+		}
+		e.hash[field] = toString(value)
+	}
+	c.store.touch(key)
+	return added, nil
+}
+
+func (c *Client) HDel(ctx context.Context, key string, fields ...string) (int64, error) {
+	unlock := c.lock()
+	defer unlock()
+
+	e, err := c.store.lookup(key, kindHash)
+	if err != nil || e == nil {
+		return 0, err
+	}
+	var removed int64
+	for _, field := range fields {
+		if _, ok := e.hash[field]; ok {
+			delete(e.hash, field)
+			removed += 1
+		}
+	}
+	if len(e.hash) == 0 {
+		c.store.delete(key)
+	} else {
+		c.store.touch(key)
+	}
+	return removed, nil
+}
+
+func (c *Client) HIncrBy(ctx context.Context, key, field string, incr int64) (int64, error) {
+	unlock := c.lock()
+	defer unlock()
+
+	e, err := c.store.lookupOrCreate(key, kindHash)
+	if err != nil {
+		return 0, err
+	}
+	var n int64
+	if current, ok := e.hash[field]; ok {
+		if n, err = strconv.ParseInt(current, 10, 64); err != nil {
+			return 0, errNotInt
+		}
+	}
+	n += incr
+	e.hash[field] = strconv.FormatInt(n, 10)
+	c.store.touch(key)
+	return n, nil
+}
+
+func (c *Client) SAdd(ctx context.Context, key string, members ...string) (int64, error) {
+	unlock := c.lock()
+	defer unlock()
+
+	e, err := c.store.lookupOrCreate(key, kindSet)
+	if err != nil {
+		return 0, err
+	}
+	var added int64
+	for _, member := range members {
+		if _, ok := e.set[member]; !ok {
+			e.set[member] = struct{}{}
+			added += 1
+		}
+	}
+	c.store.touch(key)
+	return added, nil
+}
+
+func (c *Client) SRem(ctx context.Context, key string, members ...string) (int64, error) {
+	unlock := c.lock()
+	defer unlock()
+
+	e, err := c.store.lookup(key, kindSet)
+	if err != nil || e == nil {
+		return 0, err
+	}
+	var removed int64
+	for _, member := range members {
+		if _, ok := e.set[member]; ok {
+			delete(e.set, member)
+			removed += 1
+		}
+	}
+	if len(e.set) == 0 {
+		c.store.delete(key)
+	} else {
+		c.store.touch(key)
+	}
+	return removed, nil
+}
+
+func (c *Client) SMembers(ctx context.Context, key string) ([]string, error) {
+	unlock := c.lock()
+	defer unlock()
+
+	e, err := c.store.lookup(key, kindSet)
+	if err != nil {
+		return nil, err
+	}
+	members := make([]string, 0)
+	if e != nil {
+		for member := range e.set {
+			members = append(members, member)
+		}
+	}
+	sort.Strings(members)
+	return members, nil
+}
+
+func (c *Client) SCard(ctx context.Context, key string) (int64, error) {
+	unlock := c.lock()
+	defer unlock()
+
+	e, err := c.store.lookup(key, kindSet)
+	if err != nil || e == nil {
+		return 0, err
+	}
+	return int64(len(e.set)), nil
+}
+
+func (c *Client) ZAdd(ctx context.Context, key string, members ...cache.ZMember) (int64, error) {
+	unlock := c.lock()
+	defer unlock()
+
+	e, err := c.store.lookupOrCreate(key, kindZSet)
+	if err != nil {
+		return 0, err
+	}
+	var added int64
+	for _, m := range members {
+		if _, ok := e.zset[m.Member]; !ok {
+			added += 1
+		}
+		e.zset[m.Member] = m.Score
+	}
+	c.store.touch(key)
+	return added, nil
+}
+
+func (c *Client) ZRem(ctx context.Context, key string, members ...string) (int64, error) {
+	unlock := c.lock()
+	defer unlock()
+
+	e, err := c.store.lookup(key, kindZSet)
+	if err != nil || e == nil {
+		return 0, err
+	}
+	var removed int64
+	for _, member := range members {
+		if _, ok := e.zset[member]; ok {
+			delete(e.zset, member)
+			removed += 1
+		}
+	}
+	c.store.afterZSetRemove(key, e)
+	return removed, nil
+}
+
+func (c *Client) ZScore(ctx context.Context, key, member string) (float64, error) {
+	unlock := c.lock()
+	defer unlock()
+
+	e, err := c.store.lookup(key, kindZSet)
+	if err != nil {
+		return 0, err
+	}
+	if e == nil {
+		return 0, cache.NewKeyNotFoundError(key)
+	}
+	score, ok := e.zset[member]
+	if !ok {
+		return 0, cache.NewKeyNotFoundError(key)
+	}
+	return score, nil
+}
+
+func (c *Client) ZCard(ctx context.Context, key string) (int64, error) {
+	unlock := c.lock()
+	defer unlock()
+
+	e, err := c.store.lookup(key, kindZSet)
+	if err != nil || e == nil {
+		return 0, err
+	}
+	return int64(len(e.zset)), nil
+}
+
+func (c *Client) ZRange(ctx context.Context, key string, start, stop int64) ([]cache.ZMember, error) {
+	unlock := c.lock()
+	defer unlock()
+
+	e, err := c.store.lookup(key, kindZSet)
+	if err != nil || e == nil {
+		return []cache.ZMember{}, err
+	}
+
+	members := sortedMembers(e.zset)
+	size := int64(len(members))
+	if start < 0 {
+		start = max(size+start, 0)
+	}
+	if stop < 0 {
+		stop = size + stop
+	}
+	if stop >= size {
+		stop = size - 1
+	}
+	if start > stop {
+		return []cache.ZMember{}, nil
+	}
+	return members[start : stop+1], nil
+}
+
+func (c *Client) ZRangeByScore(ctx context.Context, key string, by cache.ZRangeBy) ([]cache.ZMember, error) {
+	unlock := c.lock()
+	defer unlock()
+
+	e, err := c.store.lookup(key, kindZSet)
+	if err != nil || e == nil {
+		return []cache.ZMember{}, err
+	}
+
+	inRange, err := scoreRange(by.Min, by.Max)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]cache.ZMember, 0)
+	var skipped int64
+	for _, m := range sortedMembers(e.zset) {
+		if !inRange(m.Score) {
+			continue
+		}
+		if skipped < by.Offset {
+			skipped += 1
+			continue
+		}
+		if by.Count > 0 && int64(len(result)) >= by.Count {
+			break
+		}
+		result = append(result, m)
+	}
+	return result, nil
+}
+
+func (c *Client) ZRemRangeByScore(ctx context.Context, key, min, max string) (int64, error) {
+	unlock := c.lock()
+	defer unlock()
+
+	e, err := c.store.lookup(key, kindZSet)
+	if err != nil || e == nil {
+		return 0, err
+	}
+
+	inRange, err := scoreRange(min, max)
+	if err != nil {
+		return 0, err
+	}
+
+	var removed int64
+	for member, score := range e.zset {
+		if inRange(score) {
+			delete(e.zset, member)
+			removed += 1
+		}
+	}
+	c.store.afterZSetRemove(key, e)
+	return removed, nil
+}
+
+// get returns the live entry or nil, dropping it when expired
+func (s *store) get(key string) *entry {
+	e, ok := s.entries[key]
+	if !ok {
+		return nil
+	}
+	if !e.expiresAt.IsZero() && !s.now().Before(e.expiresAt) {
+		s.delete(key)
+		return nil
+	}
+	return e
+}
+
+func (s *store) lookup(key string, kind entryKind) (*entry, error) {
+	e := s.get(key)
+	if e != nil && e.kind != kind {
+		return nil, cache.NewWrongTypeError(key)
+	}
+	return e, nil
+}
+
+func (s *store) lookupOrCreate(key string, kind entryKind) (*entry, error) {
+	e, err := s.lookup(key, kind)
+	if err != nil || e != nil {
+		return e, err
+	}
+
+	e = &entry{kind: kind}
+	switch kind {
+	case kindHash:
+		e.hash = make(map[string]string)
+	case kindSet:
+		e.set = make(map[string]struct{})
+	case kindZSet:
+		e.zset = make(map[string]float64)
+	}
+	s.entries[key] = e
+	return e, nil
+}
+
+func (s *store) set(key, value string, expiration time.Duration) {
+	e := &entry{kind: kindString, str: value}
+	if expiration > 0 {
+		e.expiresAt = s.now().Add(expiration)
+	}
+	s.entries[key] = e
+	s.touch(key)
+}
+
+func (s *store) delete(key string) {
+	delete(s.entries, key)
+	s.touch(key)
+}
+
+func (s *store) touch(key string) {
+	s.versions[key] += 1
+}
+
+func (s *store) afterZSetRemove(key string, e *entry) {
+	if len(e.zset) == 0 {
+		s.delete(key)
+		return
+	}
+	s.touch(key)
+}
+
+func sortedMembers(zset map[string]float64) []cache.ZMember {
+	members := make([]cache.ZMember, 0, len(zset))
+	for member, score := range zset {
+		members = append(members, cache.ZMember{Member: member, Score: score})
+	}
+	sort.Slice(members, func(i, j int) bool {
+		if members[i].Score == members[j].Score {
+			return members[i].Member < members[j].Member
+		}
+		return members[i].Score < members[j].Score
+	})
+	return members
+}
+
+// scoreRange parses redis style bounds such as "-inf", "+inf" and "(1.5"
+func scoreRange(min, max string) (func(float64) bool, error) {
+	minVal, minExclusive, err := parseScoreBound(min)
+	if err != nil {
+		return nil, err
+	}
+	maxVal, maxExclusive, err := parseScoreBound(max)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(score float64) bool {
+		if score < minVal || (minExclusive && score == minVal) {
+			return false
+		}
+		if score > maxVal || (maxExclusive && score == maxVal) {
+			return false
+		}
+		return true
+	}, nil
+}
+
+func parseScoreBound(bound string) (float64, bool, error) {
+	exclusive := strings.HasPrefix(bound, "(")
+	bound = strings.TrimPrefix(bound, "(")
+
+	switch bound {
+	case "-inf":
+		return math.Inf(-1), exclusive, nil
+	case "+inf", "inf":
+		return math.Inf(1), exclusive, nil
+	}
+
+	val, err := strconv.ParseFloat(bound, 64)
+	if err != nil {
+		return 0, false, fmt.Errorf("%w: min or max is not a float", cache.ErrInvalidValue)
+	}
+	return val, exclusive, nil
+}
+
+func matchPattern(pattern, key string) bool {
+	for pattern != "" {
+		switch pattern[0] {
+		case '*':
+			for i := len(key); i >= 0; i-- {
+				if matchPattern(pattern[1:], key[i:]) {
+					return true
+				}
+			}
+			return false
+		case '?':
+			if key == "" {
+				return false
+			}
+		default:
+			if key == "" || key[0] != pattern[0] {
+				return false
+			}
+		}
+		pattern, key = pattern[1:], key[1:]
+	}
+	return key == ""
+}
+
+// toString mirrors how go-redis writes argument values
+func toString(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case []byte:
+		return string(v)
+	case int:
+		return strconv.Itoa(v)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		if v {
+			return "1"
+		}
+		return "0"
+	case time.Duration:
+		return strconv.FormatInt(int64(v), 10)
+	case encoding.BinaryMarshaler:
+		if b, err := v.MarshalBinary(); err == nil {
+			return string(b)
+		}
+	}
+	return fmt.Sprint(value)
+}
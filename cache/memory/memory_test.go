@@ -0,0 +1,80 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/brianwu291/go-learn/cache"
+)
+
+func TestClient_Expiry(t *testing.T) {
+	now := time.Now()
+	client := NewClient(WithClock(func() time.Time { return now }))
+	ctx := context.Background()
+
+	require.NoError(t, client.Set(ctx, "key", "value", time.Minute))
+
+	ttl, err := client.TTL(ctx, "key")
+	require.NoError(t, err)
+	assert.Equal(t, time.Minute, ttl)
+
+	now = now.Add(time.Minute)
+	_, err = client.Get(ctx, "key")
+	assert.True(t, cache.IsKeyNotFound(err))
+
+	ttl, _ = client.TTL(ctx, "key")
+	assert.Equal(t, time.Duration(-2), ttl)
+}
+
+func TestClient_WrongType(t *testing.T) {
+	client := NewClient()
+	ctx := context.Background()
+
+	_, err := client.HSet(ctx, "hash", map[string]interface{}{"a": 1})
+	require.NoError(t, err)
+
+	_, err = client.Get(ctx, "hash")
+	assert.True(t, cache.IsWrongType(err))
+	assert.True(t, errors.Is(err, cache.ErrInvalidValue))
+
+	_, err = client.Incr(ctx, "hash")
+	assert.True(t, errors.Is(err, cache.ErrInvalidValue))
+}
+
+func TestClient_Watch(t *testing.T) {
+	client := NewClient()
+	ctx := context.Background()
+
+	err := client.Watch(ctx, func(tx cache.Tx) error {
+		require.NoError(t, client.Set(ctx, "watched", "changed", 0))
+		return tx.TxPipelined(ctx, func(pipe cache.Pipeline) error {
+			pipe.Set(ctx, "watched", "mine", 0)
+			return nil
+		})
+	}, "watched")
+	assert.True(t, errors.Is(err, cache.ErrTxFailed))
+
+	val, _ := client.Get(ctx, "watched")
+	assert.Equal(t, "changed", val)
+}
+
+func TestClient_Eval(t *testing.T) {
+	client := NewClient()
+	ctx := context.Background()
+
+	_, err := client.Eval(ctx, "return 1", nil, nil)
+	assert.True(t, errors.Is(err, ErrScriptNotRegistered))
+	assert.True(t, cache.IsScriptError(err))
+
+	client.RegisterScript("return INCR", func(ctx context.Context, c cache.Client, keys []string, args []interface{}) (interface{}, error) {
+		return c.Incr(ctx, keys[0])
+	})
+	result, err := client.Eval(ctx, "return INCR", []string{"counter"}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), result)
+}
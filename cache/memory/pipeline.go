@@ -0,0 +1,282 @@
+package memory
+
+import (
+	"context"
+	"time"
+
+	"github.com/brianwu291/go-learn/cache"
+)
+
+type (
+	// Pipeline runs its queued commands under one lock, so a plain
+	// pipeline is as atomic as a MULTI/EXEC one here
+	Pipeline struct {
+		client *Client
+		ops    []func(ctx context.Context, c *Client) error
+		// check runs before the queued commands, Watch uses it to abort
+		// when a watched key changed
+		check func() error
+	}
+
+	tx struct {
+		client  *Client
+		watched map[string]uint64
+	}
+
+	intCmd struct {
+		val int64
+		err error
+	}
+
+	durationCmd struct {
+		val time.Duration
+		err error
+	}
+
+	stringCmd struct {
+		val string
+		err error
+	}
+
+	boolCmd struct {
+		val bool
+		err error
+	}
+
+	mapCmd struct {
+		val map[string]string
+		err error
+	}
+
+	statusCmd struct {
+		err error
+	}
+)
+
+func (c *Client) Pipeline() cache.Pipeline {
+	return &Pipeline{client: c}
+}
+
+func (c *Client) TxPipeline() cache.Pipeline {
+	return &Pipeline{client: c}
+}
+
+func (c *Client) Watch(ctx context.Context, fn cache.TxFunc, keys ...string) error {
+	unlock := c.lock()
+	watched := make(map[string]uint64, len(keys))
+	for _, key := range keys {
+		// expire first so a lapsed key counts as a change
+		c.store.get(key)
+		watched[key] = c.store.versions[key]
+	}
+	unlock()
+
+	return fn(&tx{client: c, watched: watched})
+}
+
+func (p *Pipeline) Get(ctx context.Context, key string) cache.PipelineStringCmd {
+	cmd := &stringCmd{}
+	p.ops = append(p.ops, func(ctx context.Context, c *Client) error {
+		cmd.val, cmd.err = c.Get(ctx, key)
+		return cmd.err
+	})
+	return cmd
+}
+
+func (p *Pipeline) Set(ctx context.Context, key string, value interface{}, expiration time.Duration) cache.PipelineStatusCmd {
+	cmd := &statusCmd{}
+	p.ops = append(p.ops, func(ctx context.Context, c *Client) error {
+		cmd.err = c.Set(ctx, key, value, expiration)
+		return cmd.err
+	})
+	return cmd
+}
+
+func (p *Pipeline) Del(ctx context.Context, keys ...string) cache.PipelineCmd {
+	cmd := &intCmd{}
+	p.ops = append(p.ops, func(ctx context.Context, c *Client) error {
+		cmd.val, cmd.err = c.Del(ctx, keys...)
+		return cmd.err
+	})
+	return cmd
+}
+
+func (p *Pipeline) Incr(ctx context.Context, key string) cache.PipelineCmd {
+	cmd := &intCmd{}
+	p.ops = append(p.ops, func(ctx context.Context, c *Client) error {
+		cmd.val, cmd.err = c.Incr(ctx, key)
+		return cmd.err
+	})
+	return cmd
+}
+
+func (p *Pipeline) TTL(ctx context.Context, key string) cache.PipelineDurationCmd {
+	cmd := &durationCmd{}
+	p.ops = append(p.ops, func(ctx context.Context, c *Client) error {
+		cmd.val, cmd.err = c.TTL(ctx, key)
+		return cmd.err
+	})
+	return cmd
+}
+
+func (p *Pipeline) Expire(ctx context.Context, key string, expiration time.Duration) cache.PipelineBoolCmd {
+	cmd := &boolCmd{}
+	p.ops = append(p.ops, func(ctx context.Context, c *Client) error {
+		cmd.val = c.store.get(key) != nil
+		cmd.err = c.Expire(ctx, key, expiration)
+		return cmd.err
+	})
+	return cmd
+}
+
+func (p *Pipeline) HGet(ctx context.Context, key, field string) cache.PipelineStringCmd {
+	cmd := &stringCmd{}
+	p.ops = append(p.ops, func(ctx context.Context, c *Client) error {
+		cmd.val, cmd.err = c.HGet(ctx, key, field)
+		return cmd.err
+	})
+	return cmd
+}
+
+func (p *Pipeline) HGetAll(ctx context.Context, key string) cache.PipelineMapCmd {
+	cmd := &mapCmd{}
+	p.ops = append(p.ops, func(ctx context.Context, c *Client) error {
+		cmd.val, cmd.err = c.HGetAll(ctx, key)
+		return cmd.err
+	})
+	return cmd
+}
+
+func (p *Pipeline) HSet(ctx context.Context, key string, values map[string]interface{}) cache.PipelineCmd {
+	cmd := &intCmd{}
+	p.ops = append(p.ops, func(ctx context.Context, c *Client) error {
+		cmd.val, cmd.err = c.HSet(ctx, key, values)
+		return cmd.err
+	})
+	return cmd
+}
+
+func (p *Pipeline) HDel(ctx context.Context, key string, fields ...string) cache.PipelineCmd {
+	cmd := &intCmd{}
+	p.ops = append(p.ops, func(ctx context.Context, c *Client) error {
+		cmd.val, cmd.err = c.HDel(ctx, key, fields...)
+		return cmd.err
+	})
+	return cmd
+}
+
+func (p *Pipeline) HIncrBy(ctx context.Context, key, field string, incr int64) cache.PipelineCmd {
+	cmd := &intCmd{}
+	p.ops = append(p.ops, func(ctx context.Context, c *Client) error {
+		cmd.val, cmd.err = c.HIncrBy(ctx, key, field, incr)
+		return cmd.err
+	})
+	return cmd
+}
+
+func (p *Pipeline) Len() int {
+	return len(p.ops)
+}
+
+func (p *Pipeline) Exec(ctx context.Context) error {
+	ops := p.ops
+	p.ops = nil
+
+	unlock := p.client.lock()
+	defer unlock()
+
+	if p.check != nil {
+		if err := p.check(); err != nil {
+			return err
+		}
+	}
+
+	var firstErr error
+	view := p.client.lockedView()
+	for _, op := range ops {
+		if err := op(ctx, view); err != nil && firstErr == nil && !cache.IsKeyNotFound(err) {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+func (p *Pipeline) Discard() {
+	p.ops = nil
+}
+
+func (t *tx) Get(ctx context.Context, key string) (string, error) {
+	return t.client.Get(ctx, key)
+}
+
+func (t *tx) HGetAll(ctx context.Context, key string) (map[string]string, error) {
+	return t.client.HGetAll(ctx, key)
+}
+
+func (t *tx) TTL(ctx context.Context, key string) (time.Duration, error) {
+	return t.client.TTL(ctx, key)
+}
+
+func (t *tx) TxPipelined(ctx context.Context, fn func(pipe cache.Pipeline) error) error {
+	pipe := &Pipeline{
+		client: t.client,
+		check: func() error {
+			for key, version := range t.watched {
+				t.client.store.get(key)
+				if t.client.store.versions[key] != version {
+					return cache.ErrTxFailed
+				}
+			}
+			return nil
+		},
+	}
+	if err := fn(pipe); err != nil {
+		pipe.Discard()
+		return err
+	}
+	return pipe.Exec(ctx)
+}
+
+func (c *intCmd) Val() int64 {
+	return c.val
+}
+
+func (c *intCmd) Err() error {
+	return c.err
+}
+
+func (c *durationCmd) Val() time.Duration {
+	return c.val
+}
+
+func (c *durationCmd) Err() error {
+	return c.err
+}
+
+func (c *stringCmd) Val() string {
+	return c.val
+}
+
+func (c *stringCmd) Err() error {
+	return c.err
+}
+
+func (c *boolCmd) Val() bool {
+	return c.val
+}
+
+func (c *boolCmd) Err() error {
+	return c.err
+}
+
+func (c *mapCmd) Val() map[string]string {
+	return c.val
+}
+
+func (c *mapCmd) Err() error {
+	return c.err
+}
+
+func (c *statusCmd) Err() error {
+	return c.err
+}
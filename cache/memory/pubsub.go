@@ -0,0 +1,117 @@
+package memory
+
+import (
+	"context"
+	"sync"
+
+	"github.com/brianwu291/go-learn/cache"
+)
+
+type (
+	subscription struct {
+		store    *store
+		channels map[string]struct{}
+		patterns []string
+		messages chan cache.Message
+		done     chan struct{}
+		once     sync.Once
+	}
+)
+
+const subscriptionBufferSize = 100
+
+// Publish delivers to subscribers without blocking. A subscriber whose
+// buffer is full misses the message, like a slow Redis client would.
+func (c *Client) Publish(ctx context.Context, channel string, message interface{}) (int64, error) {
+	payload, err := cache.EncodePayload(message)
+	if err != nil {
+		return 0, err
+	}
+
+	unlock := c.lock()
+	defer unlock()
+
+	var received int64
+	for sub := range c.store.subscriptions {
+		msg, ok := sub.match(channel)
+		if !ok {
+			continue
+		}
+		msg.Payload = payload
+		select {
+		case sub.messages <- msg:
+			received += 1
+		default:
+		}
+	}
+	return received, nil
+}
+
+func (c *Client) Subscribe(ctx context.Context, channels ...string) (cache.Subscription, error) {
+	sub := c.newSubscription()
+	for _, channel := range channels {
+		sub.channels[channel] = struct{}{}
+	}
+	return c.addSubscription(ctx, sub), nil
+}
+
+// PSubscribe matches like Redis glob patterns, "*" crosses any character
+func (c *Client) PSubscribe(ctx context.Context, patterns ...string) (cache.Subscription, error) {
+	sub := c.newSubscription()
+	sub.patterns = patterns
+	return c.addSubscription(ctx, sub), nil
+}
+
+func (c *Client) newSubscription() *subscription {
+	return &subscription{
+		store:    c.store,
+		channels: make(map[string]struct{}),
+		messages: make(chan cache.Message, subscriptionBufferSize),
+		done:     make(chan struct{}),
+	}
+}
+
+func (c *Client) addSubscription(ctx context.Context, sub *subscription) *subscription {
+	unlock := c.lock()
+	c.store.subscriptions[sub] = struct{}{}
+	unlock()
+
+	go func() {
+		select {
+		case <-ctx.Done():
+			sub.Close()
+		case <-sub.done:
+		}
+	}()
+
+	return sub
+}
+
+func (s *subscription) match(channel string) (cache.Message, bool) {
+	if _, ok := s.channels[channel]; ok {
+		return cache.Message{Channel: channel}, true
+	}
+	for _, pattern := range s.patterns {
+		if matchPattern(pattern, channel) {
+			return cache.Message{Channel: channel, Pattern: pattern}, true
+		}
+	}
+	return cache.Message{}, false
+}
+
+func (s *subscription) Messages() <-chan cache.Message {
+	return s.messages
+}
+
+// Close unregisters under the store lock, so Publish never sends on a
+// closed channel
+func (s *subscription) Close() error {
+	s.once.Do(func() {
+		s.store.mu.Lock()
+		delete(s.store.subscriptions, s)
+		close(s.messages)
+		s.store.mu.Unlock()
+		close(s.done)
+	})
+	return nil
+}
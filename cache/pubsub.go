@@ -0,0 +1,56 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+type (
+	Message struct {
+		Channel string
+		// Pattern is the matched pattern for PSubscribe, empty otherwise
+		Pattern string
+		Payload string
+	}
+
+	// Subscription delivers messages until Close is called or the context
+	// given to Subscribe is done, after which Messages is closed
+	Subscription interface {
+		Messages() <-chan Message
+		Close() error
+	}
+
+	PubSub interface {
+		// Publish returns the number of subscribers that received the message.
+		// Non string values are sent as JSON.
+		Publish(ctx context.Context, channel string, message interface{}) (int64, error)
+		Subscribe(ctx context.Context, channels ...string) (Subscription, error)
+		// PSubscribe subscribes to glob patterns such as "catalog:*"
+		PSubscribe(ctx context.Context, patterns ...string) (Subscription, error)
+	}
+)
+
+// Decode unmarshals a JSON payload into v
+func (m Message) Decode(v interface{}) error {
+	if err := json.Unmarshal([]byte(m.Payload), v); err != nil {
+		return fmt.Errorf("%w: decoding message on %s: %v", ErrInvalidValue, m.Channel, err)
+	}
+	return nil
+}
+
+// EncodePayload turns a publish argument into the wire payload
+func EncodePayload(message interface{}) (string, error) {
+	switch v := message.(type) {
+	case string:
+		return v, nil
+	case []byte:
+		return string(v), nil
+	}
+
+	b, err := json.Marshal(message)
+	if err != nil {
+		return "", fmt.Errorf("%w: encoding message: %v", ErrInvalidValue, err)
+	}
+	return string(b), nil
+}
@@ -0,0 +1,113 @@
+package cache_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/brianwu291/go-learn/cache"
+	"github.com/brianwu291/go-learn/cache/memory"
+	"github.com/brianwu291/go-learn/db/redis"
+)
+
+func pubSubTestClients(t *testing.T) map[string]cache.PubSub {
+	t.Helper()
+
+	server := miniredis.RunT(t)
+	redisClient, err := redis.NewClient(&cache.Config{Host: server.Host(), Port: server.Port()})
+	require.NoError(t, err)
+	t.Cleanup(func() { redisClient.Close() })
+
+	return map[string]cache.PubSub{
+		"redis":  redisClient,
+		"memory": memory.NewClient(),
+	}
+}
+
+func receive(t *testing.T, sub cache.Subscription) cache.Message {
+	t.Helper()
+
+	select {
+	case msg, ok := <-sub.Messages():
+		require.True(t, ok, "subscription closed")
+		return msg
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for message")
+	}
+	return cache.Message{}
+}
+
+func TestPubSub(t *testing.T) {
+	type productUpdated struct {
+		ID    int64   `json:"id"`
+		Price float64 `json:"price"`
+	}
+
+	for name, ps := range pubSubTestClients(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+
+			t.Run("Delivers typed messages", func(t *testing.T) {
+				sub, err := ps.Subscribe(ctx, "products")
+				require.NoError(t, err)
+				defer sub.Close()
+
+				n, err := ps.Publish(ctx, "products", productUpdated{ID: 1, Price: 9.5})
+				require.NoError(t, err)
+				assert.Equal(t, int64(1), n)
+
+				msg := receive(t, sub)
+				assert.Equal(t, "products", msg.Channel)
+
+				var event productUpdated
+				require.NoError(t, msg.Decode(&event))
+				assert.Equal(t, productUpdated{ID: 1, Price: 9.5}, event)
+			})
+
+			t.Run("Matches patterns", func(t *testing.T) {
+				sub, err := ps.PSubscribe(ctx, "catalog:*")
+				require.NoError(t, err)
+				defer sub.Close()
+
+				_, err = ps.Publish(ctx, "catalog:electronics", "invalidate")
+				require.NoError(t, err)
+
+				msg := receive(t, sub)
+				assert.Equal(t, "catalog:electronics", msg.Channel)
+				assert.Equal(t, "catalog:*", msg.Pattern)
+				assert.Equal(t, "invalidate", msg.Payload)
+			})
+
+			t.Run("Matches channels with a slash", func(t *testing.T) {
+				sub, err := ps.PSubscribe(ctx, "catalog:*")
+				require.NoError(t, err)
+				defer sub.Close()
+
+				_, err = ps.Publish(ctx, "catalog:men's/shoes", "invalidate")
+				require.NoError(t, err)
+
+				msg := receive(t, sub)
+				assert.Equal(t, "catalog:men's/shoes", msg.Channel)
+				assert.Equal(t, "catalog:*", msg.Pattern)
+			})
+
+			t.Run("Closes on context cancellation", func(t *testing.T) {
+				subCtx, cancel := context.WithCancel(ctx)
+				sub, err := ps.Subscribe(subCtx, "shutdown")
+				require.NoError(t, err)
+
+				cancel()
+				select {
+				case _, ok := <-sub.Messages():
+					assert.False(t, ok)
+				case <-time.After(time.Second):
+					t.Fatal("subscription not closed after cancel")
+				}
+			})
+		})
+	}
+}
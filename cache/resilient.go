@@ -0,0 +1,374 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	circuitbreaker "github.com/brianwu291/go-learn/circuitbreaker"
+)
+
+type (
+	ResilienceConfig struct {
+		// Timeout bounds every operation that has no override
+		Timeout time.Duration
+		// OperationTimeouts overrides Timeout per operation name, e.g. "eval"
+		OperationTimeouts map[string]time.Duration
+		Breaker           circuitbreaker.Config
+	}
+
+	// ResilientClient bounds every call with a timeout and stops calling a
+	// failing cache for a while. Rejected calls return a ConnectionError
+	// wrapping circuitbreaker.ErrOpen, so callers that already treat
+	// connection errors as a miss need no change.
+	ResilientClient struct {
+		client  Client
+		config  ResilienceConfig
+		breaker *circuitbreaker.Breaker
+	}
+
+	resilientPipeline struct {
+		Pipeline
+		client    *ResilientClient
+		operation string
+	}
+)
+
+const defaultOperationTimeout = 500 * time.Millisecond
+
+func NewResilientClient(client Client, config ResilienceConfig) *ResilientClient {
+	if config.Timeout <= 0 {
+		config.Timeout = defaultOperationTimeout
+	}
+
+	return &ResilientClient{
+		client:  client,
+		config:  config,
+		breaker: circuitbreaker.New(config.Breaker),
+	}
+}
+
+// State exposes the breaker state for health checks
+func (c *ResilientClient) State() circuitbreaker.State {
+	return c.breaker.State()
+}
+
+func (c *ResilientClient) timeout(operation string) time.Duration {
+	if timeout, ok := c.config.OperationTimeouts[operation]; ok {
+		return timeout
+	}
+	return c.config.Timeout
+}
+
+// do runs fn through the breaker. Only connection errors and timeouts
+// count as failures, a miss or a bad value means the cache is healthy.
+func (c *ResilientClient) do(ctx context.Context, operation string, fn func(ctx context.Context) error) error {
+	done, err := c.breaker.Allow()
+	if err != nil {
+		return NewConnectionError(err)
+	}
+
+	opCtx, cancel := context.WithTimeout(ctx, c.timeout(operation))
+	defer cancel()
+
+	err = fn(opCtx)
+
+	switch {
+	case err == nil:
+		done(circuitbreaker.Success)
+	case ctx.Err() != nil:
+		// the caller gave up, that says nothing about the cache
+		done(circuitbreaker.Ignored)
+	case IsConnectionError(err):
+		done(circuitbreaker.Failure)
+	case errors.Is(err, context.DeadlineExceeded):
+		done(circuitbreaker.Failure)
+		err = NewTimeoutError(err)
+	case isCommandError(err):
+		// the cache answered, the command itself was wrong
+		done(circuitbreaker.Success)
+	default:
+		// an error no client classified could be a broken link as well
+		done(circuitbreaker.Failure)
+	}
+	return err
+}
+
+// isCommandError is true for errors the cache replied with, misses, wrong
+// types, bad values or scripts, which say nothing about its health
+func isCommandError(err error) bool {
+	var cacheErr CacheError
+	if errors.As(err, &cacheErr) {
+		return true
+	}
+	return errors.Is(err, ErrKeyNotFound) || errors.Is(err, ErrInvalidValue) ||
+		errors.Is(err, ErrTxFailed) || errors.Is(err, ErrUnsupported)
+}
+
+// RegisterScript forwards to clients that emulate Eval
+func (c *ResilientClient) RegisterScript(script string, fn ScriptFunc) {
+	if registrar, ok := c.client.(ScriptRegistrar); ok {
+		registrar.RegisterScript(script, fn)
+	}
+}
+
+// IsCluster forwards to the wrapped client
+func (c *ResilientClient) IsCluster() bool {
+	return IsCluster(c.client)
+}
+
+// ScanKeys forwards to clients that can scan, under the "scan" timeout
+func (c *ResilientClient) ScanKeys(ctx context.Context, pattern string) (keys []string, err error) {
+	err = c.do(ctx, "scan", func(ctx context.Context) error {
+		keys, err = ScanKeys(ctx, c.client, pattern)
+		return err
+	})
+	return keys, err
+}
+
+func (c *ResilientClient) Get(ctx context.Context, key string) (val string, err error) {
+	err = c.do(ctx, "get", func(ctx context.Context) error {
+		val, err = c.client.Get(ctx, key)
+		return err
+	})
+	return val, err
+}
+
+func (c *ResilientClient) Set(ctx context.Context, key string, value interface{}, expiration time.Duration) error {
+	return c.do(ctx, "set", func(ctx context.Context) error {
+		return c.client.Set(ctx, key, value, expiration)
+	})
+}
+
+func (c *ResilientClient) SetNX(ctx context.Context, key string, value interface{}, expiration time.Duration) (ok bool, err error) {
+	err = c.do(ctx, "setnx", func(ctx context.Context) error {
+		ok, err = c.client.SetNX(ctx, key, value, expiration)
+		return err
+	})
+	return ok, err
+}
+
+func (c *ResilientClient) Del(ctx context.Context, keys ...string) (n int64, err error) {
+	err = c.do(ctx, "del", func(ctx context.Context) error {
+		n, err = c.client.Del(ctx, keys...)
+		return err
+	})
+	return n, err
+}
+
+func (c *ResilientClient) Exists(ctx context.Context, keys ...string) (n int64, err error) {
+	err = c.do(ctx, "exists", func(ctx context.Context) error {
+		n, err = c.client.Exists(ctx, keys...)
+		return err
+	})
+	return n, err
+}
+
+func (c *ResilientClient) MGet(ctx context.Context, keys ...string) (vals map[string]string, err error) {
+	err = c.do(ctx, "mget", func(ctx context.Context) error {
+		vals, err = c.client.MGet(ctx, keys...)
+		return err
+	})
+	return vals, err
+}
+
+func (c *ResilientClient) MSet(ctx context.Context, values map[string]interface{}, expiration time.Duration) error {
+	return c.do(ctx, "mset", func(ctx context.Context) error {
+		return c.client.MSet(ctx, values, expiration)
+	})
+}
+
+func (c *ResilientClient) Incr(ctx context.Context, key string) (n int64, err error) {
+	err = c.do(ctx, "incr", func(ctx context.Context) error {
+		n, err = c.client.Incr(ctx, key)
+		return err
+	})
+	return n, err
+}
+
+func (c *ResilientClient) TTL(ctx context.Context, key string) (ttl time.Duration, err error) {
+	err = c.do(ctx, "ttl", func(ctx context.Context) error {
+		ttl, err = c.client.TTL(ctx, key)
+		return err
+	})
+	return ttl, err
+}
+
+func (c *ResilientClient) Expire(ctx context.Context, key string, expiration time.Duration) error {
+	return c.do(ctx, "expire", func(ctx context.Context) error {
+		return c.client.Expire(ctx, key, expiration)
+	})
+}
+
+func (c *ResilientClient) HGet(ctx context.Context, key, field string) (val string, err error) {
+	err = c.do(ctx, "hget", func(ctx context.Context) error {
+		val, err = c.client.HGet(ctx, key, field)
+		return err
+	})
+	return val, err
+}
+
+func (c *ResilientClient) HGetAll(ctx context.Context, key string) (vals map[string]string, err error) {
+	err = c.do(ctx, "hgetall", func(ctx context.Context) error {
+		vals, err = c.client.HGetAll(ctx, key)
+		return err
+	})
+	return vals, err
+}
+
+func (c *ResilientClient) HSet(ctx context.Context, key string, values map[string]interface{}) (n int64, err error) {
+	err = c.do(ctx, "hset", func(ctx context.Context) error {
+		n, err = c.client.HSet(ctx, key, values)
+		return err
+	})
+	return n, err
+}
+
+func (c *ResilientClient) HDel(ctx context.Context, key string, fields ...string) (n int64, err error) {
+	err = c.do(ctx, "hdel", func(ctx context.Context) error {
+		n, err = c.client.HDel(ctx, key, fields...)
+		return err
+	})
+	return n, err
+}
+
+func (c *ResilientClient) HIncrBy(ctx context.Context, key, field string, incr int64) (n int64, err error) {
+	err = c.do(ctx, "hincrby", func(ctx context.Context) error {
+		n, err = c.client.HIncrBy(ctx, key, field, incr)
+		return err
+	})
+	return n, err
+}
+
+func (c *ResilientClient) SAdd(ctx context.Context, key string, members ...string) (n int64, err error) {
+	err = c.do(ctx, "sadd", func(ctx context.Context) error {
+		n, err = c.client.SAdd(ctx, key, members...)
+		return err
+	})
+	return n, err
+}
+
+func (c *ResilientClient) SRem(ctx context.Context, key string, members ...string) (n int64, err error) {
+	err = c.do(ctx, "srem", func(ctx context.Context) error {
+		n, err = c.client.SRem(ctx, key, members...)
+		return err
+	})
+	return n, err
+}
+
+func (c *ResilientClient) SMembers(ctx context.Context, key string) (members []string, err error) {
+	err = c.do(ctx, "smembers", func(ctx context.Context) error {
+		members, err = c.client.SMembers(ctx, key)
+		return err
+	})
+	return members, err
+}
+
+func (c *ResilientClient) SCard(ctx context.Context, key string) (n int64, err error) {
+	err = c.do(ctx, "scard", func(ctx context.Context) error {
+		n, err = c.client.SCard(ctx, key)
+		return err
+	})
+	return n, err
+}
+
+func (c *ResilientClient) ZAdd(ctx context.Context, key string, members ...ZMember) (n int64, err error) {
+	err = c.do(ctx, "zadd", func(ctx context.Context) error {
+		n, err = c.client.ZAdd(ctx, key, members...)
+		return err
+	})
+	return n, err
+}
+
+func (c *ResilientClient) ZRem(ctx context.Context, key string, members ...string) (n int64, err error) {
+	err = c.do(ctx, "zrem", func(ctx context.Context) error {
+		n, err = c.client.ZRem(ctx, key, members...)
+		return err
+	})
+	return n, err
+}
+
+func (c *ResilientClient) ZScore(ctx context.Context, key, member string) (score float64, err error) {
+	err = c.do(ctx, "zscore", func(ctx context.Context) error {
+		score, err = c.client.ZScore(ctx, key, member)
+		return err
+	})
+	return score, err
+}
+
+func (c *ResilientClient) ZCard(ctx context.Context, key string) (n int64, err error) {
+	err = c.do(ctx, "zcard", func(ctx context.Context) error {
+		n, err = c.client.ZCard(ctx, key)
+		return err
+	})
+	return n, err
+}
+
+func (c *ResilientClient) ZRange(ctx context.Context, key string, start, stop int64) (members []ZMember, err error) {
+	err = c.do(ctx, "zrange", func(ctx context.Context) error {
+		members, err = c.client.ZRange(ctx, key, start, stop)
+		return err
+	})
+	return members, err
+}
+
+func (c *ResilientClient) ZRangeByScore(ctx context.Context, key string, by ZRangeBy) (members []ZMember, err error) {
+	err = c.do(ctx, "zrangebyscore", func(ctx context.Context) error {
+		members, err = c.client.ZRangeByScore(ctx, key, by)
+		return err
+	})
+	return members, err
+}
+
+func (c *ResilientClient) ZRemRangeByScore(ctx context.Context, key, min, max string) (n int64, err error) {
+	err = c.do(ctx, "zremrangebyscore", func(ctx context.Context) error {
+		n, err = c.client.ZRemRangeByScore(ctx, key, min, max)
+		return err
+	})
+	return n, err
+}
+
+func (c *ResilientClient) Pipeline() Pipeline {
+	return &resilientPipeline{Pipeline: c.client.Pipeline(), client: c, operation: "pipeline"}
+}
+
+func (c *ResilientClient) TxPipeline() Pipeline {
+	return &resilientPipeline{Pipeline: c.client.TxPipeline(), client: c, operation: "txpipeline"}
+}
+
+// Watch applies one timeout to the whole optimistic transaction. An error
+// of fn's own, e.g. a failed business check, is passed back without counting
+// against the breaker, only those the cache raised through tx do.
+func (c *ResilientClient) Watch(ctx context.Context, fn TxFunc, keys ...string) error {
+	var callerErr error
+	err := c.do(ctx, "watch", func(ctx context.Context) error {
+		var fnErr error
+		err := c.client.Watch(ctx, func(tx Tx) error {
+			fnErr = fn(tx)
+			return fnErr
+		}, keys...)
+		if fnErr != nil && errors.Is(err, fnErr) && !IsConnectionError(fnErr) && !errors.Is(fnErr, context.DeadlineExceeded) {
+			callerErr = err
+			return nil
+		}
+		return err
+	})
+	if callerErr != nil {
+		return callerErr
+	}
+	return err
+}
+
+func (c *ResilientClient) Eval(ctx context.Context, script string, keys []string, args []interface{}) (result interface{}, err error) {
+	err = c.do(ctx, "eval", func(ctx context.Context) error {
+		result, err = c.client.Eval(ctx, script, keys, args)
+		return err
+	})
+	return result, err
+}
+
+func (p *resilientPipeline) Exec(ctx context.Context) error {
+	return p.client.do(ctx, p.operation, func(ctx context.Context) error {
+		return p.Pipeline.Exec(ctx)
+	})
+}
@@ -0,0 +1,171 @@
+package cache_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/brianwu291/go-learn/cache"
+	"github.com/brianwu291/go-learn/cache/memory"
+	"github.com/brianwu291/go-learn/circuitbreaker"
+	"github.com/brianwu291/go-learn/db/redis"
+)
+
+// slowClient blocks Get until the context is done
+type slowClient struct {
+	cache.Client
+}
+
+func (c slowClient) Get(ctx context.Context, key string) (string, error) {
+	<-ctx.Done()
+	return "", ctx.Err()
+}
+
+// failingClient fails Get with err
+type failingClient struct {
+	cache.Client
+	err error
+}
+
+func (c failingClient) Get(ctx context.Context, key string) (string, error) {
+	return "", c.err
+}
+
+func TestResilientClient(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Passes results and misses through", func(t *testing.T) {
+		client := cache.NewResilientClient(memory.NewClient(), cache.ResilienceConfig{
+			Breaker: circuitbreaker.Config{ConsecutiveFailures: 1},
+		})
+
+		require.NoError(t, client.Set(ctx, "key", "value", time.Minute))
+		val, err := client.Get(ctx, "key")
+		require.NoError(t, err)
+		assert.Equal(t, "value", val)
+
+		for i := 0; i < 3; i++ {
+			_, err = client.Get(ctx, "missing")
+			assert.True(t, cache.IsKeyNotFound(err))
+		}
+		assert.Equal(t, circuitbreaker.StateClosed, client.State())
+	})
+
+	t.Run("Counts only unclassified errors as failures", func(t *testing.T) {
+		tests := []struct {
+			name          string
+			err           error
+			expectedState circuitbreaker.State
+		}{
+			{name: "Invalid value", err: fmt.Errorf("%w: not a number", cache.ErrInvalidValue), expectedState: circuitbreaker.StateClosed},
+			{name: "Wrong type", err: cache.NewWrongTypeError("key"), expectedState: circuitbreaker.StateClosed},
+			{name: "Script error", err: cache.NewScriptError(errors.New("ERR bad script")), expectedState: circuitbreaker.StateClosed},
+			{name: "Unknown error", err: errors.New("broken pipe"), expectedState: circuitbreaker.StateOpen},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				client := cache.NewResilientClient(failingClient{memory.NewClient(), tt.err}, cache.ResilienceConfig{
+					Breaker: circuitbreaker.Config{ConsecutiveFailures: 1},
+				})
+
+				_, err := client.Get(ctx, "key")
+				assert.ErrorIs(t, err, tt.err)
+				assert.Equal(t, tt.expectedState, client.State())
+			})
+		}
+	})
+
+	t.Run("Turns timeouts into connection errors", func(t *testing.T) {
+		client := cache.NewResilientClient(slowClient{memory.NewClient()}, cache.ResilienceConfig{
+			Timeout:           time.Second,
+			OperationTimeouts: map[string]time.Duration{"get": 10 * time.Millisecond},
+			Breaker:           circuitbreaker.Config{ConsecutiveFailures: 2},
+		})
+
+		_, err := client.Get(ctx, "key")
+		assert.True(t, cache.IsConnectionError(err))
+		assert.True(t, cache.IsTimeout(err))
+		assert.True(t, errors.Is(err, context.DeadlineExceeded))
+		assert.Equal(t, circuitbreaker.StateClosed, client.State())
+
+		_, err = client.Get(ctx, "key")
+		assert.True(t, cache.IsConnectionError(err))
+		assert.Equal(t, circuitbreaker.StateOpen, client.State())
+	})
+
+	t.Run("Does not count cancelled callers", func(t *testing.T) {
+		client := cache.NewResilientClient(slowClient{memory.NewClient()}, cache.ResilienceConfig{
+			Timeout: time.Second,
+			Breaker: circuitbreaker.Config{ConsecutiveFailures: 1},
+		})
+
+		cancelled, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+		defer cancel()
+		_, err := client.Get(cancelled, "key")
+		assert.Error(t, err)
+		assert.Equal(t, circuitbreaker.StateClosed, client.State())
+	})
+
+	t.Run("Does not count errors of the transaction function", func(t *testing.T) {
+		client := cache.NewResilientClient(memory.NewClient(), cache.ResilienceConfig{
+			Breaker: circuitbreaker.Config{ConsecutiveFailures: 1},
+		})
+		errOutOfStock := errors.New("out of stock")
+
+		for i := 0; i < 3; i++ {
+			err := client.Watch(ctx, func(tx cache.Tx) error {
+				return errOutOfStock
+			}, "stock")
+			assert.ErrorIs(t, err, errOutOfStock)
+		}
+		assert.Equal(t, circuitbreaker.StateClosed, client.State())
+
+		err := client.Watch(ctx, func(tx cache.Tx) error {
+			return cache.NewConnectionError(errors.New("connection reset"))
+		}, "stock")
+		assert.True(t, cache.IsConnectionError(err))
+		assert.Equal(t, circuitbreaker.StateOpen, client.State())
+	})
+
+	t.Run("Fails fast while open and recovers", func(t *testing.T) {
+		server := miniredis.RunT(t)
+		redisClient, err := redis.NewClient(&cache.Config{Host: server.Host(), Port: server.Port()})
+		require.NoError(t, err)
+		defer redisClient.Close()
+
+		client := cache.NewResilientClient(redisClient, cache.ResilienceConfig{
+			Breaker: circuitbreaker.Config{ConsecutiveFailures: 1, OpenTimeout: 50 * time.Millisecond},
+		})
+
+		server.Close()
+		_, err = client.Get(ctx, "key")
+		require.True(t, cache.IsConnectionError(err))
+		require.Equal(t, circuitbreaker.StateOpen, client.State())
+
+		err = client.Set(ctx, "key", "value", time.Minute)
+		assert.True(t, cache.IsConnectionError(err))
+		assert.True(t, errors.Is(err, circuitbreaker.ErrOpen))
+
+		require.NoError(t, server.Restart())
+		time.Sleep(50 * time.Millisecond)
+		assert.Equal(t, circuitbreaker.StateHalfOpen, client.State())
+
+		require.NoError(t, client.Set(ctx, "key", "value", time.Minute))
+		assert.Equal(t, circuitbreaker.StateClosed, client.State())
+	})
+
+	t.Run("Keeps script emulation working through the wrapper", func(t *testing.T) {
+		locker := cache.NewLocker(cache.NewResilientClient(memory.NewClient(), cache.ResilienceConfig{}))
+
+		lock, err := locker.Acquire(ctx, "job", cache.LockOptions{TTL: time.Second})
+		require.NoError(t, err)
+		require.NoError(t, lock.Release(ctx))
+	})
+}
@@ -0,0 +1,44 @@
+package cache_test
+
+import (
+	"context"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/brianwu291/go-learn/cache"
+)
+
+func TestScanKeys(t *testing.T) {
+	keys := cache.NewKeyBuilder("test", "dev")
+	products := keys.Register(cache.KeyFamily{Name: "product", Version: 1})
+
+	for name, client := range testClients(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			for _, key := range []string{products.Key("1"), products.Key("2"), "test:dev:product:v10:1", "other"} {
+				require.NoError(t, client.Set(ctx, key, "value", 0))
+			}
+
+			wrapped := map[string]cache.Client{
+				"plain":        client,
+				"instrumented": cache.NewInstrumentedClient(client, keys, cache.NewMetrics()),
+				"resilient":    cache.NewResilientClient(client, cache.ResilienceConfig{}),
+				"compressed":   cache.NewCompressedClient(client, cache.CompressionConfig{}),
+			}
+			for wrapper, client := range wrapped {
+				found, err := cache.ScanKeys(ctx, client, products.Pattern())
+				require.NoError(t, err, wrapper)
+				sort.Strings(found)
+				assert.Equal(t, []string{products.Key("1"), products.Key("2")}, found, wrapper)
+			}
+		})
+	}
+
+	t.Run("Fails for clients that cannot scan", func(t *testing.T) {
+		_, err := cache.ScanKeys(context.Background(), struct{ cache.Client }{}, "*")
+		assert.ErrorIs(t, err, cache.ErrUnsupported)
+	})
+}
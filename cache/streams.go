@@ -0,0 +1,45 @@
+package cache
+
+import (
+	"context"
+	"time"
+)
+
+type (
+	StreamMessage struct {
+		ID     string
+		Values map[string]string
+	}
+
+	// PendingEntry is a message delivered to a consumer but not acked yet
+	PendingEntry struct {
+		ID       string
+		Consumer string
+		// Idle is the time since the message was last delivered
+		Idle time.Duration
+		// Deliveries counts how often the message was handed out
+		Deliveries int64
+	}
+
+	// Streams is the subset of Redis Streams used by consumer groups
+	Streams interface {
+		// XAdd appends a message and returns its generated ID
+		XAdd(ctx context.Context, stream string, values map[string]interface{}) (string, error)
+		// XGroupCreate creates the group and the stream if needed, start is
+		// "$" for new messages only or "0" for the whole stream. An existing
+		// group is not an error.
+		XGroupCreate(ctx context.Context, stream, group, start string) error
+		// XReadGroup reads messages never delivered to the group, waiting up
+		// to block for new ones. Nothing to read is an empty result.
+		XReadGroup(ctx context.Context, stream, group, consumer string, count int64, block time.Duration) ([]StreamMessage, error)
+		XAck(ctx context.Context, stream, group string, ids ...string) (int64, error)
+		// XDel removes messages, streams are not trimmed by XAck
+		XDel(ctx context.Context, stream string, ids ...string) (int64, error)
+		// XPending lists up to count pending messages idle for at least minIdle
+		XPending(ctx context.Context, stream, group string, minIdle time.Duration, count int64) ([]PendingEntry, error)
+		// XClaim moves pending messages idle for at least minIdle to consumer.
+		// Messages claimed by someone else in the meantime are left out.
+		XClaim(ctx context.Context, stream, group, consumer string, minIdle time.Duration, ids ...string) ([]StreamMessage, error)
+		XLen(ctx context.Context, stream string) (int64, error)
+	}
+)
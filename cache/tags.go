@@ -0,0 +1,192 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+type (
+	// TaggedClient adds tag based group invalidation on top of a Client.
+	// Each tag is a set holding the keys written with it. Tag sets have no
+	// expiry, they stay bounded by the key families tagged with them and
+	// are dropped by InvalidateTag.
+	//
+	// A key and its tags live in different hash slots, so against a
+	// cluster the scripts are replaced by one command per key, which is
+	// not atomic: a key written during an invalidation may survive it.
+	TaggedClient struct {
+		Client
+		cluster bool
+	}
+)
+
+const (
+	tagKeyPrefix = "tag:"
+
+	// KEYS[1] entry key, KEYS[2..n] tag sets, ARGV[1] value, ARGV[2] ttl ms
+	setWithTagsScript = `
+    local ttl = tonumber(ARGV[2])
+    if ttl > 0 then
+        redis.call('SET', KEYS[1], ARGV[1], 'PX', ttl)
+    else
+        redis.call('SET', KEYS[1], ARGV[1])
+    end
+    for i = 2, #KEYS do
+        redis.call('SADD', KEYS[i], KEYS[1])
+    end
+    return 1
+  `
+
+	// KEYS[1] tag set. Deletes every tagged key and the set itself,
+	// returns the number of entries removed.
+	invalidateTagScript = `
+    local keys = redis.call('SMEMBERS', KEYS[1])
+    local removed = 0
+    -- unpack has a stack limit, so delete in chunks
+    for i = 1, #keys, 500 do
+        removed = removed + redis.call('DEL', unpack(keys, i, math.min(i + 499, #keys)))
+    end
+    redis.call('DEL', KEYS[1])
+    return removed
+  `
+)
+
+func NewTaggedClient(client Client) *TaggedClient {
+	if registrar, ok := client.(ScriptRegistrar); ok {
+		registrar.RegisterScript(setWithTagsScript, setWithTagsFunc)
+		registrar.RegisterScript(invalidateTagScript, invalidateTagFunc)
+	}
+
+	return &TaggedClient{
+		Client:  client,
+		cluster: IsCluster(client),
+	}
+}
+
+// TagKey returns the set key that indexes a tag
+func TagKey(tag string) string {
+	return tagKeyPrefix + tag
+}
+
+// SetWithTags writes the value and adds the key to every tag set in one
+// atomic step
+func (t *TaggedClient) SetWithTags(ctx context.Context, key string, value interface{}, expiration time.Duration, tags ...string) error {
+	if len(tags) == 0 {
+		return t.Set(ctx, key, value, expiration)
+	}
+
+	// the value bypasses Set here, so encode it the way Set would
+	if codec, ok := t.Client.(ValueCodec); ok {
+		encoded, err := codec.EncodeValue(value)
+		if err != nil {
+			return fmt.Errorf("set %s with tags: %w", key, err)
+		}
+		value = encoded
+	}
+
+	keys := make([]string, 0, len(tags)+1)
+	keys = append(keys, key)
+	for _, tag := range tags {
+		keys = append(keys, TagKey(tag))
+	}
+
+	if t.cluster {
+		_, err := setWithTagsFunc(ctx, t.Client, keys, []interface{}{value, expiration.Milliseconds()})
+		if err != nil {
+			return fmt.Errorf("set %s with tags: %w", key, err)
+		}
+		return nil
+	}
+
+	if _, err := t.Eval(ctx, setWithTagsScript, keys, []interface{}{value, expiration.Milliseconds()}); err != nil {
+		return fmt.Errorf("set %s with tags: %w", key, err)
+	}
+	return nil
+}
+
+// InvalidateTag atomically deletes every key written with the tag and
+// returns how many entries were removed
+func (t *TaggedClient) InvalidateTag(ctx context.Context, tag string) (int64, error) {
+	if t.cluster {
+		removed, err := t.invalidateEach(ctx, TagKey(tag))
+		if err != nil {
+			return removed, fmt.Errorf("invalidate tag %s: %w", tag, err)
+		}
+		return removed, nil
+	}
+
+	result, err := t.Eval(ctx, invalidateTagScript, []string{TagKey(tag)}, nil)
+	if err != nil {
+		return 0, fmt.Errorf("invalidate tag %s: %w", tag, err)
+	}
+	removed, _ := result.(int64)
+	return removed, nil
+}
+
+// TaggedKeys lists the keys currently indexed under the tag. Entries that
+// already expired may still be listed.
+func (t *TaggedClient) TaggedKeys(ctx context.Context, tag string) ([]string, error) {
+	return t.SMembers(ctx, TagKey(tag))
+}
+
+// invalidateEach deletes the tagged keys one DEL each in a pipeline, so no
+// command spans two slots, and then the tag set
+func (t *TaggedClient) invalidateEach(ctx context.Context, tagKey string) (int64, error) {
+	members, err := t.SMembers(ctx, tagKey)
+	if err != nil {
+		return 0, err
+	}
+
+	var removed int64
+	if len(members) > 0 {
+		pipe := t.Pipeline()
+		cmds := make([]PipelineCmd, 0, len(members))
+		for _, key := range members {
+			cmds = append(cmds, pipe.Del(ctx, key))
+		}
+		err := pipe.Exec(ctx)
+		for _, cmd := range cmds {
+			removed += cmd.Val()
+		}
+		if err != nil {
+			return removed, err
+		}
+	}
+
+	if _, err := t.Del(ctx, tagKey); err != nil {
+		return removed, err
+	}
+	return removed, nil
+}
+
+// setWithTagsFunc indexes the key before writing it, so a failure in
+// between leaves a stale tag member rather than an entry no invalidation
+// reaches
+func setWithTagsFunc(ctx context.Context, c Client, keys []string, args []interface{}) (interface{}, error) {
+	ttl := time.Duration(args[1].(int64)) * time.Millisecond
+	for _, tagKey := range keys[1:] {
+		if _, err := c.SAdd(ctx, tagKey, keys[0]); err != nil {
+			return nil, err
+		}
+	}
+	if err := c.Set(ctx, keys[0], args[0], ttl); err != nil {
+		return nil, err
+	}
+	return int64(1), nil
+}
+
+func invalidateTagFunc(ctx context.Context, c Client, keys []string, args []interface{}) (interface{}, error) {
+	members, err := c.SMembers(ctx, keys[0])
+	if err != nil {
+		return nil, err
+	}
+	removed, err := c.Del(ctx, members...)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := c.Del(ctx, keys[0]); err != nil {
+		return nil, err
+	}
+	return removed, nil
+}
@@ -0,0 +1,118 @@
+package cache_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/brianwu291/go-learn/cache"
+	"github.com/brianwu291/go-learn/cache/memory"
+	"github.com/brianwu291/go-learn/db/redis"
+)
+
+// testClients runs a test against redis, through miniredis, and memory
+func testClients(t *testing.T) map[string]cache.Client {
+	t.Helper()
+
+	server := miniredis.RunT(t)
+	redisClient, err := redis.NewClient(&cache.Config{Host: server.Host(), Port: server.Port()})
+	require.NoError(t, err)
+	t.Cleanup(func() { redisClient.Close() })
+
+	return map[string]cache.Client{
+		"redis":  redisClient,
+		"memory": memory.NewClient(),
+	}
+}
+
+func TestTaggedClient(t *testing.T) {
+	for name, client := range testClients(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			tagged := cache.NewTaggedClient(client)
+
+			require.NoError(t, tagged.SetWithTags(ctx, "products:electronics", "[]", time.Hour, "category:electronics"))
+			require.NoError(t, tagged.SetWithTags(ctx, "product:9", "{}", time.Hour, "category:electronics", "featured"))
+			require.NoError(t, tagged.SetWithTags(ctx, "product:1", "{}", 0, "category:jewelery"))
+			require.NoError(t, tagged.SetWithTags(ctx, "untagged", "value", 0))
+
+			keys, err := tagged.TaggedKeys(ctx, "category:electronics")
+			require.NoError(t, err)
+			assert.ElementsMatch(t, []string{"products:electronics", "product:9"}, keys)
+
+			ttl, err := tagged.TTL(ctx, "product:9")
+			require.NoError(t, err)
+			assert.Equal(t, time.Hour, ttl)
+
+			removed, err := tagged.InvalidateTag(ctx, "category:electronics")
+			require.NoError(t, err)
+			assert.Equal(t, int64(2), removed)
+
+			n, err := tagged.Exists(ctx, "products:electronics", "product:9", cache.TagKey("category:electronics"))
+			require.NoError(t, err)
+			assert.Equal(t, int64(0), n)
+
+			n, err = tagged.Exists(ctx, "product:1", "untagged")
+			require.NoError(t, err)
+			assert.Equal(t, int64(2), n)
+
+			removed, err = tagged.InvalidateTag(ctx, "unknown")
+			require.NoError(t, err)
+			assert.Equal(t, int64(0), removed)
+		})
+	}
+}
+
+// clusterClient stands in for a cluster, which rejects scripts touching keys
+// in more than one hash slot, something miniredis does not check
+type clusterClient struct {
+	cache.Client
+}
+
+func (c clusterClient) IsCluster() bool {
+	return true
+}
+
+func (c clusterClient) Eval(ctx context.Context, script string, keys []string, args []interface{}) (interface{}, error) {
+	return nil, cache.NewScriptError(errors.New("CROSSSLOT Keys in request don't hash to the same slot"))
+}
+
+func TestTaggedClient_Cluster(t *testing.T) {
+	server := miniredis.RunT(t)
+	redisClient, err := redis.NewClient(&cache.Config{Mode: cache.ModeCluster, Addrs: []string{server.Addr()}})
+	require.NoError(t, err)
+	t.Cleanup(func() { redisClient.Close() })
+	require.True(t, cache.IsCluster(redisClient))
+
+	ctx := context.Background()
+	tagged := cache.NewTaggedClient(clusterClient{Client: redisClient})
+
+	require.NoError(t, tagged.SetWithTags(ctx, "products:electronics", "[]", time.Hour, "category:electronics"))
+	require.NoError(t, tagged.SetWithTags(ctx, "product:9", "{}", time.Hour, "category:electronics", "featured"))
+	require.NoError(t, tagged.SetWithTags(ctx, "product:1", "{}", 0, "category:jewelery"))
+
+	keys, err := tagged.TaggedKeys(ctx, "featured")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"product:9"}, keys)
+
+	ttl, err := tagged.TTL(ctx, "product:9")
+	require.NoError(t, err)
+	assert.Equal(t, time.Hour, ttl)
+
+	removed, err := tagged.InvalidateTag(ctx, "category:electronics")
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), removed)
+
+	n, err := tagged.Exists(ctx, "products:electronics", "product:9", cache.TagKey("category:electronics"))
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), n)
+
+	n, err = tagged.Exists(ctx, "product:1")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), n)
+}
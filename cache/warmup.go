@@ -0,0 +1,80 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+type (
+	// Loader fills the cache for one family, usually by calling the
+	// service method that reads through the cache
+	Loader func(ctx context.Context) error
+
+	// Warmer runs the registered loaders so the first requests after a
+	// deploy or a flush do not all miss
+	Warmer struct {
+		mu      sync.RWMutex
+		loaders map[string]Loader
+	}
+
+	WarmupResult struct {
+		Name     string        `json:"name"`
+		Duration time.Duration `json:"duration"`
+		Error    string        `json:"error,omitempty"`
+	}
+)
+
+func NewWarmer() *Warmer {
+	return &Warmer{
+		loaders: make(map[string]Loader),
+	}
+}
+
+// Register adds a loader, registering a name twice panics
+func (w *Warmer) Register(name string, load Loader) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if _, ok := w.loaders[name]; ok {
+		panic(fmt.Errorf("%w: cache loader %s registered twice", ErrInvalidConfig, name))
+	}
+	w.loaders[name] = load
+}
+
+// Run calls every loader concurrently and returns the results sorted by
+// name. A failing loader is logged and does not stop the others.
+func (w *Warmer) Run(ctx context.Context) []WarmupResult {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	results := make([]WarmupResult, 0, len(w.loaders))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for name, load := range w.loaders {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			start := time.Now()
+			err := load(ctx)
+			result := WarmupResult{Name: name, Duration: time.Since(start)}
+			if err != nil {
+				fmt.Printf("failed to warm up cache %s: %+v\n", name, err)
+				result.Error = err.Error()
+			}
+
+			mu.Lock()
+			results = append(results, result)
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].Name < results[j].Name
+	})
+	return results
+}
@@ -0,0 +1,45 @@
+package cache_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/brianwu291/go-learn/cache"
+)
+
+func TestWarmer(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Runs every loader and reports failures", func(t *testing.T) {
+		warmer := cache.NewWarmer()
+		var loaded []string
+		warmer.Register("categories", func(ctx context.Context) error {
+			loaded = append(loaded, "categories")
+			return nil
+		})
+		warmer.Register("products", func(ctx context.Context) error {
+			return errors.New("fake store down")
+		})
+
+		results := warmer.Run(ctx)
+
+		require.Len(t, results, 2)
+		assert.Equal(t, "categories", results[0].Name)
+		assert.Empty(t, results[0].Error)
+		assert.Equal(t, "products", results[1].Name)
+		assert.Equal(t, "fake store down", results[1].Error)
+		assert.Equal(t, []string{"categories"}, loaded)
+	})
+
+	t.Run("Panics on a duplicate name", func(t *testing.T) {
+		warmer := cache.NewWarmer()
+		load := func(ctx context.Context) error { return nil }
+		warmer.Register("categories", load)
+
+		assert.Panics(t, func() { warmer.Register("categories", load) })
+	})
+}
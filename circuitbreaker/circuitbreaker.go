@@ -0,0 +1,210 @@
+package circuitbreaker
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+type (
+	State int
+
+	// Outcome is reported once per allowed call
+	Outcome int
+
+	Config struct {
+		// ConsecutiveFailures opens the breaker after this many failures in
+		// a row, zero disables the rule
+		ConsecutiveFailures int
+		// FailureRatio opens the breaker when failures/calls in the current
+		// Window reaches it, once MinRequests calls were seen. Zero
+		// disables the rule.
+		FailureRatio float64
+		MinRequests  int
+		// Window resets the closed state counters, zero never resets
+		Window time.Duration
+		// OpenTimeout is how long calls are rejected before probing
+		OpenTimeout time.Duration
+		// HalfOpenMaxRequests is how many probes may run at once, all of
+		// them must succeed to close the breaker again
+		HalfOpenMaxRequests int
+	}
+
+	Breaker struct {
+		config Config
+		now    func() time.Time
+
+		mu                  sync.Mutex
+		state               State
+		openedAt            time.Time
+		windowStart         time.Time
+		calls               int
+		failures            int
+		consecutiveFailures int
+		halfOpenInFlight    int
+		halfOpenSuccesses   int
+	}
+)
+
+const (
+	StateClosed State = iota
+	StateOpen
+	StateHalfOpen
+)
+
+const (
+	Success Outcome = iota
+	Failure
+	// Ignored releases the call without counting it, e.g. when the caller
+	// cancelled the request
+	Ignored
+)
+
+const (
+	defaultOpenTimeout         = 30 * time.Second
+	defaultHalfOpenMaxRequests = 1
+)
+
+var (
+	// ErrOpen is returned by Allow while the breaker rejects calls
+	ErrOpen = errors.New("circuit breaker is open")
+)
+
+func (s State) String() string {
+	switch s {
+	case StateClosed:
+		return "closed"
+	case StateOpen:
+		return "open"
+	case StateHalfOpen:
+		return "half-open"
+	default:
+		return "unknown"
+	}
+}
+
+func New(config Config) *Breaker {
+	if config.OpenTimeout <= 0 {
+		config.OpenTimeout = defaultOpenTimeout
+	}
+	if config.HalfOpenMaxRequests <= 0 {
+		config.HalfOpenMaxRequests = defaultHalfOpenMaxRequests
+	}
+
+	return &Breaker{
+		config: config,
+		now:    time.Now,
+	}
+}
+
+// State returns the current state, moving from open to half-open once
+// the open timeout passed
+func (b *Breaker) State() State {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.currentState()
+}
+
+// Allow reserves a call. The returned done func must be called exactly
+// once with the outcome.
+func (b *Breaker) Allow() (func(Outcome), error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.currentState() {
+	case StateOpen:
+		return nil, ErrOpen
+	case StateHalfOpen:
+		if b.halfOpenInFlight >= b.config.HalfOpenMaxRequests {
+			return nil, ErrOpen
+		}
+		b.halfOpenInFlight += 1
+		return b.doneFunc(StateHalfOpen), nil
+	default:
+		return b.doneFunc(StateClosed), nil
+	}
+}
+
+func (b *Breaker) doneFunc(allowedIn State) func(Outcome) {
+	var once sync.Once
+	return func(outcome Outcome) {
+		once.Do(func() {
+			b.mu.Lock()
+			defer b.mu.Unlock()
+
+			if allowedIn == StateHalfOpen {
+				b.halfOpenInFlight -= 1
+			}
+			// the state moved on while the call ran, its result is stale
+			if b.state != allowedIn {
+				return
+			}
+			b.record(outcome)
+		})
+	}
+}
+
+func (b *Breaker) currentState() State {
+	if b.state == StateOpen && !b.now().Before(b.openedAt.Add(b.config.OpenTimeout)) {
+		b.state = StateHalfOpen
+		b.halfOpenInFlight = 0
+		b.halfOpenSuccesses = 0
+	}
+	return b.state
+}
+
+func (b *Breaker) record(outcome Outcome) {
+	if outcome == Ignored {
+		return
+	}
+
+	if b.state == StateHalfOpen {
+		if outcome == Failure {
+			b.open()
+			return
+		}
+		b.halfOpenSuccesses += 1
+		if b.halfOpenSuccesses >= b.config.HalfOpenMaxRequests {
+			b.close()
+		}
+		return
+	}
+
+	now := b.now()
+	if b.config.Window > 0 && now.Sub(b.windowStart) >= b.config.Window {
+		b.windowStart = now
+		b.calls = 0
+		b.failures = 0
+	}
+
+	b.calls += 1
+	if outcome == Success {
+		b.consecutiveFailures = 0
+		return
+	}
+	b.failures += 1
+	b.consecutiveFailures += 1
+
+	if b.config.ConsecutiveFailures > 0 && b.consecutiveFailures >= b.config.ConsecutiveFailures {
+		b.open()
+		return
+	}
+	if b.config.FailureRatio > 0 && b.calls >= b.config.MinRequests &&
+		float64(b.failures)/float64(b.calls) >= b.config.FailureRatio {
+		b.open()
+	}
+}
+
+func (b *Breaker) open() {
+	b.state = StateOpen
+	b.openedAt = b.now()
+}
+
+func (b *Breaker) close() {
+	b.state = StateClosed
+	b.windowStart = b.now()
+	b.calls = 0
+	b.failures = 0
+	b.consecutiveFailures = 0
+}
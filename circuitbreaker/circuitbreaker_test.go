@@ -0,0 +1,123 @@
+package circuitbreaker
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+func newTestBreaker(config Config) (*Breaker, *fakeClock) {
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	breaker := New(config)
+	breaker.now = clock.Now
+	return breaker, clock
+}
+
+func call(t *testing.T, b *Breaker, outcome Outcome) {
+	t.Helper()
+	done, err := b.Allow()
+	require.NoError(t, err)
+	done(outcome)
+}
+
+func TestBreaker(t *testing.T) {
+	t.Run("Opens after consecutive failures", func(t *testing.T) {
+		b, _ := newTestBreaker(Config{ConsecutiveFailures: 3})
+
+		call(t, b, Failure)
+		call(t, b, Failure)
+		call(t, b, Success)
+		call(t, b, Failure)
+		call(t, b, Failure)
+		assert.Equal(t, StateClosed, b.State())
+
+		call(t, b, Failure)
+		assert.Equal(t, StateOpen, b.State())
+
+		_, err := b.Allow()
+		assert.ErrorIs(t, err, ErrOpen)
+	})
+
+	t.Run("Opens on failure ratio once enough calls were seen", func(t *testing.T) {
+		b, clock := newTestBreaker(Config{FailureRatio: 0.5, MinRequests: 4, Window: time.Minute})
+
+		call(t, b, Failure)
+		call(t, b, Failure)
+		assert.Equal(t, StateClosed, b.State())
+
+		// a new window forgets the earlier failures
+		clock.now = clock.now.Add(time.Minute)
+		call(t, b, Success)
+		call(t, b, Failure)
+		call(t, b, Success)
+		assert.Equal(t, StateClosed, b.State())
+
+		call(t, b, Failure)
+		assert.Equal(t, StateOpen, b.State())
+	})
+
+	t.Run("Half-open probes close or reopen the breaker", func(t *testing.T) {
+		tests := []struct {
+			name     string
+			outcomes []Outcome
+			want     State
+		}{
+			{name: "all probes succeed", outcomes: []Outcome{Success, Success}, want: StateClosed},
+			{name: "one probe fails", outcomes: []Outcome{Success, Failure}, want: StateOpen},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				b, clock := newTestBreaker(Config{
+					ConsecutiveFailures: 1,
+					OpenTimeout:         time.Second,
+					HalfOpenMaxRequests: 2,
+				})
+				call(t, b, Failure)
+
+				clock.now = clock.now.Add(time.Second)
+				assert.Equal(t, StateHalfOpen, b.State())
+
+				dones := make([]func(Outcome), 0, len(tt.outcomes))
+				for range tt.outcomes {
+					done, err := b.Allow()
+					require.NoError(t, err)
+					dones = append(dones, done)
+				}
+				_, err := b.Allow()
+				assert.ErrorIs(t, err, ErrOpen, "probes are limited")
+
+				for i, done := range dones {
+					done(tt.outcomes[i])
+				}
+				assert.Equal(t, tt.want, b.State())
+			})
+		}
+	})
+
+	t.Run("Ignores stale and ignored outcomes", func(t *testing.T) {
+		b, _ := newTestBreaker(Config{ConsecutiveFailures: 1})
+
+		slow, err := b.Allow()
+		require.NoError(t, err)
+		call(t, b, Ignored)
+		assert.Equal(t, StateClosed, b.State())
+
+		call(t, b, Failure)
+		require.Equal(t, StateOpen, b.State())
+
+		// allowed while closed, reported after opening
+		slow(Success)
+		assert.Equal(t, StateOpen, b.State())
+	})
+}
@@ -3,6 +3,11 @@ package constants
 const (
 	InternalServerErrorMessage = "internal server error"
 
+	// CacheNamespace prefixes every cache key of this app
+	CacheNamespace = "go-learn"
+
 	// https://fakestoreapi.com/docs
 	FakeStoreBaseURL = "https://fakestoreapi.com"
+	// FakeStoreLoginPath returns a token for username and password
+	FakeStoreLoginPath = "/auth/login"
 )
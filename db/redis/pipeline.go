@@ -0,0 +1,262 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/brianwu291/go-learn/cache"
+)
+
+type (
+	Pipeline struct {
+		pipeline redis.Pipeliner
+		cmds     []redis.Cmder
+	}
+
+	tx struct {
+		tx *redis.Tx
+	}
+
+	pipelineIntCmd struct {
+		key string
+		cmd *redis.IntCmd
+	}
+
+	pipelineDurationCmd struct {
+		key string
+		cmd *redis.DurationCmd
+	}
+
+	pipelineStringCmd struct {
+		key string
+		cmd *redis.StringCmd
+	}
+
+	pipelineBoolCmd struct {
+		key string
+		cmd *redis.BoolCmd
+	}
+
+	pipelineMapCmd struct {
+		key string
+		cmd *redis.MapStringStringCmd
+	}
+
+	pipelineStatusCmd struct {
+		key string
+		cmd *redis.StatusCmd
+	}
+)
+
+func newPipeline(pipeline redis.Pipeliner) *Pipeline {
+	return &Pipeline{
+		pipeline: pipeline,
+		cmds:     make([]redis.Cmder, 0),
+	}
+}
+
+func (c *Client) Pipeline() cache.Pipeline {
+	return newPipeline(c.client.Pipeline())
+}
+
+func (c *Client) TxPipeline() cache.Pipeline {
+	return newPipeline(c.client.TxPipeline())
+}
+
+func (c *Client) Watch(ctx context.Context, fn cache.TxFunc, keys ...string) error {
+	var fnErr error
+	err := c.client.Watch(ctx, func(rtx *redis.Tx) error {
+		fnErr = fn(&tx{tx: rtx})
+		return fnErr
+	}, keys...)
+	if err == nil {
+		return nil
+	}
+	if errors.Is(err, redis.TxFailedErr) {
+		return cache.ErrTxFailed
+	}
+	if err == fnErr {
+		return err
+	}
+	return toCacheError("", err)
+}
+
+func (p *Pipeline) Get(ctx context.Context, key string) cache.PipelineStringCmd {
+	cmd := p.pipeline.Get(ctx, key)
+	p.cmds = append(p.cmds, cmd)
+	return &pipelineStringCmd{key: key, cmd: cmd}
+}
+
+func (p *Pipeline) Set(ctx context.Context, key string, value interface{}, expiration time.Duration) cache.PipelineStatusCmd {
+	cmd := p.pipeline.Set(ctx, key, value, expiration)
+	p.cmds = append(p.cmds, cmd)
+	return &pipelineStatusCmd{key: key, cmd: cmd}
+}
+
+func (p *Pipeline) Del(ctx context.Context, keys ...string) cache.PipelineCmd {
+	cmd := p.pipeline.Del(ctx, keys...)
+	p.cmds = append(p.cmds, cmd)
+	return &pipelineIntCmd{cmd: cmd}
+}
+
+func (p *Pipeline) Incr(ctx context.Context, key string) cache.PipelineCmd {
+	cmd := p.pipeline.Incr(ctx, key)
+	p.cmds = append(p.cmds, cmd)
+	return &pipelineIntCmd{key: key, cmd: cmd}
+}
+
+func (p *Pipeline) TTL(ctx context.Context, key string) cache.PipelineDurationCmd {
+	cmd := p.pipeline.TTL(ctx, key)
+	p.cmds = append(p.cmds, cmd)
+	return &pipelineDurationCmd{key: key, cmd: cmd}
+}
+
+func (p *Pipeline) Expire(ctx context.Context, key string, expiration time.Duration) cache.PipelineBoolCmd {
+	cmd := p.pipeline.Expire(ctx, key, expiration)
+	p.cmds = append(p.cmds, cmd)
+	return &pipelineBoolCmd{key: key, cmd: cmd}
+}
+
+func (p *Pipeline) HGet(ctx context.Context, key, field string) cache.PipelineStringCmd {
+	cmd := p.pipeline.HGet(ctx, key, field)
+	p.cmds = append(p.cmds, cmd)
+	return &pipelineStringCmd{key: key, cmd: cmd}
+}
+
+func (p *Pipeline) HGetAll(ctx context.Context, key string) cache.PipelineMapCmd {
+	cmd := p.pipeline.HGetAll(ctx, key)
+	p.cmds = append(p.cmds, cmd)
+	return &pipelineMapCmd{key: key, cmd: cmd}
+}
+
+func (p *Pipeline) HSet(ctx context.Context, key string, values map[string]interface{}) cache.PipelineCmd {
+	cmd := p.pipeline.HSet(ctx, key, values)
+	p.cmds = append(p.cmds, cmd)
+	return &pipelineIntCmd{key: key, cmd: cmd}
+}
+
+func (p *Pipeline) HDel(ctx context.Context, key string, fields ...string) cache.PipelineCmd {
+	cmd := p.pipeline.HDel(ctx, key, fields...)
+	p.cmds = append(p.cmds, cmd)
+	return &pipelineIntCmd{key: key, cmd: cmd}
+}
+
+func (p *Pipeline) HIncrBy(ctx context.Context, key, field string, incr int64) cache.PipelineCmd {
+	cmd := p.pipeline.HIncrBy(ctx, key, field, incr)
+	p.cmds = append(p.cmds, cmd)
+	return &pipelineIntCmd{key: key, cmd: cmd}
+}
+
+func (p *Pipeline) Len() int {
+	return len(p.cmds)
+}
+
+func (p *Pipeline) Exec(ctx context.Context) error {
+	cmds := p.cmds
+	p.cmds = make([]redis.Cmder, 0)
+
+	_, err := p.pipeline.Exec(ctx)
+	if err == nil {
+		return nil
+	}
+	if errors.Is(err, redis.TxFailedErr) {
+		return cache.ErrTxFailed
+	}
+
+	// go-redis reports the first failed command, which may only be a
+	// missing key, so look for a real failure before giving up
+	for _, cmd := range cmds {
+		if cmdErr := cmd.Err(); cmdErr != nil && !errors.Is(cmdErr, redis.Nil) {
+			return toCacheError("", cmdErr)
+		}
+	}
+	if errors.Is(err, redis.Nil) {
+		return nil
+	}
+	return toCacheError("", err)
+}
+
+func (p *Pipeline) Discard() {
+	p.pipeline.Discard()
+	p.cmds = make([]redis.Cmder, 0)
+}
+
+func (t *tx) Get(ctx context.Context, key string) (string, error) {
+	val, err := t.tx.Get(ctx, key).Result()
+	if err != nil {
+		return "", toCacheError(key, err)
+	}
+	return val, nil
+}
+
+func (t *tx) HGetAll(ctx context.Context, key string) (map[string]string, error) {
+	vals, err := t.tx.HGetAll(ctx, key).Result()
+	if err != nil {
+		return nil, toCacheError(key, err)
+	}
+	return vals, nil
+}
+
+func (t *tx) TTL(ctx context.Context, key string) (time.Duration, error) {
+	ttl, err := t.tx.TTL(ctx, key).Result()
+	if err != nil {
+		return 0, toCacheError(key, err)
+	}
+	return ttl, nil
+}
+
+func (t *tx) TxPipelined(ctx context.Context, fn func(pipe cache.Pipeline) error) error {
+	pipe := newPipeline(t.tx.TxPipeline())
+	if err := fn(pipe); err != nil {
+		pipe.Discard()
+		return err
+	}
+	return pipe.Exec(ctx)
+}
+
+func (c *pipelineIntCmd) Val() int64 {
+	return c.cmd.Val()
+}
+
+func (c *pipelineIntCmd) Err() error {
+	return toCacheError(c.key, c.cmd.Err())
+}
+
+func (c *pipelineDurationCmd) Val() time.Duration {
+	return c.cmd.Val()
+}
+
+func (c *pipelineDurationCmd) Err() error {
+	return toCacheError(c.key, c.cmd.Err())
+}
+
+func (c *pipelineStringCmd) Val() string {
+	return c.cmd.Val()
+}
+
+func (c *pipelineStringCmd) Err() error {
+	return toCacheError(c.key, c.cmd.Err())
+}
+
+func (c *pipelineBoolCmd) Val() bool {
+	return c.cmd.Val()
+}
+
+func (c *pipelineBoolCmd) Err() error {
+	return toCacheError(c.key, c.cmd.Err())
+}
+
+func (c *pipelineMapCmd) Val() map[string]string {
+	return c.cmd.Val()
+}
+
+func (c *pipelineMapCmd) Err() error {
+	return toCacheError(c.key, c.cmd.Err())
+}
+
+func (c *pipelineStatusCmd) Err() error {
+	return toCacheError(c.key, c.cmd.Err())
+}
@@ -0,0 +1,120 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/brianwu291/go-learn/cache"
+)
+
+func TestPipeline_Exec(t *testing.T) {
+	client, server := newTestClient(t)
+	ctx := context.Background()
+
+	require.NoError(t, client.Set(ctx, "existing", "value", 0))
+
+	pipe := client.Pipeline()
+	setCmd := pipe.Set(ctx, "new", "1", time.Minute)
+	incrCmd := pipe.Incr(ctx, "new")
+	getCmd := pipe.Get(ctx, "existing")
+	missingCmd := pipe.Get(ctx, "missing")
+	ttlCmd := pipe.TTL(ctx, "new")
+	hsetCmd := pipe.HSet(ctx, "hash", map[string]interface{}{"a": "1"})
+	hgetAllCmd := pipe.HGetAll(ctx, "hash")
+	delCmd := pipe.Del(ctx, "existing")
+	assert.Equal(t, 8, pipe.Len())
+
+	// a missing key must not fail the whole batch
+	require.NoError(t, pipe.Exec(ctx))
+	assert.Equal(t, 0, pipe.Len())
+
+	assert.NoError(t, setCmd.Err())
+	assert.Equal(t, int64(2), incrCmd.Val())
+	assert.Equal(t, "value", getCmd.Val())
+	assert.True(t, cache.IsKeyNotFound(missingCmd.Err()))
+	assert.Equal(t, time.Minute, ttlCmd.Val())
+	assert.Equal(t, int64(1), hsetCmd.Val())
+	assert.Equal(t, map[string]string{"a": "1"}, hgetAllCmd.Val())
+	assert.Equal(t, int64(1), delCmd.Val())
+	assert.False(t, server.Exists("existing"))
+}
+
+func TestPipeline_Discard(t *testing.T) {
+	client, server := newTestClient(t)
+	ctx := context.Background()
+
+	pipe := client.TxPipeline()
+	pipe.Set(ctx, "key", "value", 0)
+	pipe.Discard()
+
+	assert.Equal(t, 0, pipe.Len())
+	require.NoError(t, pipe.Exec(ctx))
+	assert.False(t, server.Exists("key"))
+}
+
+func TestClient_Watch(t *testing.T) {
+	client, _ := newTestClient(t)
+	ctx := context.Background()
+
+	increment := func(tx cache.Tx) error {
+		current, err := tx.Get(ctx, "counter")
+		if err != nil && !cache.IsKeyNotFound(err) {
+			return err
+		}
+		n, _ := strconv.Atoi(current)
+		return tx.TxPipelined(ctx, func(pipe cache.Pipeline) error {
+			pipe.Set(ctx, "counter", n+1, 0)
+			return nil
+		})
+	}
+
+	t.Run("Commits when watched key is unchanged", func(t *testing.T) {
+		require.NoError(t, client.Watch(ctx, increment, "counter"))
+
+		val, err := client.Get(ctx, "counter")
+		require.NoError(t, err)
+		assert.Equal(t, "1", val)
+	})
+
+	t.Run("Fails when watched key changes", func(t *testing.T) {
+		err := client.Watch(ctx, func(tx cache.Tx) error {
+			// simulate a concurrent writer between WATCH and EXEC
+			require.NoError(t, client.Set(ctx, "counter", "100", 0))
+			return increment(tx)
+		}, "counter")
+		assert.True(t, errors.Is(err, cache.ErrTxFailed))
+
+		val, _ := client.Get(ctx, "counter")
+		assert.Equal(t, "100", val)
+	})
+
+	t.Run("Returns fn error unchanged", func(t *testing.T) {
+		errAbort := errors.New("abort")
+		err := client.Watch(ctx, func(tx cache.Tx) error {
+			return errAbort
+		}, "counter")
+		assert.Equal(t, errAbort, err)
+	})
+
+	t.Run("Transact retries conflicts", func(t *testing.T) {
+		attempts := 0
+		err := cache.Transact(ctx, client, 3, func(tx cache.Tx) error {
+			attempts += 1
+			if attempts < 3 {
+				require.NoError(t, client.Set(ctx, "counter", "0", 0))
+			}
+			return increment(tx)
+		}, "counter")
+		require.NoError(t, err)
+		assert.Equal(t, 3, attempts)
+
+		val, _ := client.Get(ctx, "counter")
+		assert.Equal(t, "1", val)
+	})
+}
@@ -0,0 +1,111 @@
+package redis
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/brianwu291/go-learn/cache"
+)
+
+type (
+	subscription struct {
+		pubsub    *redis.PubSub
+		messages  chan cache.Message
+		done      chan struct{}
+		closeOnce sync.Once
+	}
+)
+
+const (
+	subscriptionBufferSize = 100
+	// an idle subscription is pinged this often so a dead connection is
+	// noticed and replaced
+	subscriptionHealthCheck = 30 * time.Second
+)
+
+func (c *Client) Publish(ctx context.Context, channel string, message interface{}) (int64, error) {
+	payload, err := cache.EncodePayload(message)
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := c.client.Publish(ctx, channel, payload).Result()
+	if err != nil {
+		return 0, toCacheError(channel, err)
+	}
+	return n, nil
+}
+
+func (c *Client) Subscribe(ctx context.Context, channels ...string) (cache.Subscription, error) {
+	return newSubscription(ctx, c.client.Subscribe(ctx, channels...))
+}
+
+func (c *Client) PSubscribe(ctx context.Context, patterns ...string) (cache.Subscription, error) {
+	return newSubscription(ctx, c.client.PSubscribe(ctx, patterns...))
+}
+
+// newSubscription waits for the server confirmation, so a message
+// published right after Subscribe returns is not missed
+func newSubscription(ctx context.Context, pubsub *redis.PubSub) (*subscription, error) {
+	if _, err := pubsub.Receive(ctx); err != nil {
+		pubsub.Close()
+		return nil, toCacheError("", err)
+	}
+
+	sub := &subscription{
+		pubsub:   pubsub,
+		messages: make(chan cache.Message, subscriptionBufferSize),
+		done:     make(chan struct{}),
+	}
+	go sub.run(ctx)
+
+	return sub, nil
+}
+
+// run forwards messages until Close or ctx is done. go-redis reconnects
+// and resubscribes by itself when the connection drops.
+func (s *subscription) run(ctx context.Context) {
+	defer close(s.messages)
+
+	ch := s.pubsub.Channel(
+		redis.WithChannelSize(subscriptionBufferSize),
+		redis.WithChannelHealthCheckInterval(subscriptionHealthCheck),
+	)
+	for {
+		select {
+		case <-ctx.Done():
+			s.Close()
+			return
+		case <-s.done:
+			return
+		case msg, ok := <-ch:
+			if !ok {
+				return
+			}
+			select {
+			case s.messages <- cache.Message{Channel: msg.Channel, Pattern: msg.Pattern, Payload: msg.Payload}:
+			case <-ctx.Done():
+				s.Close()
+				return
+			case <-s.done:
+				return
+			}
+		}
+	}
+}
+
+func (s *subscription) Messages() <-chan cache.Message {
+	return s.messages
+}
+
+func (s *subscription) Close() error {
+	var err error
+	s.closeOnce.Do(func() {
+		close(s.done)
+		err = s.pubsub.Close()
+	})
+	return err
+}
@@ -2,7 +2,12 @@ package redis
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
 	"fmt"
+	"net"
+	"os"
 	"time"
 
 	"github.com/redis/go-redis/v9"
@@ -12,34 +17,79 @@ import (
 
 type (
 	Client struct {
-		client *redis.Client
+		client redis.UniversalClient
 	}
+)
 
-	Pipeline struct {
-		pipeline redis.Pipeliner
-		cmds     []cache.PipelineCmd
-	}
+// unavailablePrefixes start the error replies of a node that is up but
+// cannot serve the command until a failover or load finishes
+var unavailablePrefixes = []string{"READONLY", "LOADING", "CLUSTERDOWN", "MASTERDOWN", "TRYAGAIN"}
 
-	pipelineIncrCmd struct {
-		cmd *redis.IntCmd
+// NewClient connects to a standalone node, a sentinel managed master or
+// a cluster depending on cfg.Mode, and pings it before returning
+func NewClient(cfg *cache.Config) (*Client, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
 	}
 
-	pipelineTTLCmd struct {
-		cmd *redis.DurationCmd
+	tlsConfig, err := newTLSConfig(cfg.TLS)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build Redis TLS config: %w", err)
 	}
-)
 
-func NewClient(cfg *cache.Config) (*Client, error) {
-	client := redis.NewClient(&redis.Options{
-		Addr:     fmt.Sprintf("%s:%s", cfg.Host, cfg.Port),
-		Password: cfg.Password,
-		DB:       cfg.DB,
-	})
+	var client redis.UniversalClient
+	switch cfg.Mode {
+	case cache.ModeSentinel:
+		client = redis.NewFailoverClient(&redis.FailoverOptions{
+			MasterName:       cfg.MasterName,
+			SentinelAddrs:    cfg.Addrs,
+			SentinelUsername: cfg.SentinelUsername,
+			SentinelPassword: cfg.SentinelPassword,
+			Username:         cfg.Username,
+			Password:         cfg.Password,
+			DB:               cfg.DB,
+			TLSConfig:        tlsConfig,
+			PoolSize:         cfg.PoolSize,
+			MinIdleConns:     cfg.MinIdleConns,
+			DialTimeout:      cfg.DialTimeout,
+			ReadTimeout:      cfg.ReadTimeout,
+			WriteTimeout:     cfg.WriteTimeout,
+			PoolTimeout:      cfg.PoolTimeout,
+		})
+	case cache.ModeCluster:
+		client = redis.NewClusterClient(&redis.ClusterOptions{
+			Addrs:        cfg.Addrs,
+			Username:     cfg.Username,
+			Password:     cfg.Password,
+			TLSConfig:    tlsConfig,
+			PoolSize:     cfg.PoolSize,
+			MinIdleConns: cfg.MinIdleConns,
+			DialTimeout:  cfg.DialTimeout,
+			ReadTimeout:  cfg.ReadTimeout,
+			WriteTimeout: cfg.WriteTimeout,
+			PoolTimeout:  cfg.PoolTimeout,
+		})
+	default:
+		client = redis.NewClient(&redis.Options{
+			Addr:         cfg.Address(),
+			Username:     cfg.Username,
+			Password:     cfg.Password,
+			DB:           cfg.DB,
+			TLSConfig:    tlsConfig,
+			PoolSize:     cfg.PoolSize,
+			MinIdleConns: cfg.MinIdleConns,
+			DialTimeout:  cfg.DialTimeout,
+			ReadTimeout:  cfg.ReadTimeout,
+			WriteTimeout: cfg.WriteTimeout,
+			PoolTimeout:  cfg.PoolTimeout,
+		})
+	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
 	if err := client.Ping(ctx).Err(); err != nil {
+		client.Close()
 		return nil, fmt.Errorf("failed to connect to Redis: %w", err)
 	}
 
@@ -48,71 +98,405 @@ func NewClient(cfg *cache.Config) (*Client, error) {
 	}, nil
 }
 
+// newTLSConfig returns nil when TLS is disabled so the driver dials plain TCP
+func newTLSConfig(cfg cache.TLSConfig) (*tls.Config, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+
+	tlsConfig := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		ServerName:         cfg.ServerName,
+		InsecureSkipVerify: cfg.InsecureSkipVerify,
+	}
+
+	if cfg.CAFile != "" {
+		caPEM, err := os.ReadFile(cfg.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("reading CA file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caPEM) {
+			return nil, fmt.Errorf("no certificates found in CA file %s", cfg.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if cfg.CertFile != "" && cfg.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("loading client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsConfig, nil
+}
+
+// Close releases every pooled connection
+func (c *Client) Close() error {
+	return c.client.Close()
+}
+
+// IsCluster reports whether the client was opened in cluster mode
+func (c *Client) IsCluster() bool {
+	_, ok := c.client.(*redis.ClusterClient)
+	return ok
+}
+
 func (c *Client) Get(ctx context.Context, key string) (string, error) {
 	val, err := c.client.Get(ctx, key).Result()
 	if err != nil {
-		if err == redis.Nil {
-			return "", cache.NewKeyNotFoundError(key)
-		}
-		return "", cache.NewConnectionError(err)
+		return "", toCacheError(key, err)
 	}
 	return val, nil
 }
 
 func (c *Client) Set(ctx context.Context, key string, value interface{}, expiration time.Duration) error {
-	return c.client.Set(ctx, key, value, expiration).Err()
+	return toCacheError(key, c.client.Set(ctx, key, value, expiration).Err())
+}
+
+func (c *Client) SetNX(ctx context.Context, key string, value interface{}, expiration time.Duration) (bool, error) {
+	ok, err := c.client.SetNX(ctx, key, value, expiration).Result()
+	if err != nil {
+		return false, toCacheError(key, err)
+	}
+	return ok, nil
+}
+
+func (c *Client) Del(ctx context.Context, keys ...string) (int64, error) {
+	if len(keys) == 0 {
+		return 0, nil
+	}
+	n, err := c.client.Del(ctx, keys...).Result()
+	if err != nil {
+		return 0, toCacheError("", err)
+	}
+	return n, nil
+}
+
+func (c *Client) Exists(ctx context.Context, keys ...string) (int64, error) {
+	if len(keys) == 0 {
+		return 0, nil
+	}
+	n, err := c.client.Exists(ctx, keys...).Result()
+	if err != nil {
+		return 0, toCacheError("", err)
+	}
+	return n, nil
+}
+
+// MGet reads through a pipeline of GETs instead of MGET, so keys may
+// live in different cluster slots like with MSet
+func (c *Client) MGet(ctx context.Context, keys ...string) (map[string]string, error) {
+	result := make(map[string]string, len(keys))
+	if len(keys) == 0 {
+		return result, nil
+	}
+
+	cmds := make([]*redis.StringCmd, len(keys))
+	_, err := c.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i, key := range keys {
+			cmds[i] = pipe.Get(ctx, key)
+		}
+		return nil
+	})
+	// a missing key fails its own GET with a reply, checked below
+	var redisErr redis.Error
+	if err != nil && !errors.As(err, &redisErr) {
+		return nil, toCacheError("", err)
+	}
+
+	for i, cmd := range cmds {
+		val, err := cmd.Result()
+		// like MGET, missing keys and keys of other types are left out
+		if errors.Is(err, redis.Nil) || redis.HasErrorPrefix(err, "WRONGTYPE") {
+			continue
+		}
+		if err != nil {
+			return nil, toCacheError(keys[i], err)
+		}
+		result[keys[i]] = val
+	}
+	return result, nil
+}
+
+// MSet writes through a pipeline instead of MSET so every key can carry
+// the expiration and keys may live in different cluster slots.
+// The writes are not atomic.
+func (c *Client) MSet(ctx context.Context, values map[string]interface{}, expiration time.Duration) error {
+	if len(values) == 0 {
+		return nil
+	}
+
+	_, err := c.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for key, value := range values {
+			pipe.Set(ctx, key, value, expiration)
+		}
+		return nil
+	})
+	return toCacheError("", err)
 }
 
 func (c *Client) Incr(ctx context.Context, key string) (int64, error) {
-	return c.client.Incr(ctx, key).Result()
+	n, err := c.client.Incr(ctx, key).Result()
+	if err != nil {
+		return 0, toCacheError(key, err)
+	}
+	return n, nil
 }
 
 func (c *Client) TTL(ctx context.Context, key string) (time.Duration, error) {
-	return c.client.TTL(ctx, key).Result()
+	ttl, err := c.client.TTL(ctx, key).Result()
+	if err != nil {
+		return 0, toCacheError(key, err)
+	}
+	return ttl, nil
 }
 
 func (c *Client) Expire(ctx context.Context, key string, expiration time.Duration) error {
-	return c.client.Expire(ctx, key, expiration).Err()
+	return toCacheError(key, c.client.Expire(ctx, key, expiration).Err())
 }
 
+// Eval reports server replies as ScriptError, a script that returns nil
+// comes back as KeyNotFoundError
 func (c *Client) Eval(ctx context.Context, script string, keys []string, args []interface{}) (interface{}, error) {
-	return c.client.Eval(ctx, script, keys, args).Result()
+	result, err := c.client.Eval(ctx, script, keys, args).Result()
+	if err != nil {
+		return nil, toScriptError(err)
+	}
+	return result, nil
+}
+
+func (c *Client) HGet(ctx context.Context, key, field string) (string, error) {
+	val, err := c.client.HGet(ctx, key, field).Result()
+	if err != nil {
+		return "", toCacheError(key, err)
+	}
+	return val, nil
+}
+
+func (c *Client) HGetAll(ctx context.Context, key string) (map[string]string, error) {
+	vals, err := c.client.HGetAll(ctx, key).Result()
+	if err != nil {
+		return nil, toCacheError(key, err)
+	}
+	return vals, nil
+}
+
+func (c *Client) HSet(ctx context.Context, key string, values map[string]interface{}) (int64, error) {
+	if len(values) == 0 {
+		return 0, nil
+	}
+	n, err := c.client.HSet(ctx, key, values).Result()
+	if err != nil {
+		return 0, toCacheError(key, err)
+	}
+	return n, nil
 }
 
-func (c *Client) Pipeline() cache.Pipeline {
-	return &Pipeline{
-		pipeline: c.client.Pipeline(),
-		cmds:     make([]cache.PipelineCmd, 0),
+func (c *Client) HDel(ctx context.Context, key string, fields ...string) (int64, error) {
+	if len(fields) == 0 {
+		return 0, nil
+	}
+	n, err := c.client.HDel(ctx, key, fields...).Result()
+	if err != nil {
+		return 0, toCacheError(key, err)
 	}
+	return n, nil
 }
 
-func (p *Pipeline) Incr(ctx context.Context, key string) cache.PipelineCmd {
-	cmd := &pipelineIncrCmd{cmd: p.pipeline.Incr(ctx, key)}
-	p.cmds = append(p.cmds, cmd)
-	return cmd
+func (c *Client) HIncrBy(ctx context.Context, key, field string, incr int64) (int64, error) {
+	n, err := c.client.HIncrBy(ctx, key, field, incr).Result()
+	if err != nil {
+		return 0, toCacheError(key, err)
+	}
+	return n, nil
 }
 
-func (p *Pipeline) TTL(ctx context.Context, key string) cache.PipelineDurationCmd {
-	return &pipelineTTLCmd{cmd: p.pipeline.TTL(ctx, key)}
+func (c *Client) SAdd(ctx context.Context, key string, members ...string) (int64, error) {
+	if len(members) == 0 {
+		return 0, nil
+	}
+	n, err := c.client.SAdd(ctx, key, toArgs(members)...).Result()
+	if err != nil {
+		return 0, toCacheError(key, err)
+	}
+	return n, nil
 }
 
-func (p *Pipeline) Exec(ctx context.Context) error {
-	_, err := p.pipeline.Exec(ctx)
-	return err
+func (c *Client) SRem(ctx context.Context, key string, members ...string) (int64, error) {
+	if len(members) == 0 {
+		return 0, nil
+	}
+	n, err := c.client.SRem(ctx, key, toArgs(members)...).Result()
+	if err != nil {
+		return 0, toCacheError(key, err)
+	}
+	return n, nil
 }
 
-func (c *pipelineIncrCmd) Val() int64 {
-	return c.cmd.Val()
+func (c *Client) SMembers(ctx context.Context, key string) ([]string, error) {
+	members, err := c.client.SMembers(ctx, key).Result()
+	if err != nil {
+		return nil, toCacheError(key, err)
+	}
+	return members, nil
 }
 
-func (c *pipelineIncrCmd) Err() error {
-	return c.cmd.Err()
+func (c *Client) SCard(ctx context.Context, key string) (int64, error) {
+	n, err := c.client.SCard(ctx, key).Result()
+	if err != nil {
+		return 0, toCacheError(key, err)
+	}
+	return n, nil
 }
 
-func (c *pipelineTTLCmd) Val() time.Duration {
-	return c.cmd.Val()
+func (c *Client) ZAdd(ctx context.Context, key string, members ...cache.ZMember) (int64, error) {
+	if len(members) == 0 {
+		return 0, nil
+	}
+	zs := make([]redis.Z, 0, len(members))
+	for _, m := range members {
+		zs = append(zs, redis.Z{Score: m.Score, Member: m.Member})
+	}
+	n, err := c.client.ZAdd(ctx, key, zs...).Result()
+	if err != nil {
+		return 0, toCacheError(key, err)
+	}
+	return n, nil
+}
+
+func (c *Client) ZRem(ctx context.Context, key string, members ...string) (int64, error) {
+	if len(members) == 0 {
+		return 0, nil
+	}
+	n, err := c.client.ZRem(ctx, key, toArgs(members)...).Result()
+	if err != nil {
+		return 0, toCacheError(key, err)
+	}
+	return n, nil
+}
+
+func (c *Client) ZScore(ctx context.Context, key, member string) (float64, error) {
+	score, err := c.client.ZScore(ctx, key, member).Result()
+	if err != nil {
+		return 0, toCacheError(key, err)
+	}
+	return score, nil
 }
 
-func (c *pipelineTTLCmd) Err() error {
-	return c.cmd.Err()
+func (c *Client) ZCard(ctx context.Context, key string) (int64, error) {
+	n, err := c.client.ZCard(ctx, key).Result()
+	if err != nil {
+		return 0, toCacheError(key, err)
+	}
+	return n, nil
+}
+
+func (c *Client) ZRange(ctx context.Context, key string, start, stop int64) ([]cache.ZMember, error) {
+	zs, err := c.client.ZRangeWithScores(ctx, key, start, stop).Result()
+	if err != nil {
+		return nil, toCacheError(key, err)
+	}
+	return toZMembers(zs), nil
+}
+
+func (c *Client) ZRangeByScore(ctx context.Context, key string, by cache.ZRangeBy) ([]cache.ZMember, error) {
+	opt := &redis.ZRangeBy{
+		Min:    by.Min,
+		Max:    by.Max,
+		Offset: by.Offset,
+		Count:  by.Count,
+	}
+	if opt.Count <= 0 {
+		// redis needs both offset and count, -1 means no limit
+		opt.Count = -1
+	}
+	zs, err := c.client.ZRangeByScoreWithScores(ctx, key, opt).Result()
+	if err != nil {
+		return nil, toCacheError(key, err)
+	}
+	return toZMembers(zs), nil
+}
+
+func (c *Client) ZRemRangeByScore(ctx context.Context, key, min, max string) (int64, error) {
+	n, err := c.client.ZRemRangeByScore(ctx, key, min, max).Result()
+	if err != nil {
+		return 0, toCacheError(key, err)
+	}
+	return n, nil
+}
+
+func toArgs(values []string) []interface{} {
+	args := make([]interface{}, 0, len(values))
+	for _, v := range values {
+		args = append(args, v)
+	}
+	return args
+}
+
+func toZMembers(zs []redis.Z) []cache.ZMember {
+	members := make([]cache.ZMember, 0, len(zs))
+	for _, z := range zs {
+		member, _ := z.Member.(string)
+		members = append(members, cache.ZMember{Member: member, Score: z.Score})
+	}
+	return members
+}
+
+// toCacheError maps driver errors into the cache error types
+func toCacheError(key string, err error) error {
+	if err == nil {
+		return nil
+	}
+	if errors.Is(err, redis.Nil) {
+		return cache.NewKeyNotFoundError(key)
+	}
+	if errors.Is(err, context.Canceled) {
+		return cache.NewCanceledError(err)
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return cache.NewTimeoutError(err)
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return cache.NewTimeoutError(err)
+	}
+
+	if isUnavailable(err) {
+		return cache.NewConnectionError(err)
+	}
+
+	// anything else the server replied is about the command, not the link
+	var redisErr redis.Error
+	if errors.As(err, &redisErr) {
+		if redis.HasErrorPrefix(err, "WRONGTYPE") {
+			return cache.NewWrongTypeError(key)
+		}
+		return fmt.Errorf("%w: %w", cache.ErrInvalidValue, err)
+	}
+	return cache.NewConnectionError(err)
+}
+
+// toScriptError maps Eval errors, server replies there come from the script
+func toScriptError(err error) error {
+	var redisErr redis.Error
+	if errors.As(err, &redisErr) && !errors.Is(err, redis.Nil) && !isUnavailable(err) {
+		return cache.NewScriptError(err)
+	}
+	return toCacheError("", err)
+}
+
+// isUnavailable is true for replies of a server that cannot take the
+// command right now, a replica, a failover or a node still loading, which
+// callers treat like a lost connection
+func isUnavailable(err error) bool {
+	for _, prefix := range unavailablePrefixes {
+		if redis.HasErrorPrefix(err, prefix) {
+			return true
+		}
+	}
+	return false
 }
@@ -0,0 +1,317 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/brianwu291/go-learn/cache"
+)
+
+func newTestClient(t *testing.T) (*Client, *miniredis.Miniredis) {
+	t.Helper()
+
+	server := miniredis.RunT(t)
+	client, err := NewClient(&cache.Config{
+		Host: server.Host(),
+		Port: server.Port(),
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() { client.Close() })
+
+	return client, server
+}
+
+func TestClient_KeyOperations(t *testing.T) {
+	client, server := newTestClient(t)
+	ctx := context.Background()
+
+	t.Run("Get missing key", func(t *testing.T) {
+		_, err := client.Get(ctx, "missing")
+		assert.True(t, cache.IsKeyNotFound(err))
+	})
+
+	t.Run("SetNX only sets once", func(t *testing.T) {
+		ok, err := client.SetNX(ctx, "lock", "a", time.Minute)
+		require.NoError(t, err)
+		assert.True(t, ok)
+
+		ok, err = client.SetNX(ctx, "lock", "b", time.Minute)
+		require.NoError(t, err)
+		assert.False(t, ok)
+
+		val, _ := client.Get(ctx, "lock")
+		assert.Equal(t, "a", val)
+		assert.Equal(t, time.Minute, server.TTL("lock"))
+	})
+
+	t.Run("Del and Exists", func(t *testing.T) {
+		require.NoError(t, client.Set(ctx, "k1", "v1", 0))
+		require.NoError(t, client.Set(ctx, "k2", "v2", 0))
+
+		n, err := client.Exists(ctx, "k1", "k2", "k3")
+		require.NoError(t, err)
+		assert.Equal(t, int64(2), n)
+
+		n, err = client.Del(ctx, "k1", "k3")
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), n)
+
+		n, err = client.Exists(ctx, "k1")
+		require.NoError(t, err)
+		assert.Equal(t, int64(0), n)
+
+		n, err = client.Del(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, int64(0), n)
+	})
+
+	t.Run("MSet and MGet", func(t *testing.T) {
+		err := client.MSet(ctx, map[string]interface{}{
+			"m1": "one",
+			"m2": 2,
+		}, time.Hour)
+		require.NoError(t, err)
+		assert.Equal(t, time.Hour, server.TTL("m1"))
+
+		vals, err := client.MGet(ctx, "m1", "m2", "m3")
+		require.NoError(t, err)
+		assert.Equal(t, map[string]string{"m1": "one", "m2": "2"}, vals)
+	})
+
+	t.Run("MGet leaves out keys of other types", func(t *testing.T) {
+		server.HSet("m-hash", "field", "value")
+
+		vals, err := client.MGet(ctx, "m1", "m-hash")
+		require.NoError(t, err)
+		assert.Equal(t, map[string]string{"m1": "one"}, vals)
+	})
+}
+
+func TestClient_HashOperations(t *testing.T) {
+	client, _ := newTestClient(t)
+	ctx := context.Background()
+
+	added, err := client.HSet(ctx, "flags", map[string]interface{}{"a": "1", "b": "0"})
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), added)
+
+	val, err := client.HGet(ctx, "flags", "a")
+	require.NoError(t, err)
+	assert.Equal(t, "1", val)
+
+	_, err = client.HGet(ctx, "flags", "missing")
+	assert.True(t, cache.IsKeyNotFound(err))
+
+	n, err := client.HIncrBy(ctx, "flags", "b", 5)
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), n)
+
+	removed, err := client.HDel(ctx, "flags", "a", "missing")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), removed)
+
+	all, err := client.HGetAll(ctx, "flags")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"b": "5"}, all)
+
+	all, err = client.HGetAll(ctx, "missing")
+	require.NoError(t, err)
+	assert.Empty(t, all)
+}
+
+func TestClient_SortedSetOperations(t *testing.T) {
+	client, _ := newTestClient(t)
+	ctx := context.Background()
+
+	added, err := client.ZAdd(ctx, "scores",
+		cache.ZMember{Member: "a", Score: 1},
+		cache.ZMember{Member: "b", Score: 2},
+		cache.ZMember{Member: "c", Score: 3},
+	)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), added)
+
+	score, err := client.ZScore(ctx, "scores", "b")
+	require.NoError(t, err)
+	assert.Equal(t, float64(2), score)
+
+	_, err = client.ZScore(ctx, "scores", "missing")
+	assert.True(t, cache.IsKeyNotFound(err))
+
+	members, err := client.ZRange(ctx, "scores", 0, -1)
+	require.NoError(t, err)
+	assert.Equal(t, []cache.ZMember{{Member: "a", Score: 1}, {Member: "b", Score: 2}, {Member: "c", Score: 3}}, members)
+
+	members, err = client.ZRangeByScore(ctx, "scores", cache.ZRangeBy{Min: "(1", Max: "+inf"})
+	require.NoError(t, err)
+	assert.Equal(t, []cache.ZMember{{Member: "b", Score: 2}, {Member: "c", Score: 3}}, members)
+
+	members, err = client.ZRangeByScore(ctx, "scores", cache.ZRangeBy{Min: "-inf", Max: "+inf", Offset: 1, Count: 1})
+	require.NoError(t, err)
+	assert.Equal(t, []cache.ZMember{{Member: "b", Score: 2}}, members)
+
+	removed, err := client.ZRemRangeByScore(ctx, "scores", "-inf", "1")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), removed)
+
+	removed, err = client.ZRem(ctx, "scores", "c")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), removed)
+
+	card, err := client.ZCard(ctx, "scores")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), card)
+}
+
+func TestClient_ConnectionErrors(t *testing.T) {
+	client, server := newTestClient(t)
+	ctx := context.Background()
+
+	server.Close()
+
+	_, err := client.Get(ctx, "key")
+	assert.True(t, cache.IsConnectionError(err))
+
+	_, err = client.Del(ctx, "key")
+	assert.True(t, cache.IsConnectionError(err))
+
+	_, err = client.HGetAll(ctx, "key")
+	assert.True(t, cache.IsConnectionError(err))
+
+	_, err = client.ZAdd(ctx, "key", cache.ZMember{Member: "a", Score: 1})
+	assert.True(t, cache.IsConnectionError(err))
+
+	err = client.MSet(ctx, map[string]interface{}{"key": "value"}, 0)
+	assert.True(t, cache.IsConnectionError(err))
+}
+
+func TestClient_ErrorMapping(t *testing.T) {
+	client, server := newTestClient(t)
+	ctx := context.Background()
+
+	require.NoError(t, client.Set(ctx, "string", "not a number", 0))
+	_, err := client.HSet(ctx, "hash", map[string]interface{}{"a": 1})
+	require.NoError(t, err)
+
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	expired, cancelExpired := context.WithDeadline(ctx, time.Now().Add(-time.Second))
+	defer cancelExpired()
+
+	tests := []struct {
+		name  string
+		call  func() error
+		check func(error) bool
+	}{
+		{
+			name:  "Get on a missing key is not found",
+			call:  func() error { _, err := client.Get(ctx, "missing"); return err },
+			check: cache.IsKeyNotFound,
+		},
+		{
+			name:  "ZScore on a missing member is not found",
+			call:  func() error { _, err := client.ZScore(ctx, "missing", "a"); return err },
+			check: cache.IsKeyNotFound,
+		},
+		{
+			name:  "Get on a hash is wrong type",
+			call:  func() error { _, err := client.Get(ctx, "hash"); return err },
+			check: cache.IsWrongType,
+		},
+		{
+			name:  "Incr on a hash is wrong type",
+			call:  func() error { _, err := client.Incr(ctx, "hash"); return err },
+			check: cache.IsWrongType,
+		},
+		{
+			name: "Incr on text is an invalid value",
+			call: func() error { _, err := client.Incr(ctx, "string"); return err },
+			check: func(err error) bool {
+				return errors.Is(err, cache.ErrInvalidValue) && !cache.IsConnectionError(err)
+			},
+		},
+		{
+			name:  "Eval with a broken script is a script error",
+			call:  func() error { _, err := client.Eval(ctx, "return +", nil, nil); return err },
+			check: cache.IsScriptError,
+		},
+		{
+			name: "Eval failing inside the script is a script error",
+			call: func() error {
+				_, err := client.Eval(ctx, "return redis.call('INCR', KEYS[1])", []string{"hash"}, nil)
+				return err
+			},
+			check: cache.IsScriptError,
+		},
+		{
+			name:  "Eval returning nil is not found",
+			call:  func() error { _, err := client.Eval(ctx, "return nil", nil, nil); return err },
+			check: cache.IsKeyNotFound,
+		},
+		{
+			name:  "Cancelled context is canceled",
+			call:  func() error { return client.Set(canceled, "key", "value", 0) },
+			check: func(err error) bool { return cache.IsCanceled(err) && errors.Is(err, context.Canceled) },
+		},
+		{
+			name:  "Expired deadline is a timeout and a connection error",
+			call:  func() error { _, err := client.TTL(expired, "key"); return err },
+			check: func(err error) bool { return cache.IsTimeout(err) && cache.IsConnectionError(err) },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.call()
+			require.Error(t, err)
+			assert.True(t, tt.check(err), "unexpected error: %v", err)
+		})
+	}
+
+	t.Run("Every operation reports connection errors", func(t *testing.T) {
+		server.Close()
+
+		calls := map[string]func() error{
+			"set":    func() error { return client.Set(ctx, "key", "value", 0) },
+			"incr":   func() error { _, err := client.Incr(ctx, "key"); return err },
+			"ttl":    func() error { _, err := client.TTL(ctx, "key"); return err },
+			"expire": func() error { return client.Expire(ctx, "key", time.Minute) },
+			"eval":   func() error { _, err := client.Eval(ctx, "return 1", nil, nil); return err },
+			"pipeline": func() error {
+				pipe := client.Pipeline()
+				pipe.Incr(ctx, "key")
+				return pipe.Exec(ctx)
+			},
+		}
+		for name, call := range calls {
+			err := call()
+			assert.True(t, cache.IsConnectionError(err), "%s: %v", name, err)
+			assert.False(t, cache.IsScriptError(err), "%s: %v", name, err)
+		}
+	})
+}
+
+func TestClient_UnavailableErrors(t *testing.T) {
+	ctx := context.Background()
+
+	for _, prefix := range []string{"READONLY", "LOADING", "CLUSTERDOWN", "MASTERDOWN", "TRYAGAIN"} {
+		t.Run(prefix, func(t *testing.T) {
+			client, server := newTestClient(t)
+			server.SetError(prefix + " the server cannot take writes right now")
+
+			err := client.Set(ctx, "key", "value", 0)
+			assert.True(t, cache.IsConnectionError(err), "set: %v", err)
+			assert.False(t, errors.Is(err, cache.ErrInvalidValue))
+
+			_, err = client.Eval(ctx, "return 1", nil, nil)
+			assert.True(t, cache.IsConnectionError(err), "eval: %v", err)
+			assert.False(t, cache.IsScriptError(err))
+		})
+	}
+}
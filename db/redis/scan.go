@@ -0,0 +1,59 @@
+package redis
+
+import (
+	"context"
+	"sync"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// scanBatchSize is the COUNT hint per SCAN call, small enough to never
+// block the server for long
+const scanBatchSize = 500
+
+// ScanKeys walks the keyspace with SCAN, on every master in cluster mode
+func (c *Client) ScanKeys(ctx context.Context, pattern string) ([]string, error) {
+	cluster, ok := c.client.(*redis.ClusterClient)
+	if !ok {
+		keys, err := scanNode(ctx, c.client, pattern)
+		if err != nil {
+			return nil, toCacheError(pattern, err)
+		}
+		return keys, nil
+	}
+
+	var mu sync.Mutex
+	var keys []string
+	err := cluster.ForEachMaster(ctx, func(ctx context.Context, node *redis.Client) error {
+		nodeKeys, err := scanNode(ctx, node, pattern)
+		if err != nil {
+			return err
+		}
+		mu.Lock()
+		keys = append(keys, nodeKeys...)
+		mu.Unlock()
+		return nil
+	})
+	if err != nil {
+		return nil, toCacheError(pattern, err)
+	}
+	return keys, nil
+}
+
+// scanNode drops duplicates, SCAN only guarantees every key is returned
+// at least once
+func scanNode(ctx context.Context, client redis.Cmdable, pattern string) ([]string, error) {
+	seen := make(map[string]struct{})
+	var keys []string
+
+	iter := client.Scan(ctx, 0, pattern, scanBatchSize).Iterator()
+	for iter.Next(ctx) {
+		key := iter.Val()
+		if _, ok := seen[key]; ok {
+			continue
+		}
+		seen[key] = struct{}{}
+		keys = append(keys, key)
+	}
+	return keys, iter.Err()
+}
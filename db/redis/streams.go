@@ -0,0 +1,144 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/brianwu291/go-learn/cache"
+)
+
+func (c *Client) XAdd(ctx context.Context, stream string, values map[string]interface{}) (string, error) {
+	id, err := c.client.XAdd(ctx, &redis.XAddArgs{
+		Stream: stream,
+		Values: values,
+	}).Result()
+	if err != nil {
+		return "", toCacheError(stream, err)
+	}
+	return id, nil
+}
+
+func (c *Client) XGroupCreate(ctx context.Context, stream, group, start string) error {
+	err := c.client.XGroupCreateMkStream(ctx, stream, group, start).Err()
+	if err != nil && !redis.HasErrorPrefix(err, "BUSYGROUP") {
+		return toCacheError(stream, err)
+	}
+	return nil
+}
+
+func (c *Client) XReadGroup(ctx context.Context, stream, group, consumer string, count int64, block time.Duration) ([]cache.StreamMessage, error) {
+	streams, err := c.client.XReadGroup(ctx, &redis.XReadGroupArgs{
+		Group:    group,
+		Consumer: consumer,
+		Streams:  []string{stream, ">"},
+		Count:    count,
+		Block:    block,
+	}).Result()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, toCacheError(stream, err)
+	}
+
+	var messages []cache.StreamMessage
+	for _, s := range streams {
+		messages = append(messages, toStreamMessages(s.Messages)...)
+	}
+	return messages, nil
+}
+
+func (c *Client) XAck(ctx context.Context, stream, group string, ids ...string) (int64, error) {
+	if len(ids) == 0 {
+		return 0, nil
+	}
+	n, err := c.client.XAck(ctx, stream, group, ids...).Result()
+	if err != nil {
+		return 0, toCacheError(stream, err)
+	}
+	return n, nil
+}
+
+func (c *Client) XDel(ctx context.Context, stream string, ids ...string) (int64, error) {
+	if len(ids) == 0 {
+		return 0, nil
+	}
+	n, err := c.client.XDel(ctx, stream, ids...).Result()
+	if err != nil {
+		return 0, toCacheError(stream, err)
+	}
+	return n, nil
+}
+
+func (c *Client) XPending(ctx context.Context, stream, group string, minIdle time.Duration, count int64) ([]cache.PendingEntry, error) {
+	pending, err := c.client.XPendingExt(ctx, &redis.XPendingExtArgs{
+		Stream: stream,
+		Group:  group,
+		Idle:   minIdle,
+		Start:  "-",
+		End:    "+",
+		Count:  count,
+	}).Result()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, toCacheError(stream, err)
+	}
+
+	entries := make([]cache.PendingEntry, 0, len(pending))
+	for _, p := range pending {
+		entries = append(entries, cache.PendingEntry{
+			ID:         p.ID,
+			Consumer:   p.Consumer,
+			Idle:       p.Idle,
+			Deliveries: p.RetryCount,
+		})
+	}
+	return entries, nil
+}
+
+func (c *Client) XClaim(ctx context.Context, stream, group, consumer string, minIdle time.Duration, ids ...string) ([]cache.StreamMessage, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	messages, err := c.client.XClaim(ctx, &redis.XClaimArgs{
+		Stream:   stream,
+		Group:    group,
+		Consumer: consumer,
+		MinIdle:  minIdle,
+		Messages: ids,
+	}).Result()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, toCacheError(stream, err)
+	}
+	return toStreamMessages(messages), nil
+}
+
+func (c *Client) XLen(ctx context.Context, stream string) (int64, error) {
+	n, err := c.client.XLen(ctx, stream).Result()
+	if err != nil {
+		return 0, toCacheError(stream, err)
+	}
+	return n, nil
+}
+
+// toStreamMessages flattens field values, Redis returns them as strings
+func toStreamMessages(messages []redis.XMessage) []cache.StreamMessage {
+	result := make([]cache.StreamMessage, 0, len(messages))
+	for _, m := range messages {
+		values := make(map[string]string, len(m.Values))
+		for field, value := range m.Values {
+			values[field] = fmt.Sprint(value)
+		}
+		result = append(result, cache.StreamMessage{ID: m.ID, Values: values})
+	}
+	return result
+}
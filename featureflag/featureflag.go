@@ -0,0 +1,283 @@
+// Package featureflag turns features on per client without a redeploy.
+// Flags live in one cache hash, every instance keeps a copy in memory and
+// reloads it when another instance publishes a change.
+package featureflag
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/brianwu291/go-learn/cache"
+)
+
+type (
+	// Flag is on for a client when Enabled and the client is either in
+	// Allowlist or in the Percentage rollout. A plain boolean flag uses
+	// Percentage 100.
+	Flag struct {
+		Name        string `json:"name"`
+		Description string `json:"description,omitempty"`
+		Enabled     bool   `json:"enabled"`
+		// Percentage of clients, 0 to 100, picked by a stable hash of the
+		// flag name and client ID so a client keeps its answer
+		Percentage int       `json:"percentage"`
+		Allowlist  []string  `json:"allowlist,omitempty"`
+		UpdatedAt  time.Time `json:"updatedAt"`
+	}
+
+	Config struct {
+		// RefreshInterval reloads the flags even without a change message,
+		// in case one was missed
+		RefreshInterval time.Duration
+	}
+
+	Store struct {
+		client  cache.Client
+		pubsub  cache.PubSub
+		config  Config
+		key     string
+		channel string
+
+		mu    sync.RWMutex
+		flags map[string]Flag
+	}
+)
+
+const defaultRefreshInterval = 30 * time.Second
+
+var (
+	// ErrInvalidFlag is returned by Set for a flag it cannot store
+	ErrInvalidFlag = errors.New("invalid feature flag")
+	// ErrUnknownFlag is returned for a flag that does not exist
+	ErrUnknownFlag = errors.New("unknown feature flag")
+
+	featureFlagsCacheFamily = cache.KeyFamily{
+		Name:        "featureFlags",
+		Version:     1,
+		Description: "feature flags as JSON per hash field, never expire",
+		Protected:   true,
+	}
+)
+
+// NewStore keeps the flags in a hash of client. pubsub spreads changes to
+// the other instances, without it they only see changes on refresh.
+func NewStore(client cache.Client, pubsub cache.PubSub, cacheKeys *cache.KeyBuilder, config Config) *Store {
+	if config.RefreshInterval <= 0 {
+		config.RefreshInterval = defaultRefreshInterval
+	}
+
+	return &Store{
+		client:  client,
+		pubsub:  pubsub,
+		config:  config,
+		key:     cacheKeys.Register(featureFlagsCacheFamily).Key("all"),
+		channel: cacheKeys.Tag(featureFlagsCacheFamily.Name, "changed"),
+		flags:   make(map[string]Flag),
+	}
+}
+
+// Start loads the flags and keeps them fresh until ctx is done. It
+// subscribes before loading so no change falls in between. A failed first
+// load is returned, every flag is then off until a refresh works.
+func (s *Store) Start(ctx context.Context) error {
+	sub := s.subscribe(ctx)
+	err := s.Refresh(ctx)
+	go s.watch(ctx, sub)
+	return err
+}
+
+// Refresh reloads every flag from the cache
+func (s *Store) Refresh(ctx context.Context) error {
+	values, err := s.client.HGetAll(ctx, s.key)
+	if err != nil && !cache.IsKeyNotFound(err) {
+		return fmt.Errorf("loading feature flags: %w", err)
+	}
+
+	flags := make(map[string]Flag, len(values))
+	for name, value := range values {
+		var flag Flag
+		if err := json.Unmarshal([]byte(value), &flag); err != nil {
+			fmt.Printf("skipping corrupt feature flag %s: %+v\n", name, err)
+			continue
+		}
+		flags[name] = flag
+	}
+
+	s.mu.Lock()
+	s.flags = flags
+	s.mu.Unlock()
+	return nil
+}
+
+// Enabled answers from memory, unknown flags are off
+func (s *Store) Enabled(name, clientID string) bool {
+	flag, ok := s.Flag(name)
+	return ok && flag.EnabledFor(clientID)
+}
+
+func (s *Store) Flag(name string) (Flag, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	flag, ok := s.flags[name]
+	return flag, ok
+}
+
+// Flags lists every flag sorted by name
+func (s *Store) Flags() []Flag {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	flags := make([]Flag, 0, len(s.flags))
+	for _, flag := range s.flags {
+		flags = append(flags, flag)
+	}
+	sort.Slice(flags, func(i, j int) bool {
+		return flags[i].Name < flags[j].Name
+	})
+	return flags
+}
+
+// Set creates or replaces a flag and tells the other instances
+func (s *Store) Set(ctx context.Context, flag Flag) (Flag, error) {
+	if err := flag.validate(); err != nil {
+		return Flag{}, err
+	}
+	flag.UpdatedAt = time.Now().UTC()
+
+	data, err := json.Marshal(flag)
+	if err != nil {
+		return Flag{}, fmt.Errorf("%w: %v", ErrInvalidFlag, err)
+	}
+	if _, err := s.client.HSet(ctx, s.key, map[string]interface{}{flag.Name: string(data)}); err != nil {
+		return Flag{}, fmt.Errorf("saving feature flag %s: %w", flag.Name, err)
+	}
+
+	s.mu.Lock()
+	s.flags[flag.Name] = flag
+	s.mu.Unlock()
+
+	s.publish(ctx, flag.Name)
+	return flag, nil
+}
+
+func (s *Store) Delete(ctx context.Context, name string) error {
+	removed, err := s.client.HDel(ctx, s.key, name)
+	if err != nil {
+		return fmt.Errorf("deleting feature flag %s: %w", name, err)
+	}
+
+	s.mu.Lock()
+	_, known := s.flags[name]
+	delete(s.flags, name)
+	s.mu.Unlock()
+
+	if removed == 0 && !known {
+		return fmt.Errorf("%w: %s", ErrUnknownFlag, name)
+	}
+	s.publish(ctx, name)
+	return nil
+}
+
+// EnabledFor evaluates the flag for one client
+func (f Flag) EnabledFor(clientID string) bool {
+	if !f.Enabled {
+		return false
+	}
+	if slices.Contains(f.Allowlist, clientID) {
+		return true
+	}
+	if f.Percentage >= 100 {
+		return true
+	}
+	return f.Percentage > 0 && bucket(f.Name, clientID) < f.Percentage
+}
+
+func (f Flag) validate() error {
+	if f.Name == "" {
+		return fmt.Errorf("%w: name is required", ErrInvalidFlag)
+	}
+	if f.Percentage < 0 || f.Percentage > 100 {
+		return fmt.Errorf("%w: percentage must be between 0 and 100", ErrInvalidFlag)
+	}
+	return nil
+}
+
+// bucket spreads clients over 0-99, salted with the flag name so the same
+// clients are not always the first to get every feature
+func bucket(name, clientID string) int {
+	h := fnv.New32a()
+	h.Write([]byte(name))
+	h.Write([]byte{':'})
+	h.Write([]byte(clientID))
+	return int(h.Sum32() % 100)
+}
+
+// publish is best effort, the others still catch up on refresh
+func (s *Store) publish(ctx context.Context, name string) {
+	if s.pubsub == nil {
+		return
+	}
+	if _, err := s.pubsub.Publish(ctx, s.channel, name); err != nil {
+		fmt.Printf("failed to publish feature flag change %s: %+v\n", name, err)
+	}
+}
+
+// watch reloads on change messages and every RefreshInterval, and
+// subscribes again when the subscription drops
+func (s *Store) watch(ctx context.Context, sub cache.Subscription) {
+	ticker := time.NewTicker(s.config.RefreshInterval)
+	defer ticker.Stop()
+
+	defer func() {
+		if sub != nil {
+			sub.Close()
+		}
+	}()
+
+	for ctx.Err() == nil {
+		var messages <-chan cache.Message
+		if sub == nil {
+			sub = s.subscribe(ctx)
+		}
+		if sub != nil {
+			messages = sub.Messages()
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case _, ok := <-messages:
+			if !ok {
+				// changes may have been missed while it was down, close it
+				// before subscribing again so it does not keep a connection
+				sub.Close()
+				sub = nil
+			}
+		}
+
+		if err := s.Refresh(ctx); err != nil && ctx.Err() == nil {
+			fmt.Printf("failed to refresh feature flags: %+v\n", err)
+		}
+	}
+}
+
+func (s *Store) subscribe(ctx context.Context) cache.Subscription {
+	if s.pubsub == nil {
+		return nil
+	}
+	sub, err := s.pubsub.Subscribe(ctx, s.channel)
+	if err != nil {
+		fmt.Printf("failed to subscribe to feature flag changes: %+v\n", err)
+		return nil
+	}
+	return sub
+}
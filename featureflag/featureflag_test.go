@@ -0,0 +1,187 @@
+package featureflag
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/brianwu291/go-learn/cache"
+	"github.com/brianwu291/go-learn/cache/memory"
+)
+
+// droppingPubSub hands out subscriptions the test can drop, as a broken
+// connection would, and counts the ones closed
+type droppingPubSub struct {
+	cache.PubSub
+
+	mu     sync.Mutex
+	subs   []*droppingSubscription
+	closed int
+}
+
+type droppingSubscription struct {
+	pubsub   *droppingPubSub
+	messages chan cache.Message
+	once     sync.Once
+}
+
+func (p *droppingPubSub) Subscribe(ctx context.Context, channels ...string) (cache.Subscription, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	sub := &droppingSubscription{pubsub: p, messages: make(chan cache.Message)}
+	p.subs = append(p.subs, sub)
+	return sub, nil
+}
+
+func (p *droppingPubSub) drop() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	close(p.subs[len(p.subs)-1].messages)
+}
+
+func (p *droppingPubSub) counts() (subscribed, closed int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.subs), p.closed
+}
+
+func (s *droppingSubscription) Messages() <-chan cache.Message {
+	return s.messages
+}
+
+func (s *droppingSubscription) Close() error {
+	s.once.Do(func() {
+		s.pubsub.mu.Lock()
+		s.pubsub.closed += 1
+		s.pubsub.mu.Unlock()
+	})
+	return nil
+}
+
+func TestFlag_EnabledFor(t *testing.T) {
+	tests := []struct {
+		name     string
+		flag     Flag
+		clientID string
+		expected bool
+	}{
+		{name: "Disabled flag is off for everyone", flag: Flag{Name: "f", Percentage: 100, Allowlist: []string{"a"}}, clientID: "a", expected: false},
+		{name: "Boolean flag is on for everyone", flag: Flag{Name: "f", Enabled: true, Percentage: 100}, clientID: "a", expected: true},
+		{name: "Allowlisted client outside the rollout", flag: Flag{Name: "f", Enabled: true, Allowlist: []string{"a"}}, clientID: "a", expected: true},
+		{name: "Other client outside the rollout", flag: Flag{Name: "f", Enabled: true, Allowlist: []string{"a"}}, clientID: "b", expected: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, tt.flag.EnabledFor(tt.clientID))
+		})
+	}
+
+	t.Run("Percentage rollout is stable and close to the target", func(t *testing.T) {
+		flag := Flag{Name: "new-checkout", Enabled: true, Percentage: 25}
+
+		on := 0
+		for i := 0; i < 10000; i++ {
+			clientID := fmt.Sprintf("client-%d", i)
+			enabled := flag.EnabledFor(clientID)
+			assert.Equal(t, enabled, flag.EnabledFor(clientID), "same answer for %s", clientID)
+			if enabled {
+				on += 1
+			}
+		}
+		assert.InDelta(t, 2500, on, 250)
+	})
+}
+
+func TestStore(t *testing.T) {
+	ctx := context.Background()
+
+	newStores := func(t *testing.T) (*Store, *Store) {
+		client := memory.NewClient()
+		config := Config{RefreshInterval: time.Hour}
+		// two instances sharing one cache
+		first := NewStore(client, client, cache.NewKeyBuilder("test", "dev"), config)
+		second := NewStore(client, client, cache.NewKeyBuilder("test", "dev"), config)
+
+		startCtx, cancel := context.WithCancel(ctx)
+		t.Cleanup(cancel)
+		require.NoError(t, first.Start(startCtx))
+		require.NoError(t, second.Start(startCtx))
+		return first, second
+	}
+
+	t.Run("Spreads changes to other instances", func(t *testing.T) {
+		first, second := newStores(t)
+
+		saved, err := first.Set(ctx, Flag{Name: "new-checkout", Enabled: true, Percentage: 100})
+		require.NoError(t, err)
+		assert.False(t, saved.UpdatedAt.IsZero())
+		assert.True(t, first.Enabled("new-checkout", "client-1"))
+
+		require.Eventually(t, func() bool {
+			return second.Enabled("new-checkout", "client-1")
+		}, time.Second, 5*time.Millisecond)
+
+		require.NoError(t, first.Delete(ctx, "new-checkout"))
+		require.Eventually(t, func() bool {
+			_, ok := second.Flag("new-checkout")
+			return !ok
+		}, time.Second, 5*time.Millisecond)
+	})
+
+	t.Run("Loads existing flags on start", func(t *testing.T) {
+		client := memory.NewClient()
+		keys := cache.NewKeyBuilder("test", "dev")
+		writer := NewStore(client, nil, keys, Config{})
+		_, err := writer.Set(ctx, Flag{Name: "b", Enabled: true, Percentage: 100})
+		require.NoError(t, err)
+		_, err = writer.Set(ctx, Flag{Name: "a"})
+		require.NoError(t, err)
+
+		reader := NewStore(client, nil, keys, Config{})
+		startCtx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		require.NoError(t, reader.Start(startCtx))
+
+		flags := reader.Flags()
+		require.Len(t, flags, 2)
+		assert.Equal(t, "a", flags[0].Name)
+		assert.Equal(t, "b", flags[1].Name)
+	})
+
+	t.Run("Rejects invalid flags and unknown deletes", func(t *testing.T) {
+		first, _ := newStores(t)
+
+		_, err := first.Set(ctx, Flag{Name: "", Enabled: true})
+		assert.ErrorIs(t, err, ErrInvalidFlag)
+		_, err = first.Set(ctx, Flag{Name: "f", Percentage: 101})
+		assert.ErrorIs(t, err, ErrInvalidFlag)
+		assert.ErrorIs(t, first.Delete(ctx, "missing"), ErrUnknownFlag)
+	})
+
+	t.Run("Closes a dropped subscription before subscribing again", func(t *testing.T) {
+		pubsub := &droppingPubSub{}
+		store := NewStore(memory.NewClient(), pubsub, cache.NewKeyBuilder("test", "dev"), Config{RefreshInterval: time.Hour})
+		startCtx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		require.NoError(t, store.Start(startCtx))
+
+		for i := 1; i <= 2; i++ {
+			pubsub.drop()
+			require.Eventually(t, func() bool {
+				subscribed, closed := pubsub.counts()
+				return subscribed == i+1 && closed == i
+			}, time.Second, 5*time.Millisecond)
+		}
+	})
+
+	t.Run("Unknown flags are off", func(t *testing.T) {
+		first, _ := newStores(t)
+		assert.False(t, first.Enabled("missing", "client-1"))
+	})
+}
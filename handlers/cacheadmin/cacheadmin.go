@@ -0,0 +1,104 @@
+package cacheadminhandler
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	gin "github.com/gin-gonic/gin"
+
+	"github.com/brianwu291/go-learn/cache"
+	constants "github.com/brianwu291/go-learn/constants"
+	cacheadminservice "github.com/brianwu291/go-learn/services/cacheadmin"
+	types "github.com/brianwu291/go-learn/types"
+)
+
+type (
+	CacheAdminHandler struct {
+		service cacheadminservice.CacheAdminService
+	}
+)
+
+func NewCacheAdminHandler(service cacheadminservice.CacheAdminService) *CacheAdminHandler {
+	return &CacheAdminHandler{
+		service: service,
+	}
+}
+
+func (h *CacheAdminHandler) ListFamilies(c *gin.Context) {
+	families, err := h.service.ListFamilies(c)
+	if err != nil {
+		h.abortWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, families)
+}
+
+// GetEntry reads the key from the query, keys contain ':' and are awkward
+// as path params
+func (h *CacheAdminHandler) GetEntry(c *gin.Context) {
+	key, ok := requiredKey(c)
+	if !ok {
+		return
+	}
+
+	entry, err := h.service.GetEntry(c, key)
+	if err != nil {
+		h.abortWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, entry)
+}
+
+func (h *CacheAdminHandler) PurgeEntry(c *gin.Context) {
+	key, ok := requiredKey(c)
+	if !ok {
+		return
+	}
+
+	result, err := h.service.PurgeKey(c, key)
+	if err != nil {
+		h.abortWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+func (h *CacheAdminHandler) PurgeFamily(c *gin.Context) {
+	result, err := h.service.PurgeFamily(c, c.Param("name"))
+	if err != nil {
+		h.abortWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+func (h *CacheAdminHandler) WarmUp(c *gin.Context) {
+	c.JSON(http.StatusOK, h.service.WarmUp(c))
+}
+
+func requiredKey(c *gin.Context) (string, bool) {
+	key := c.Query("key")
+	if key == "" {
+		c.AbortWithStatusJSON(http.StatusBadRequest, types.BadRequestResponse{Message: "key query parameter is required"})
+		return "", false
+	}
+	return key, true
+}
+
+func (h *CacheAdminHandler) abortWithError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, cacheadminservice.ErrForeignKey), errors.Is(err, cacheadminservice.ErrProtectedFamily):
+		c.AbortWithStatusJSON(http.StatusBadRequest, types.BadRequestResponse{Message: err.Error()})
+	case errors.Is(err, cacheadminservice.ErrUnknownFamily), cache.IsKeyNotFound(err):
+		c.AbortWithStatusJSON(http.StatusNotFound, types.NotFoundResponse{Message: err.Error()})
+	default:
+		fmt.Printf("cache admin request failed: %+v\n", err)
+		internalServerErr := fmt.Errorf(constants.InternalServerErrorMessage)
+		c.AbortWithStatusJSON(http.StatusInternalServerError, types.InternalServerErrorResponse{Message: internalServerErr.Error()})
+	}
+}
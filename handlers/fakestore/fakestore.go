@@ -1,12 +1,16 @@
 package fakestorehandler
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
+	"strconv"
 
 	gin "github.com/gin-gonic/gin"
 
 	constants "github.com/brianwu291/go-learn/constants"
+	httpclient "github.com/brianwu291/go-learn/httpclient"
+	fakestorerepo "github.com/brianwu291/go-learn/repos/fakestore"
 	fakestoreservice "github.com/brianwu291/go-learn/services/fakestore"
 	types "github.com/brianwu291/go-learn/types"
 )
@@ -34,6 +38,29 @@ func (h *FakeStoreHandler) GetAllCategories(c *gin.Context) {
 	c.JSON(http.StatusOK, response)
 }
 
+// GetProduct answers 404 when the fake store API does not know the id
+func (h *FakeStoreHandler) GetProduct(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil || id <= 0 {
+		c.AbortWithStatusJSON(http.StatusBadRequest, types.BadRequestResponse{Message: "id must be a positive integer"})
+		return
+	}
+
+	product, err := h.service.GetProduct(c, id)
+	if errors.Is(err, fakestorerepo.ErrProductNotFound) || httpclient.IsNotFound(err) {
+		c.AbortWithStatusJSON(http.StatusNotFound, types.NotFoundResponse{Message: fmt.Sprintf("product %d not found", id)})
+		return
+	}
+	if err != nil {
+		fmt.Printf("failed to get product %d: %+v\n", id, err)
+		internalServerErr := fmt.Errorf(constants.InternalServerErrorMessage)
+		c.AbortWithStatusJSON(http.StatusInternalServerError, types.InternalServerErrorResponse{Message: internalServerErr.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, product)
+}
+
 func (h *FakeStoreHandler) GetAllCategoriesProducts(c *gin.Context) {
 	allCategories, err := h.service.GetCategories(c, false)
 	if err != nil {
@@ -0,0 +1,109 @@
+package fakestorehandler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/brianwu291/go-learn/httpclient"
+	fakestorerepo "github.com/brianwu291/go-learn/repos/fakestore"
+	"github.com/brianwu291/go-learn/types"
+)
+
+type mockFakeStoreService struct {
+	mock.Mock
+}
+
+func (m *mockFakeStoreService) GetCategories(ctx context.Context, skipCache bool) ([]types.Category, error) {
+	args := m.Called(skipCache)
+	return args.Get(0).([]types.Category), args.Error(1)
+}
+
+func (m *mockFakeStoreService) GetProductsByCategory(ctx context.Context, category types.Category) ([]types.Product, error) {
+	args := m.Called(category)
+	return args.Get(0).([]types.Product), args.Error(1)
+}
+
+func (m *mockFakeStoreService) GetProduct(ctx context.Context, id int64) (*types.Product, error) {
+	args := m.Called(id)
+	product, _ := args.Get(0).(*types.Product)
+	return product, args.Error(1)
+}
+
+func (m *mockFakeStoreService) InvalidateCategory(ctx context.Context, category types.Category) error {
+	return m.Called(category).Error(0)
+}
+
+func TestFakeStoreHandler_GetProduct(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	upstreamNotFound := fmt.Errorf("get product failed 99: %w", &httpclient.HTTPError{StatusCode: http.StatusNotFound})
+
+	tests := []struct {
+		name           string
+		id             string
+		setupMock      func(*mockFakeStoreService)
+		expectedStatus int
+	}{
+		{
+			name: "Returns the product",
+			id:   "1",
+			setupMock: func(m *mockFakeStoreService) {
+				m.On("GetProduct", int64(1)).Return(&types.Product{ID: 1}, nil)
+			},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name: "Maps an upstream 404 to 404",
+			id:   "99",
+			setupMock: func(m *mockFakeStoreService) {
+				m.On("GetProduct", int64(99)).Return(nil, upstreamNotFound)
+			},
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name: "Maps an unknown product to 404",
+			id:   "9999",
+			setupMock: func(m *mockFakeStoreService) {
+				m.On("GetProduct", int64(9999)).Return(nil, fmt.Errorf("%w: 9999", fakestorerepo.ErrProductNotFound))
+			},
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name: "Keeps other upstream errors a 500",
+			id:   "2",
+			setupMock: func(m *mockFakeStoreService) {
+				m.On("GetProduct", int64(2)).Return(nil, errors.New("connection refused"))
+			},
+			expectedStatus: http.StatusInternalServerError,
+		},
+		{
+			name:           "Rejects an invalid id",
+			id:             "abc",
+			setupMock:      func(m *mockFakeStoreService) {},
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service := new(mockFakeStoreService)
+			tt.setupMock(service)
+			handler := NewFakeStoreHandler(service)
+
+			router := gin.New()
+			router.GET("/fake-store/products/:id", handler.GetProduct)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/fake-store/products/"+tt.id, nil))
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			service.AssertExpectations(t)
+		})
+	}
+}
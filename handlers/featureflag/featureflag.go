@@ -0,0 +1,80 @@
+package featureflaghandler
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	gin "github.com/gin-gonic/gin"
+
+	constants "github.com/brianwu291/go-learn/constants"
+	featureflag "github.com/brianwu291/go-learn/featureflag"
+	types "github.com/brianwu291/go-learn/types"
+)
+
+type (
+	FeatureFlagHandler struct {
+		store *featureflag.Store
+	}
+)
+
+func NewFeatureFlagHandler(store *featureflag.Store) *FeatureFlagHandler {
+	return &FeatureFlagHandler{
+		store: store,
+	}
+}
+
+func (h *FeatureFlagHandler) ListFlags(c *gin.Context) {
+	c.JSON(http.StatusOK, h.store.Flags())
+}
+
+func (h *FeatureFlagHandler) GetFlag(c *gin.Context) {
+	flag, ok := h.store.Flag(c.Param("name"))
+	if !ok {
+		c.AbortWithStatusJSON(http.StatusNotFound, types.NotFoundResponse{Message: featureflag.ErrUnknownFlag.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, flag)
+}
+
+// PutFlag creates or replaces the flag. Percentage defaults to 100, so
+// {"enabled": true} is a plain boolean flag.
+func (h *FeatureFlagHandler) PutFlag(c *gin.Context) {
+	flag := featureflag.Flag{Percentage: 100}
+	if err := c.ShouldBindJSON(&flag); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, types.BadRequestResponse{Message: err.Error()})
+		return
+	}
+	flag.Name = c.Param("name")
+
+	saved, err := h.store.Set(c, flag)
+	if err != nil {
+		h.abortWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, saved)
+}
+
+func (h *FeatureFlagHandler) DeleteFlag(c *gin.Context) {
+	if err := h.store.Delete(c, c.Param("name")); err != nil {
+		h.abortWithError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func (h *FeatureFlagHandler) abortWithError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, featureflag.ErrInvalidFlag):
+		c.AbortWithStatusJSON(http.StatusBadRequest, types.BadRequestResponse{Message: err.Error()})
+	case errors.Is(err, featureflag.ErrUnknownFlag):
+		c.AbortWithStatusJSON(http.StatusNotFound, types.NotFoundResponse{Message: err.Error()})
+	default:
+		fmt.Printf("feature flag request failed: %+v\n", err)
+		internalServerErr := fmt.Errorf(constants.InternalServerErrorMessage)
+		c.AbortWithStatusJSON(http.StatusInternalServerError, types.InternalServerErrorResponse{Message: internalServerErr.Error()})
+	}
+}
@@ -0,0 +1,247 @@
+package httpclient
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+type (
+	// authenticator sets the credentials on every attempt
+	authenticator interface {
+		apply(req *http.Request) error
+		// refresh drops the credentials req was sent with after a 401 and
+		// reports whether new ones are worth one more attempt
+		refresh(req *http.Request) bool
+	}
+
+	staticHeader struct {
+		name  string
+		value string
+	}
+
+	// Token is an access token, a zero ExpiresAt never expires
+	Token struct {
+		Value     string
+		ExpiresAt time.Time
+	}
+
+	TokenFetcher func(ctx context.Context) (Token, error)
+
+	// TokenSource caches the token fetch returns until shortly before it
+	// expires. Concurrent callers wait for a single fetch, each only as
+	// long as its own context allows.
+	TokenSource struct {
+		fetch  TokenFetcher
+		leeway time.Duration
+		now    func() time.Time
+
+		mu    sync.Mutex
+		token Token
+		call  *tokenCall
+	}
+
+	// tokenCall is a fetch in flight, done is closed once token or err
+	// is set
+	tokenCall struct {
+		done  chan struct{}
+		token Token
+		err   error
+	}
+
+	tokenAuth struct {
+		source *TokenSource
+	}
+
+	loginResponse struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+)
+
+// tokenLeeway refreshes tokens this long before they expire, so they do
+// not expire on the way to the upstream
+const (
+	tokenLeeway = 30 * time.Second
+	// tokenFetchTimeout bounds a fetch, it outlives the caller that
+	// started it since other callers may be waiting for it
+	tokenFetchTimeout = 30 * time.Second
+)
+
+var ErrEmptyToken = errors.New("login returned no token")
+
+// WithBearerToken sends Authorization: Bearer token
+func WithBearerToken(token string) Option {
+	return withAuth(staticHeader{name: "Authorization", value: "Bearer " + token})
+}
+
+// WithBasicAuth sends Authorization: Basic with username and password
+func WithBasicAuth(username, password string) Option {
+	credentials := base64.StdEncoding.EncodeToString([]byte(username + ":" + password))
+	return withAuth(staticHeader{name: "Authorization", value: "Basic " + credentials})
+}
+
+// WithAPIKey sends key in header, e.g. X-API-Key
+func WithAPIKey(header, key string) Option {
+	return withAuth(staticHeader{name: header, value: key})
+}
+
+// WithTokenSource sends a bearer token from source. On a 401 the token is
+// dropped and the request sent once more with a new one.
+func WithTokenSource(source *TokenSource) Option {
+	return withAuth(tokenAuth{source: source})
+}
+
+// withAuth sets the client credentials, responses to authenticated
+// requests are then kept out of the shared response cache
+func withAuth(auth authenticator) Option {
+	return func(c *Client) {
+		c.auth = auth
+	}
+}
+
+// NewTokenSource caches the tokens of fetch, see Login for a fetch calling
+// a login endpoint
+func NewTokenSource(fetch TokenFetcher) *TokenSource {
+	return &TokenSource{
+		fetch:  fetch,
+		leeway: tokenLeeway,
+		now:    time.Now,
+	}
+}
+
+// Token returns the cached token or waits for a new one, joining the
+// fetch already in flight if there is one
+func (s *TokenSource) Token(ctx context.Context) (string, error) {
+	s.mu.Lock()
+	if s.valid() {
+		token := s.token.Value
+		s.mu.Unlock()
+		return token, nil
+	}
+	call := s.call
+	if call == nil {
+		call = &tokenCall{done: make(chan struct{})}
+		s.call = call
+		go s.run(ctx, call)
+	}
+	s.mu.Unlock()
+
+	select {
+	case <-ctx.Done():
+		return "", ctx.Err()
+	case <-call.done:
+		if call.err != nil {
+			return "", call.err
+		}
+		return call.token.Value, nil
+	}
+}
+
+// run fetches for call, detached from the cancellation of the caller
+// that started it but keeping its values
+func (s *TokenSource) run(ctx context.Context, call *tokenCall) {
+	fetchCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), tokenFetchTimeout)
+	defer cancel()
+
+	token, err := s.fetch(fetchCtx)
+	if err != nil {
+		err = fmt.Errorf("fetching token: %w", err)
+	} else if token.Value == "" {
+		err = ErrEmptyToken
+	}
+
+	s.mu.Lock()
+	if err == nil {
+		s.token = token
+	}
+	s.call = nil
+	s.mu.Unlock()
+
+	call.token, call.err = token, err
+	close(call.done)
+}
+
+// Invalidate drops stale, the next Token call fetches a new one. A token
+// fetched meanwhile by another request is kept.
+func (s *TokenSource) Invalidate(stale string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.token.Value == stale {
+		s.token = Token{}
+	}
+}
+
+func (s *TokenSource) valid() bool {
+	if s.token.Value == "" {
+		return false
+	}
+	return s.token.ExpiresAt.IsZero() || s.now().Add(s.leeway).Before(s.token.ExpiresAt)
+}
+
+// Login fetches tokens by posting credentials as JSON to path on c. The
+// response needs a token or access_token field, expires_in in seconds
+// sets the expiry, ttl is used without it and zero never expires. c must
+// not use the token source itself.
+func Login(c *Client, path string, credentials interface{}, ttl time.Duration) TokenFetcher {
+	return func(ctx context.Context) (Token, error) {
+		resp, err := PostJSON[interface{}, loginResponse](ctx, c, path, credentials)
+		if err != nil {
+			return Token{}, fmt.Errorf("logging in: %w", err)
+		}
+
+		token := Token{Value: resp.Token}
+		if token.Value == "" {
+			token.Value = resp.AccessToken
+		}
+		expiresIn := ttl
+		if resp.ExpiresIn > 0 {
+			expiresIn = time.Duration(resp.ExpiresIn) * time.Second
+		}
+		if expiresIn > 0 {
+			token.ExpiresAt = time.Now().Add(expiresIn)
+		}
+		return token, nil
+	}
+}
+
+// apply keeps a header the caller already set
+func (h staticHeader) apply(req *http.Request) error {
+	if req.Header.Get(h.name) == "" {
+		req.Header.Set(h.name, h.value)
+	}
+	return nil
+}
+
+func (h staticHeader) refresh(req *http.Request) bool {
+	return false
+}
+
+func (a tokenAuth) apply(req *http.Request) error {
+	if req.Header.Get("Authorization") != "" {
+		return nil
+	}
+
+	token, err := a.source.Token(req.Context())
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	return nil
+}
+
+func (a tokenAuth) refresh(req *http.Request) bool {
+	stale, ok := strings.CutPrefix(req.Header.Get("Authorization"), "Bearer ")
+	if !ok {
+		return false
+	}
+	a.source.Invalidate(stale)
+	return true
+}
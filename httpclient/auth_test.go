@@ -0,0 +1,204 @@
+package httpclient
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/brianwu291/go-learn/cache"
+	"github.com/brianwu291/go-learn/cache/memory"
+)
+
+// countingFetcher hands out token-1, token-2 and so on
+func countingFetcher(fetches *int64, ttl time.Duration) TokenFetcher {
+	return func(ctx context.Context) (Token, error) {
+		n := atomic.AddInt64(fetches, 1)
+		token := Token{Value: fmt.Sprintf("token-%d", n)}
+		if ttl > 0 {
+			token.ExpiresAt = time.Now().Add(ttl)
+		}
+		return token, nil
+	}
+}
+
+func TestClient_StaticAuth(t *testing.T) {
+	tests := []struct {
+		name          string
+		option        Option
+		header        string
+		expectedValue string
+	}{
+		{name: "Bearer token", option: WithBearerToken("secret"), header: "Authorization", expectedValue: "Bearer secret"},
+		{name: "Basic auth", option: WithBasicAuth("john", "pass"), header: "Authorization", expectedValue: "Basic am9objpwYXNz"},
+		{name: "API key", option: WithAPIKey("X-API-Key", "key-1"), header: "X-API-Key", expectedValue: "key-1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, received := newEchoServer(t, http.StatusOK)
+			client := NewClient(WithBaseURL(server.URL), tt.option)
+
+			require.NoError(t, client.Get(context.Background(), "/", nil))
+			assert.Equal(t, tt.expectedValue, received()[0].Get(tt.header))
+		})
+	}
+}
+
+func TestClient_TokenSource(t *testing.T) {
+	ctx := context.Background()
+
+	// newAuthServer accepts the tokens in valid only
+	newAuthServer := func(t *testing.T, valid ...string) (*httptest.Server, *[]string) {
+		var seen []string
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			seen = append(seen, r.Header.Get("Authorization"))
+			for _, token := range valid {
+				if r.Header.Get("Authorization") == "Bearer "+token {
+					w.WriteHeader(http.StatusOK)
+					return
+				}
+			}
+			w.WriteHeader(http.StatusUnauthorized)
+		}))
+		t.Cleanup(server.Close)
+		return server, &seen
+	}
+
+	t.Run("Reuses the token until it expires", func(t *testing.T) {
+		server, seen := newAuthServer(t, "token-1", "token-2")
+		var fetches int64
+		source := NewTokenSource(countingFetcher(&fetches, time.Hour))
+		client := NewClient(WithBaseURL(server.URL), WithTokenSource(source))
+
+		require.NoError(t, client.Get(ctx, "/", nil))
+		require.NoError(t, client.Get(ctx, "/", nil))
+		source.now = func() time.Time { return time.Now().Add(time.Hour) }
+		require.NoError(t, client.Get(ctx, "/", nil))
+
+		assert.Equal(t, []string{"Bearer token-1", "Bearer token-1", "Bearer token-2"}, *seen)
+	})
+
+	t.Run("Refreshes once on a 401", func(t *testing.T) {
+		server, seen := newAuthServer(t, "token-2")
+		var fetches int64
+		client := NewClient(WithBaseURL(server.URL), WithTokenSource(NewTokenSource(countingFetcher(&fetches, 0))))
+
+		require.NoError(t, client.Get(ctx, "/", nil))
+		assert.Equal(t, []string{"Bearer token-1", "Bearer token-2"}, *seen)
+	})
+
+	t.Run("Gives up after the refreshed token fails too", func(t *testing.T) {
+		server, seen := newAuthServer(t)
+		var fetches int64
+		client := NewClient(
+			WithBaseURL(server.URL),
+			WithRetry(RetryPolicy{BaseDelay: time.Millisecond}),
+			WithTokenSource(NewTokenSource(countingFetcher(&fetches, 0))),
+		)
+
+		err := client.Get(ctx, "/", nil)
+		assert.Equal(t, http.StatusUnauthorized, StatusCode(err))
+		assert.Len(t, *seen, 2)
+		assert.Equal(t, int64(2), fetches)
+	})
+
+	t.Run("Fails the request when fetching fails", func(t *testing.T) {
+		server, seen := newAuthServer(t)
+		failing := errors.New("login down")
+		client := NewClient(WithBaseURL(server.URL), WithTokenSource(NewTokenSource(func(ctx context.Context) (Token, error) {
+			return Token{}, failing
+		})))
+
+		assert.ErrorIs(t, client.Get(ctx, "/", nil), failing)
+		assert.Empty(t, *seen)
+	})
+
+	t.Run("Shares one fetch and lets each caller stop waiting", func(t *testing.T) {
+		var fetches int64
+		release := make(chan struct{})
+		source := NewTokenSource(func(ctx context.Context) (Token, error) {
+			atomic.AddInt64(&fetches, 1)
+			<-release
+			return Token{Value: "token-1"}, nil
+		})
+
+		impatient, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+		defer cancel()
+		_, err := source.Token(impatient)
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+		tokens := make(chan string, 2)
+		for i := 0; i < 2; i++ {
+			go func() {
+				token, _ := source.Token(ctx)
+				tokens <- token
+			}()
+		}
+		time.Sleep(20 * time.Millisecond)
+		close(release)
+
+		assert.Equal(t, "token-1", <-tokens)
+		assert.Equal(t, "token-1", <-tokens)
+		assert.Equal(t, int64(1), atomic.LoadInt64(&fetches))
+	})
+
+	t.Run("Skips the response cache", func(t *testing.T) {
+		server, conditions := newCacheableServer(t, map[string]string{"Cache-Control": "max-age=60"})
+		client := NewClient(
+			WithBaseURL(server.URL),
+			WithBearerToken("secret"),
+			WithResponseCache(memory.NewClient(), cache.NewKeyBuilder("test", "test"), ResponseCacheConfig{}),
+		)
+
+		require.NoError(t, client.Get(ctx, "/products/1", nil))
+		require.NoError(t, client.Get(ctx, "/products/1", nil))
+		assert.Len(t, conditions(), 2)
+	})
+}
+
+func TestLogin(t *testing.T) {
+	tests := []struct {
+		name            string
+		response        string
+		ttl             time.Duration
+		expectedToken   string
+		expectedExpires time.Duration
+		expectedErr     error
+	}{
+		{name: "Token without expiry", response: `{"token":"jwt"}`, expectedToken: "jwt"},
+		{name: "Token with the fallback ttl", response: `{"token":"jwt"}`, ttl: time.Hour, expectedToken: "jwt", expectedExpires: time.Hour},
+		{name: "Access token with expires_in", response: `{"access_token":"abc","expires_in":60}`, ttl: time.Hour, expectedToken: "abc", expectedExpires: time.Minute},
+		{name: "No token", response: `{}`, expectedErr: ErrEmptyToken},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var credentials map[string]string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				json.NewDecoder(r.Body).Decode(&credentials)
+				w.Write([]byte(tt.response))
+			}))
+			t.Cleanup(server.Close)
+
+			login := Login(NewClient(WithBaseURL(server.URL)), "/auth/login", map[string]string{"username": "john"}, tt.ttl)
+			source := NewTokenSource(login)
+
+			token, err := source.Token(context.Background())
+			assert.ErrorIs(t, err, tt.expectedErr)
+			assert.Equal(t, tt.expectedToken, token)
+			assert.Equal(t, "john", credentials["username"])
+			if tt.expectedExpires > 0 {
+				assert.WithinDuration(t, time.Now().Add(tt.expectedExpires), source.token.ExpiresAt, time.Second)
+			}
+		})
+	}
+}
@@ -0,0 +1,96 @@
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+
+	circuitbreaker "github.com/brianwu291/go-learn/circuitbreaker"
+)
+
+type (
+	// hostBreakers keeps one breaker per host, so one failing API does not
+	// block calls to the others
+	hostBreakers struct {
+		config circuitbreaker.Config
+
+		mu       sync.Mutex
+		breakers map[string]*circuitbreaker.Breaker
+	}
+)
+
+var (
+	// ErrCircuitOpen is returned without calling the host while its
+	// breaker is open, callers can fall back right away
+	ErrCircuitOpen = errors.New("circuit open for host")
+)
+
+// WithCircuitBreaker stops calling a host after it keeps failing, see
+// circuitbreaker.Config for the thresholds. Network errors and 5xx
+// responses count as failures, every attempt of a retry is counted.
+func WithCircuitBreaker(config circuitbreaker.Config) Option {
+	return func(c *Client) {
+		c.breakers = &hostBreakers{
+			config:   config,
+			breakers: make(map[string]*circuitbreaker.Breaker),
+		}
+	}
+}
+
+// CircuitStates reports the breaker state per host called so far
+func (c *Client) CircuitStates() map[string]string {
+	states := make(map[string]string)
+	if c.breakers == nil {
+		return states
+	}
+
+	c.breakers.mu.Lock()
+	defer c.breakers.mu.Unlock()
+	for host, breaker := range c.breakers.breakers {
+		states[host] = breaker.State().String()
+	}
+	return states
+}
+
+// allow returns the func reporting the attempt, a no-op without breakers
+func (c *Client) allow(host string) (func(ctx context.Context, resp *http.Response, err error), error) {
+	if c.breakers == nil {
+		return func(context.Context, *http.Response, error) {}, nil
+	}
+
+	done, err := c.breakers.get(host).Allow()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrCircuitOpen, host)
+	}
+	return func(ctx context.Context, resp *http.Response, err error) {
+		done(outcome(ctx, resp, err))
+	}, nil
+}
+
+func (b *hostBreakers) get(host string) *circuitbreaker.Breaker {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	breaker, ok := b.breakers[host]
+	if !ok {
+		breaker = circuitbreaker.New(b.config)
+		b.breakers[host] = breaker
+	}
+	return breaker
+}
+
+func outcome(ctx context.Context, resp *http.Response, err error) circuitbreaker.Outcome {
+	switch {
+	case ctx.Err() != nil:
+		// the caller gave up, that says nothing about the host
+		return circuitbreaker.Ignored
+	case err != nil:
+		return circuitbreaker.Failure
+	case resp.StatusCode >= http.StatusInternalServerError:
+		return circuitbreaker.Failure
+	default:
+		return circuitbreaker.Success
+	}
+}
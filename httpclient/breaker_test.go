@@ -0,0 +1,79 @@
+package httpclient
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	circuitbreaker "github.com/brianwu291/go-learn/circuitbreaker"
+)
+
+func TestClient_CircuitBreaker(t *testing.T) {
+	ctx := context.Background()
+	config := circuitbreaker.Config{ConsecutiveFailures: 2, OpenTimeout: 30 * time.Millisecond}
+
+	t.Run("Fails fast while the host keeps failing", func(t *testing.T) {
+		down, downRequests, _ := newStatusServer(t, nil, http.StatusBadGateway)
+		up, upRequests, _ := newStatusServer(t, nil, http.StatusOK)
+		client := NewClient(WithCircuitBreaker(config))
+
+		for i := 0; i < 2; i++ {
+			err := client.Get(ctx, down.URL, nil)
+			assert.NotErrorIs(t, err, ErrCircuitOpen)
+		}
+
+		err := client.Get(ctx, down.URL, nil)
+		assert.ErrorIs(t, err, ErrCircuitOpen)
+		assert.Equal(t, int64(2), atomic.LoadInt64(downRequests), "the open circuit skips the host")
+
+		require.NoError(t, client.Get(ctx, up.URL, nil), "other hosts are not affected")
+		assert.Equal(t, int64(1), atomic.LoadInt64(upRequests))
+
+		states := client.CircuitStates()
+		assert.Equal(t, "open", states[down.Listener.Addr().String()])
+		assert.Equal(t, "closed", states[up.Listener.Addr().String()])
+	})
+
+	t.Run("Closes again after a successful probe", func(t *testing.T) {
+		server, requests, _ := newStatusServer(t, nil, http.StatusInternalServerError, http.StatusInternalServerError, http.StatusOK)
+		client := NewClient(WithBaseURL(server.URL), WithCircuitBreaker(config))
+
+		assert.Error(t, client.Get(ctx, "/", nil))
+		assert.Error(t, client.Get(ctx, "/", nil))
+		assert.ErrorIs(t, client.Get(ctx, "/", nil), ErrCircuitOpen)
+
+		time.Sleep(config.OpenTimeout)
+		require.NoError(t, client.Get(ctx, "/", nil))
+		require.NoError(t, client.Get(ctx, "/", nil))
+		assert.Equal(t, int64(4), atomic.LoadInt64(requests))
+	})
+
+	t.Run("Counts every retry attempt and stops retrying once open", func(t *testing.T) {
+		server, requests, _ := newStatusServer(t, nil, http.StatusServiceUnavailable)
+		client := NewClient(
+			WithBaseURL(server.URL),
+			WithRetry(RetryPolicy{MaxAttempts: 5, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond}),
+			WithCircuitBreaker(config),
+		)
+
+		assert.ErrorIs(t, client.Get(ctx, "/", nil), ErrCircuitOpen)
+		assert.Equal(t, int64(2), atomic.LoadInt64(requests))
+	})
+
+	t.Run("Client errors keep the circuit closed", func(t *testing.T) {
+		server, requests, _ := newStatusServer(t, nil, http.StatusNotFound)
+		client := NewClient(WithBaseURL(server.URL), WithCircuitBreaker(config))
+
+		for i := 0; i < 3; i++ {
+			err := client.Get(ctx, "/", nil)
+			assert.Error(t, err)
+			assert.NotErrorIs(t, err, ErrCircuitOpen)
+		}
+		assert.Equal(t, int64(3), atomic.LoadInt64(requests))
+	})
+}
@@ -0,0 +1,64 @@
+package httpclient
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+)
+
+type (
+	// HTTPError is returned by Do for a response outside 2xx. Use
+	// errors.As, or the helpers below, to inspect it through wrapping.
+	HTTPError struct {
+		StatusCode int
+		Method     string
+		URL        string
+		Header     http.Header
+		// Body holds at most the first maxErrorBodyBytes of the response
+		Body string
+	}
+)
+
+// maxErrorBodyBytes caps the body kept in an HTTPError, enough for an
+// error message without holding a whole HTML error page
+const maxErrorBodyBytes = 4 << 10
+
+func (e *HTTPError) Error() string {
+	return fmt.Sprintf("unexpected status code: %d from %s %s, body: %s", e.StatusCode, e.Method, e.URL, e.Body)
+}
+
+// IsNotFound reports an upstream 404
+func IsNotFound(err error) bool {
+	return StatusCode(err) == http.StatusNotFound
+}
+
+// IsRetryable reports a status worth trying again later, such as 503
+func IsRetryable(err error) bool {
+	return slices.Contains(defaultRetryableStatuses, StatusCode(err))
+}
+
+// StatusCode returns the upstream status, 0 when err is no HTTPError
+func StatusCode(err error) int {
+	var httpErr *HTTPError
+	if errors.As(err, &httpErr) {
+		return httpErr.StatusCode
+	}
+	return 0
+}
+
+func newHTTPError(r Request, url string, resp *http.Response) *HTTPError {
+	if resp.Request != nil && resp.Request.URL != nil {
+		url = resp.Request.URL.String()
+	}
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBodyBytes))
+
+	return &HTTPError{
+		StatusCode: resp.StatusCode,
+		Method:     r.Method,
+		URL:        url,
+		Header:     resp.Header,
+		Body:       string(body),
+	}
+}
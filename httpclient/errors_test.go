@@ -0,0 +1,62 @@
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient_HTTPError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Request-ID", "abc")
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(strings.Repeat("x", maxErrorBodyBytes+100)))
+	}))
+	t.Cleanup(server.Close)
+	client := NewClient(WithBaseURL(server.URL))
+
+	err := client.Do(context.Background(), Request{
+		Method: http.MethodGet,
+		Path:   "/products/99",
+		Query:  map[string]string{"fields": "id"},
+	}, nil)
+
+	var httpErr *HTTPError
+	require.ErrorAs(t, fmt.Errorf("repo: %w", err), &httpErr)
+	assert.Equal(t, http.StatusNotFound, httpErr.StatusCode)
+	assert.Equal(t, http.MethodGet, httpErr.Method)
+	assert.Equal(t, server.URL+"/products/99?fields=id", httpErr.URL)
+	assert.Equal(t, "abc", httpErr.Header.Get("X-Request-ID"))
+	assert.Len(t, httpErr.Body, maxErrorBodyBytes)
+}
+
+func TestErrorHelpers(t *testing.T) {
+	tests := []struct {
+		name              string
+		err               error
+		expectedStatus    int
+		expectedNotFound  bool
+		expectedRetryable bool
+	}{
+		{name: "Wrapped 404", err: fmt.Errorf("get product: %w", &HTTPError{StatusCode: http.StatusNotFound}), expectedStatus: 404, expectedNotFound: true},
+		{name: "503", err: &HTTPError{StatusCode: http.StatusServiceUnavailable}, expectedStatus: 503, expectedRetryable: true},
+		{name: "400", err: &HTTPError{StatusCode: http.StatusBadRequest}, expectedStatus: 400},
+		{name: "Other error", err: errors.New("connection refused"), expectedStatus: 0},
+		{name: "No error", err: nil, expectedStatus: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expectedStatus, StatusCode(tt.err))
+			assert.Equal(t, tt.expectedNotFound, IsNotFound(tt.err))
+			assert.Equal(t, tt.expectedRetryable, IsRetryable(tt.err))
+		})
+	}
+}
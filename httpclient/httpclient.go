@@ -1,8 +1,10 @@
 package httpclient
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -18,6 +20,16 @@ type (
 	Client struct {
 		baseURL    string
 		httpClient HTTPClient
+		retry      *RetryPolicy
+		breakers   *hostBreakers
+		// responseCache is nil unless WithResponseCache is given
+		responseCache *responseCache
+		auth          authenticator
+		limiter       limiter
+
+		interceptors []Interceptor
+		// execute is httpClient.Do wrapped in the interceptors
+		execute Executor
 	}
 
 	Option func(*Client)
@@ -27,7 +39,24 @@ type (
 		Path    string
 		Query   map[string]string
 		Headers map[string]string
-		Body    io.Reader
+		// Body is sent again on every attempt, so it is kept as bytes
+		Body []byte
+		// Idempotent allows retrying a POST or PATCH, e.g. one sent with an
+		// Idempotency-Key header. Other methods are idempotent already.
+		Idempotent bool
+		// Capture receives the status and headers of the response, also
+		// when Do returns an *HTTPError
+		Capture *Response
+		// AllowEmptyBody accepts a response with Content-Length: 0 as an
+		// empty result. Empty 204, 205 and HEAD responses always are.
+		AllowEmptyBody bool
+	}
+
+	// Response is the status and headers of a response, see
+	// CaptureResponse
+	Response struct {
+		StatusCode int
+		Header     http.Header
 	}
 )
 
@@ -57,6 +86,7 @@ func NewClient(opts ...Option) *Client {
 	for _, opt := range opts {
 		opt(c)
 	}
+	c.execute = chain(c.httpClient.Do, c.interceptors)
 
 	return c
 }
@@ -68,7 +98,7 @@ func (c *Client) Get(ctx context.Context, path string, result interface{}) error
 	}, result)
 }
 
-func (c *Client) Post(ctx context.Context, path string, body io.Reader, result interface{}) error {
+func (c *Client) Post(ctx context.Context, path string, body []byte, result interface{}) error {
 	return c.Do(ctx, Request{
 		Method: http.MethodPost,
 		Path:   path,
@@ -76,13 +106,185 @@ func (c *Client) Post(ctx context.Context, path string, body io.Reader, result i
 	}, result)
 }
 
-// Do executes an HTTP request and decodes the response
+func (c *Client) Put(ctx context.Context, path string, body []byte, result interface{}) error {
+	return c.Do(ctx, Request{
+		Method: http.MethodPut,
+		Path:   path,
+		Body:   body,
+	}, result)
+}
+
+func (c *Client) Patch(ctx context.Context, path string, body []byte, result interface{}) error {
+	return c.Do(ctx, Request{
+		Method: http.MethodPatch,
+		Path:   path,
+		Body:   body,
+	}, result)
+}
+
+func (c *Client) Delete(ctx context.Context, path string, result interface{}) error {
+	return c.Do(ctx, Request{
+		Method: http.MethodDelete,
+		Path:   path,
+	}, result)
+}
+
+// Do executes an HTTP request and decodes the response. A status outside
+// 2xx is returned as *HTTPError.
 func (c *Client) Do(ctx context.Context, r Request, result interface{}) error {
+	resp, err := c.open(ctx, r)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if result != nil {
+		// an expected empty body, e.g. of a 204, leaves result as it is
+		err := json.NewDecoder(resp.Body).Decode(result)
+		if errors.Is(err, io.EOF) && emptyBodyExpected(r, resp) {
+			err = nil
+		}
+		if err != nil {
+			return fmt.Errorf("decoding response: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// emptyBodyExpected tells an empty result from a body lost on the way
+func emptyBodyExpected(r Request, resp *http.Response) bool {
+	switch {
+	case resp.StatusCode == http.StatusNoContent, resp.StatusCode == http.StatusResetContent:
+		return true
+	case r.Method == http.MethodHead:
+		return true
+	default:
+		return r.AllowEmptyBody && resp.ContentLength == 0
+	}
+}
+
+// open sends r and returns the response of a 2xx for the caller to read
+// and close, other statuses are returned as *HTTPError
+func (c *Client) open(ctx context.Context, r Request) (*http.Response, error) {
+	resp, err := c.roundTrip(ctx, r)
+	if err != nil {
+		return nil, err
+	}
+
+	if r.Capture != nil {
+		r.Capture.StatusCode = resp.StatusCode
+		r.Capture.Header = resp.Header.Clone()
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		defer resp.Body.Close()
+		return nil, newHTTPError(r, c.baseURL+r.Path, resp)
+	}
+	return resp, nil
+}
+
+// roundTrip answers r from the response cache when one is set, it sits
+// outside the retries so a fresh entry never reaches the upstream. The
+// cache is shared, so it is skipped for authenticated clients.
+func (c *Client) roundTrip(ctx context.Context, r Request) (*http.Response, error) {
+	if c.responseCache == nil || c.auth != nil {
+		return c.send(ctx, r)
+	}
+
+	req, err := c.newRequest(ctx, r)
+	if err != nil {
+		return nil, err
+	}
+	return c.responseCache.do(ctx, r, req.URL.String(), func(r Request) (*http.Response, error) {
+		return c.send(ctx, r)
+	})
+}
+
+// send makes the first attempt and the retries the policy allows. The
+// response of the last attempt is returned whatever its status.
+func (c *Client) send(ctx context.Context, r Request) (*http.Response, error) {
+	// one ID for every attempt, so the upstream sees retries as one call
+	if _, ok := RequestIDFromContext(ctx); !ok {
+		ctx = ContextWithRequestID(ctx, NewRequestID())
+	}
+
+	maxAttempts := 1
+	if c.retry != nil && (r.Idempotent || isIdempotent(r.Method)) {
+		maxAttempts = c.retry.MaxAttempts
+	}
+
+	refreshed := false
+	for attempt := 1; ; attempt += 1 {
+		req, err := c.newRequest(ctx, r)
+		if err != nil {
+			return nil, err
+		}
+		if c.auth != nil {
+			if err := c.auth.apply(req); err != nil {
+				return nil, fmt.Errorf("authenticating request: %w", err)
+			}
+		}
+
+		if err := c.throttle(ctx, req.URL.Host); err != nil {
+			return nil, err
+		}
+		done, err := c.allow(req.URL.Host)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := c.execute(req)
+		done(ctx, resp, err)
+
+		// a 401 gets one more attempt with fresh credentials, it does not
+		// use up a retry
+		if err == nil && resp.StatusCode == http.StatusUnauthorized && !refreshed &&
+			c.auth != nil && c.auth.refresh(req) {
+			refreshed = true
+			drain(resp)
+			attempt -= 1
+			continue
+		}
+
+		if attempt >= maxAttempts {
+			if err != nil {
+				return nil, fmt.Errorf("executing request: %w", err)
+			}
+			return resp, nil
+		}
+
+		delay, retry := c.retry.next(ctx, attempt, resp, err)
+		if !retry {
+			if err != nil {
+				return nil, fmt.Errorf("executing request: %w", err)
+			}
+			return resp, nil
+		}
+
+		if resp != nil {
+			fmt.Printf("retrying %s %s in %s, attempt %d got status %d\n", r.Method, r.Path, delay, attempt, resp.StatusCode)
+			drain(resp)
+		} else {
+			fmt.Printf("retrying %s %s in %s, attempt %d failed: %v\n", r.Method, r.Path, delay, attempt, err)
+		}
+
+		if err := wait(ctx, delay); err != nil {
+			return nil, fmt.Errorf("executing request: %w", err)
+		}
+	}
+}
+
+func (c *Client) newRequest(ctx context.Context, r Request) (*http.Request, error) {
 	url := fmt.Sprintf("%s%s", c.baseURL, r.Path)
 
-	req, err := http.NewRequestWithContext(ctx, r.Method, url, r.Body)
+	var body io.Reader
+	if r.Body != nil {
+		body = bytes.NewReader(r.Body)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, r.Method, url, body)
 	if err != nil {
-		return fmt.Errorf("creating request: %w", err)
+		return nil, fmt.Errorf("creating request: %w", err)
 	}
 
 	// Add query parameters
@@ -98,23 +300,9 @@ func (c *Client) Do(ctx context.Context, r Request, result interface{}) error {
 	for key, value := range r.Headers {
 		req.Header.Add(key, value)
 	}
-
-	resp, err := c.httpClient.Do(req)
-	if err != nil {
-		return fmt.Errorf("executing request: %w", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		bodyBytes, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("unexpected status code: %d, body: %s", resp.StatusCode, string(bodyBytes))
-	}
-
-	if result != nil {
-		if err := json.NewDecoder(resp.Body).Decode(result); err != nil {
-			return fmt.Errorf("decoding response: %w", err)
-		}
+	if r.Body != nil && req.Header.Get("Content-Type") == "" {
+		req.Header.Set("Content-Type", "application/json")
 	}
 
-	return nil
+	return req, nil
 }
@@ -0,0 +1,335 @@
+// Package httpclienttest provides httpclient.HTTPClient helpers for tests
+package httpclienttest
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/brianwu291/go-learn/httpclient"
+)
+
+type (
+	Mode int
+
+	// Cassette is the fixture file, a list of request and response pairs
+	Cassette struct {
+		Interactions []Interaction `json:"interactions"`
+	}
+
+	Interaction struct {
+		Request  RecordedRequest  `json:"request"`
+		Response RecordedResponse `json:"response"`
+	}
+
+	RecordedRequest struct {
+		Method string      `json:"method"`
+		URL    string      `json:"url"`
+		Header http.Header `json:"header,omitempty"`
+		Body   string      `json:"body,omitempty"`
+	}
+
+	RecordedResponse struct {
+		StatusCode int         `json:"statusCode"`
+		Header     http.Header `json:"header,omitempty"`
+		Body       string      `json:"body"`
+	}
+
+	// Matcher reports whether a recorded request answers req, whose URL
+	// and body are already redacted like the recorded ones
+	Matcher func(req RecordedRequest, recorded RecordedRequest) bool
+
+	RecorderConfig struct {
+		Mode Mode
+		// Client sends the requests while recording, a plain http.Client
+		// when nil
+		Client httpclient.HTTPClient
+		// Matcher defaults to MatchMethodAndURL
+		Matcher Matcher
+		// RedactHeaders are saved as Redacted, they default to
+		// DefaultRedactHeaders
+		RedactHeaders []string
+		// RedactQuery are query parameters saved as Redacted, e.g. api_key
+		RedactQuery []string
+		// RedactBody rewrites request and response bodies before they are
+		// saved or matched, e.g. to drop passwords and tokens
+		RedactBody func(body string) string
+	}
+
+	// Recorder is an httpclient.HTTPClient that records interactions to a
+	// cassette file or replays them from it without any network
+	Recorder struct {
+		path   string
+		config RecorderConfig
+
+		mu       sync.Mutex
+		cassette Cassette
+		used     []bool
+	}
+)
+
+const (
+	// ModeReplay serves requests from the cassette and fails unknown ones
+	ModeReplay Mode = iota
+	// ModeRecord sends requests upstream and saves them with Save
+	ModeRecord
+)
+
+// Redacted replaces secrets in saved cassettes
+const Redacted = "REDACTED"
+
+// RecordEnv set to 1 makes Open record instead of replay
+const RecordEnv = "HTTPCLIENT_RECORD"
+
+var (
+	// ErrNoInteraction is returned in replay mode for requests the
+	// cassette has no answer for
+	ErrNoInteraction = errors.New("no recorded interaction matches request")
+
+	DefaultRedactHeaders = []string{"Authorization", "Cookie", "Set-Cookie", "X-API-Key"}
+)
+
+// MatchMethodAndURL matches on method and full URL, query included
+func MatchMethodAndURL(req RecordedRequest, recorded RecordedRequest) bool {
+	return req.Method == recorded.Method && req.URL == recorded.URL
+}
+
+// MatchMethodURLAndBody also compares the bodies, for POSTs to one URL
+func MatchMethodURLAndBody(req RecordedRequest, recorded RecordedRequest) bool {
+	return MatchMethodAndURL(req, recorded) && req.Body == recorded.Body
+}
+
+// MatchHeaders is base also comparing the names headers, e.g.
+// If-None-Match to tell a revalidation from the first request
+func MatchHeaders(base Matcher, names ...string) Matcher {
+	return func(req RecordedRequest, recorded RecordedRequest) bool {
+		if !base(req, recorded) {
+			return false
+		}
+		for _, name := range names {
+			if req.Header.Get(name) != recorded.Header.Get(name) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// NewRecorder loads the cassette at path for replay, in record mode it
+// starts empty and Save writes it
+func NewRecorder(path string, config RecorderConfig) (*Recorder, error) {
+	if config.Client == nil {
+		config.Client = &http.Client{}
+	}
+	if config.Matcher == nil {
+		config.Matcher = MatchMethodAndURL
+	}
+	if config.RedactHeaders == nil {
+		config.RedactHeaders = DefaultRedactHeaders
+	}
+
+	r := &Recorder{path: path, config: config}
+	if config.Mode == ModeRecord {
+		return r, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading cassette: %w", err)
+	}
+	if err := json.Unmarshal(data, &r.cassette); err != nil {
+		return nil, fmt.Errorf("decoding cassette %s: %w", path, err)
+	}
+	r.used = make([]bool, len(r.cassette.Interactions))
+	return r, nil
+}
+
+// Open is NewRecorder for testdata/cassettes/<name>.json, recording when
+// RecordEnv is 1. A recording is saved when the test ends.
+func Open(t testing.TB, name string, config RecorderConfig) *Recorder {
+	t.Helper()
+
+	if os.Getenv(RecordEnv) == "1" {
+		config.Mode = ModeRecord
+	}
+
+	r, err := NewRecorder(filepath.Join("testdata", "cassettes", name+".json"), config)
+	if err != nil {
+		t.Fatalf("opening cassette %s: %v", name, err)
+	}
+	if config.Mode == ModeRecord {
+		t.Cleanup(func() {
+			if err := r.Save(); err != nil {
+				t.Errorf("saving cassette %s: %v", name, err)
+			}
+		})
+	}
+	return r
+}
+
+// Do records or replays req
+func (r *Recorder) Do(req *http.Request) (*http.Response, error) {
+	recorded, err := r.recordRequest(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if r.config.Mode == ModeRecord {
+		return r.record(req, recorded)
+	}
+	return r.replay(req, recorded)
+}
+
+// Save writes the recorded interactions to the cassette file
+func (r *Recorder) Save() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	data, err := json.MarshalIndent(r.cassette, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encoding cassette: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(r.path), 0o755); err != nil {
+		return fmt.Errorf("creating cassette directory: %w", err)
+	}
+	return os.WriteFile(r.path, append(data, '\n'), 0o644)
+}
+
+// Interactions returns a copy of the cassette, e.g. to check a recording
+func (r *Recorder) Interactions() []Interaction {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return append([]Interaction(nil), r.cassette.Interactions...)
+}
+
+func (r *Recorder) record(req *http.Request, recorded RecordedRequest) (*http.Response, error) {
+	resp, err := r.config.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("reading response: %w", err)
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.cassette.Interactions = append(r.cassette.Interactions, Interaction{
+		Request: recorded,
+		Response: RecordedResponse{
+			StatusCode: resp.StatusCode,
+			Header:     r.redactHeader(resp.Header),
+			Body:       r.redactBody(string(body)),
+		},
+	})
+	r.used = append(r.used, true)
+	return resp, nil
+}
+
+// replay prefers interactions not served yet, so a cassette can hold
+// different answers to one request in order, and reuses the last match
+// once they are all served
+func (r *Recorder) replay(req *http.Request, recorded RecordedRequest) (*http.Response, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	match := -1
+	for i, interaction := range r.cassette.Interactions {
+		if !r.config.Matcher(recorded, interaction.Request) {
+			continue
+		}
+		match = i
+		if !r.used[i] {
+			break
+		}
+	}
+	if match < 0 {
+		return nil, fmt.Errorf("%w: %s %s", ErrNoInteraction, recorded.Method, recorded.URL)
+	}
+	r.used[match] = true
+
+	response := r.cassette.Interactions[match].Response
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", response.StatusCode, http.StatusText(response.StatusCode)),
+		StatusCode:    response.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        response.Header.Clone(),
+		Body:          io.NopCloser(strings.NewReader(response.Body)),
+		ContentLength: int64(len(response.Body)),
+		Request:       req,
+	}, nil
+}
+
+// recordRequest redacts req for saving and matching, its body is read and
+// put back so it can still be sent
+func (r *Recorder) recordRequest(req *http.Request) (RecordedRequest, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return RecordedRequest{}, fmt.Errorf("reading request: %w", err)
+		}
+		req.Body = io.NopCloser(bytes.NewReader(body))
+	}
+
+	return RecordedRequest{
+		Method: req.Method,
+		URL:    r.redactURL(req.URL),
+		Header: r.redactHeader(req.Header),
+		Body:   r.redactBody(string(body)),
+	}, nil
+}
+
+func (r *Recorder) redactURL(u *url.URL) string {
+	if len(r.config.RedactQuery) == 0 {
+		return u.String()
+	}
+
+	redacted := *u
+	query := redacted.Query()
+	for _, name := range r.config.RedactQuery {
+		if query.Has(name) {
+			query.Set(name, Redacted)
+		}
+	}
+	redacted.RawQuery = query.Encode()
+	return redacted.String()
+}
+
+func (r *Recorder) redactHeader(header http.Header) http.Header {
+	if len(header) == 0 {
+		return nil
+	}
+
+	redacted := header.Clone()
+	for _, name := range r.config.RedactHeaders {
+		if redacted.Get(name) != "" {
+			redacted.Set(name, Redacted)
+		}
+	}
+	return redacted
+}
+
+func (r *Recorder) redactBody(body string) string {
+	if r.config.RedactBody == nil {
+		return body
+	}
+	return r.config.RedactBody(body)
+}
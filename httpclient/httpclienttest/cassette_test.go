@@ -0,0 +1,121 @@
+package httpclienttest
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/brianwu291/go-learn/httpclient"
+)
+
+type answer struct {
+	Call  int    `json:"call"`
+	Path  string `json:"path"`
+	Token string `json:"token"`
+}
+
+func TestRecorder(t *testing.T) {
+	ctx := context.Background()
+
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls += 1
+		w.Header().Set("Set-Cookie", "session=secret")
+		fmt.Fprintf(w, `{"call":%d,"path":%q,"token":"jwt-secret"}`, calls, r.URL.Path)
+	}))
+	path := filepath.Join(t.TempDir(), "cassettes", "products.json")
+	config := RecorderConfig{
+		RedactQuery: []string{"api_key"},
+		RedactBody: func(body string) string {
+			return strings.ReplaceAll(body, "jwt-secret", Redacted)
+		},
+	}
+
+	// record two answers to one request and a login
+	recording := config
+	recording.Mode = ModeRecord
+	recorder, err := NewRecorder(path, recording)
+	require.NoError(t, err)
+	client := httpclient.NewClient(httpclient.WithBaseURL(server.URL), httpclient.WithHTTPClient(recorder), httpclient.WithBearerToken("secret"))
+
+	products := httpclient.Request{Method: http.MethodGet, Path: "/products", Query: map[string]string{"api_key": "k-1"}}
+	var got answer
+	require.NoError(t, client.Do(ctx, products, &got))
+	assert.Equal(t, "jwt-secret", got.Token, "the caller sees the real response")
+	require.NoError(t, client.Do(ctx, products, &got))
+	_, err = httpclient.PostJSON[map[string]string, answer](ctx, client, "/auth/login", map[string]string{"password": "jwt-secret"})
+	require.NoError(t, err)
+	require.NoError(t, recorder.Save())
+	server.Close()
+
+	t.Run("Redacts secrets in the cassette", func(t *testing.T) {
+		data, err := os.ReadFile(path)
+		require.NoError(t, err)
+		assert.NotContains(t, string(data), "jwt-secret")
+		assert.NotContains(t, string(data), "session=secret")
+		assert.NotContains(t, string(data), "k-1")
+
+		interactions := recorder.Interactions()
+		require.Len(t, interactions, 3)
+		assert.Equal(t, Redacted, interactions[0].Request.Header.Get("Authorization"))
+		assert.Contains(t, interactions[0].Request.URL, "api_key="+Redacted)
+	})
+
+	t.Run("Replays in order without the network", func(t *testing.T) {
+		recorder, err := NewRecorder(path, config)
+		require.NoError(t, err)
+		client := httpclient.NewClient(httpclient.WithBaseURL(server.URL), httpclient.WithHTTPClient(recorder))
+
+		// a different key still matches once redacted
+		products.Query["api_key"] = "k-2"
+		var first, second, third answer
+		require.NoError(t, client.Do(ctx, products, &first))
+		require.NoError(t, client.Do(ctx, products, &second))
+		require.NoError(t, client.Do(ctx, products, &third))
+
+		assert.Equal(t, []int{1, 2, 2}, []int{first.Call, second.Call, third.Call})
+		assert.Equal(t, Redacted, first.Token)
+	})
+
+	t.Run("Fails requests it has no answer for", func(t *testing.T) {
+		tests := []struct {
+			name    string
+			matcher Matcher
+			body    map[string]string
+		}{
+			{name: "Unknown URL", matcher: MatchMethodAndURL},
+			{name: "Other body", matcher: MatchMethodURLAndBody, body: map[string]string{"password": "other"}},
+			{name: "Other header", matcher: MatchHeaders(MatchMethodAndURL, "Authorization"), body: map[string]string{"password": "jwt-secret"}},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				replaying := config
+				replaying.Matcher = tt.matcher
+				recorder, err := NewRecorder(path, replaying)
+				require.NoError(t, err)
+				client := httpclient.NewClient(httpclient.WithBaseURL(server.URL), httpclient.WithHTTPClient(recorder))
+
+				requestPath := "/auth/login"
+				if tt.body == nil {
+					requestPath = "/unknown"
+				}
+				_, err = httpclient.PostJSON[map[string]string, answer](ctx, client, requestPath, tt.body)
+				assert.ErrorIs(t, err, ErrNoInteraction)
+			})
+		}
+	})
+
+	t.Run("Needs the cassette to replay", func(t *testing.T) {
+		_, err := NewRecorder(filepath.Join(t.TempDir(), "missing.json"), RecorderConfig{})
+		assert.ErrorIs(t, err, os.ErrNotExist)
+	})
+}
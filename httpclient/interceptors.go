@@ -0,0 +1,110 @@
+package httpclient
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+type (
+	// Executor sends one attempt of a request
+	Executor func(req *http.Request) (*http.Response, error)
+
+	// Interceptor wraps the next executor, e.g. to add headers before it
+	// or to look at the response after it
+	Interceptor func(next Executor) Executor
+
+	requestIDContextKey struct{}
+)
+
+// DefaultRequestIDHeader is the header RequestID sets when given none
+const DefaultRequestIDHeader = "X-Request-ID"
+
+// WithInterceptors adds interceptors around every attempt, the first one
+// runs outermost. Retries and the circuit breaker sit outside the chain,
+// so interceptors see each attempt.
+func WithInterceptors(interceptors ...Interceptor) Option {
+	return func(c *Client) {
+		c.interceptors = append(c.interceptors, interceptors...)
+	}
+}
+
+// chain wraps executor with the interceptors, the first outermost
+func chain(executor Executor, interceptors []Interceptor) Executor {
+	for i := len(interceptors) - 1; i >= 0; i-- {
+		executor = interceptors[i](executor)
+	}
+	return executor
+}
+
+// ContextWithRequestID makes RequestID send id, e.g. the ID of the
+// incoming request being served. Without one every call gets its own ID,
+// kept over its retries.
+func ContextWithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDContextKey{}, id)
+}
+
+func RequestIDFromContext(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(requestIDContextKey{}).(string)
+	return id, ok && id != ""
+}
+
+// RequestID sets header, DefaultRequestIDHeader when empty, to the ID in
+// the request context, which the client sets once per call so retries
+// share it. A header already set on the request is kept.
+func RequestID(header string) Interceptor {
+	if header == "" {
+		header = DefaultRequestIDHeader
+	}
+
+	return func(next Executor) Executor {
+		return func(req *http.Request) (*http.Response, error) {
+			if req.Header.Get(header) == "" {
+				id, ok := RequestIDFromContext(req.Context())
+				if !ok {
+					id = NewRequestID()
+				}
+				req.Header.Set(header, id)
+			}
+			return next(req)
+		}
+	}
+}
+
+// Logging prints one line per attempt with its status, duration and the
+// request ID of the call, whatever header RequestID sends it in
+func Logging() Interceptor {
+	return Timing(func(req *http.Request, resp *http.Response, err error, elapsed time.Duration) {
+		requestID, _ := RequestIDFromContext(req.Context())
+		if err != nil {
+			fmt.Printf("http %s %s failed after %s, request id %q: %v\n", req.Method, req.URL.Redacted(), elapsed, requestID, err)
+			return
+		}
+		fmt.Printf("http %s %s -> %d in %s, request id %q\n", req.Method, req.URL.Redacted(), resp.StatusCode, elapsed, requestID)
+	})
+}
+
+// Timing calls observe after every attempt with how long it took until
+// the response headers arrived, resp is nil when err is set
+func Timing(observe func(req *http.Request, resp *http.Response, err error, elapsed time.Duration)) Interceptor {
+	return func(next Executor) Executor {
+		return func(req *http.Request) (*http.Response, error) {
+			start := time.Now()
+			resp, err := next(req)
+			observe(req, resp, err, time.Since(start))
+			return resp, err
+		}
+	}
+}
+
+// NewRequestID returns 32 random hex characters
+func NewRequestID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(b)
+}
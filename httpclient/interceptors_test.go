@@ -0,0 +1,166 @@
+package httpclient
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newEchoServer answers status and records the request headers
+func newEchoServer(t *testing.T, statuses ...int) (*httptest.Server, func() []http.Header) {
+	t.Helper()
+
+	var mu sync.Mutex
+	var headers []http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		headers = append(headers, r.Header.Clone())
+		status := statuses[min(len(headers), len(statuses))-1]
+		mu.Unlock()
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(server.Close)
+
+	return server, func() []http.Header {
+		mu.Lock()
+		defer mu.Unlock()
+		return headers
+	}
+}
+
+func TestClient_Interceptors(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Runs interceptors in order around every attempt", func(t *testing.T) {
+		server, _ := newEchoServer(t, http.StatusBadGateway, http.StatusOK)
+
+		var calls []string
+		trace := func(name string) Interceptor {
+			return func(next Executor) Executor {
+				return func(req *http.Request) (*http.Response, error) {
+					calls = append(calls, name+" before")
+					resp, err := next(req)
+					calls = append(calls, name+" after")
+					return resp, err
+				}
+			}
+		}
+		client := NewClient(
+			WithBaseURL(server.URL),
+			WithRetry(RetryPolicy{BaseDelay: time.Millisecond, MaxDelay: time.Millisecond}),
+			WithInterceptors(trace("outer")),
+			WithInterceptors(trace("inner")),
+		)
+
+		require.NoError(t, client.Get(ctx, "/", nil))
+		attempt := []string{"outer before", "inner before", "inner after", "outer after"}
+		assert.Equal(t, append(attempt, attempt...), calls)
+	})
+
+	t.Run("Sets request IDs", func(t *testing.T) {
+		tests := []struct {
+			name       string
+			ctx        context.Context
+			headers    map[string]string
+			expectedID string
+		}{
+			{name: "From the context", ctx: ContextWithRequestID(ctx, "incoming-1"), expectedID: "incoming-1"},
+			{name: "Keeps a header set by the caller", ctx: ctx, headers: map[string]string{"X-Request-ID": "caller-1"}, expectedID: "caller-1"},
+			{name: "Generates one otherwise", ctx: ctx},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				server, received := newEchoServer(t, http.StatusOK)
+				client := NewClient(WithBaseURL(server.URL), WithInterceptors(RequestID("")))
+
+				require.NoError(t, client.Do(tt.ctx, Request{Method: http.MethodGet, Path: "/", Headers: tt.headers}, nil))
+
+				id := received()[0].Get(DefaultRequestIDHeader)
+				if tt.expectedID == "" {
+					assert.Len(t, id, 32)
+					return
+				}
+				assert.Equal(t, tt.expectedID, id)
+			})
+		}
+	})
+
+	t.Run("Keeps one request ID over retries", func(t *testing.T) {
+		server, received := newEchoServer(t, http.StatusBadGateway, http.StatusBadGateway, http.StatusOK)
+		client := NewClient(
+			WithBaseURL(server.URL),
+			WithRetry(RetryPolicy{BaseDelay: time.Millisecond}),
+			WithInterceptors(RequestID("")),
+		)
+
+		require.NoError(t, client.Get(ctx, "/", nil))
+		require.NoError(t, client.Get(ctx, "/", nil))
+
+		headers := received()
+		require.Len(t, headers, 4)
+		first := headers[0].Get(DefaultRequestIDHeader)
+		assert.Len(t, first, 32)
+		assert.Equal(t, first, headers[1].Get(DefaultRequestIDHeader))
+		assert.Equal(t, first, headers[2].Get(DefaultRequestIDHeader))
+		assert.NotEqual(t, first, headers[3].Get(DefaultRequestIDHeader), "each call gets its own ID")
+	})
+
+	t.Run("Logs the request ID of the call", func(t *testing.T) {
+		server, received := newEchoServer(t, http.StatusOK)
+		client := NewClient(WithBaseURL(server.URL), WithInterceptors(RequestID("X-Correlation-Id"), Logging()))
+
+		output := captureStdout(t, func() {
+			require.NoError(t, client.Get(ctx, "/", nil))
+		})
+
+		headers := received()
+		require.Len(t, headers, 1)
+		id := headers[0].Get("X-Correlation-Id")
+		require.NotEmpty(t, id)
+		assert.Contains(t, output, fmt.Sprintf("request id %q", id))
+	})
+
+	t.Run("Times every attempt", func(t *testing.T) {
+		server, _ := newEchoServer(t, http.StatusNotFound)
+
+		var statuses []int
+		client := NewClient(WithBaseURL(server.URL), WithInterceptors(
+			Logging(),
+			Timing(func(req *http.Request, resp *http.Response, err error, elapsed time.Duration) {
+				require.NoError(t, err)
+				assert.Positive(t, elapsed)
+				statuses = append(statuses, resp.StatusCode)
+			}),
+		))
+
+		assert.True(t, IsNotFound(client.Get(ctx, "/", nil)))
+		assert.Equal(t, []int{http.StatusNotFound}, statuses)
+	})
+}
+
+// captureStdout returns what fn prints
+func captureStdout(t *testing.T, fn func()) string {
+	t.Helper()
+
+	reader, writer, err := os.Pipe()
+	require.NoError(t, err)
+	stdout := os.Stdout
+	os.Stdout = writer
+	defer func() { os.Stdout = stdout }()
+
+	fn()
+	require.NoError(t, writer.Close())
+	output, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	return string(output)
+}
@@ -0,0 +1,109 @@
+package httpclient
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// RequestOption adjusts a single request made by the JSON helpers
+type RequestOption func(r *Request)
+
+// WithQuery adds a query parameter
+func WithQuery(key, value string) RequestOption {
+	return func(r *Request) {
+		if r.Query == nil {
+			r.Query = make(map[string]string)
+		}
+		r.Query[key] = value
+	}
+}
+
+// WithHeader sets a request header
+func WithHeader(key, value string) RequestOption {
+	return func(r *Request) {
+		if r.Headers == nil {
+			r.Headers = make(map[string]string)
+		}
+		r.Headers[key] = value
+	}
+}
+
+// Idempotent marks a POST or PATCH safe to retry, see Request.Idempotent
+func Idempotent() RequestOption {
+	return func(r *Request) {
+		r.Idempotent = true
+	}
+}
+
+// AllowEmptyBody accepts an empty 2xx response, see Request.AllowEmptyBody
+func AllowEmptyBody() RequestOption {
+	return func(r *Request) {
+		r.AllowEmptyBody = true
+	}
+}
+
+// CaptureResponse fills resp with the status and headers of the response
+func CaptureResponse(resp *Response) RequestOption {
+	return func(r *Request) {
+		r.Capture = resp
+	}
+}
+
+// GetJSON decodes the response of a GET to path into a T
+func GetJSON[T any](ctx context.Context, c *Client, path string, opts ...RequestOption) (T, error) {
+	return doJSON[T](ctx, c, newJSONRequest(http.MethodGet, path, nil, opts))
+}
+
+// PostJSON sends body encoded as JSON and decodes the response into a Resp
+func PostJSON[Req, Resp any](ctx context.Context, c *Client, path string, body Req, opts ...RequestOption) (Resp, error) {
+	return sendJSON[Req, Resp](ctx, c, http.MethodPost, path, body, opts)
+}
+
+// PutJSON is PostJSON with PUT
+func PutJSON[Req, Resp any](ctx context.Context, c *Client, path string, body Req, opts ...RequestOption) (Resp, error) {
+	return sendJSON[Req, Resp](ctx, c, http.MethodPut, path, body, opts)
+}
+
+// PatchJSON is PostJSON with PATCH
+func PatchJSON[Req, Resp any](ctx context.Context, c *Client, path string, body Req, opts ...RequestOption) (Resp, error) {
+	return sendJSON[Req, Resp](ctx, c, http.MethodPatch, path, body, opts)
+}
+
+// DeleteJSON decodes the response of a DELETE to path into a T, the zero
+// T for a 204 or, with AllowEmptyBody, another empty response
+func DeleteJSON[T any](ctx context.Context, c *Client, path string, opts ...RequestOption) (T, error) {
+	return doJSON[T](ctx, c, newJSONRequest(http.MethodDelete, path, nil, opts))
+}
+
+func sendJSON[Req, Resp any](ctx context.Context, c *Client, method, path string, body Req, opts []RequestOption) (Resp, error) {
+	data, err := json.Marshal(body)
+	if err != nil {
+		var zero Resp
+		return zero, fmt.Errorf("encoding request: %w", err)
+	}
+	return doJSON[Resp](ctx, c, newJSONRequest(method, path, data, opts))
+}
+
+func doJSON[T any](ctx context.Context, c *Client, r Request) (T, error) {
+	var result T
+	if err := c.Do(ctx, r, &result); err != nil {
+		var zero T
+		return zero, err
+	}
+	return result, nil
+}
+
+func newJSONRequest(method, path string, body []byte, opts []RequestOption) Request {
+	r := Request{
+		Method:  method,
+		Path:    path,
+		Headers: map[string]string{"Accept": "application/json"},
+		Body:    body,
+	}
+	for _, opt := range opts {
+		opt(&r)
+	}
+	return r
+}
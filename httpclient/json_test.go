@@ -0,0 +1,168 @@
+package httpclient
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/brianwu291/go-learn/types"
+)
+
+type (
+	createProduct struct {
+		Title string  `json:"title"`
+		Price float64 `json:"price"`
+	}
+
+	// received is what the echo server saw, sent back as the response
+	received struct {
+		Method      string            `json:"method"`
+		Query       string            `json:"query"`
+		ContentType string            `json:"contentType"`
+		Accept      string            `json:"accept"`
+		Body        json.RawMessage   `json:"body"`
+		Headers     map[string]string `json:"headers"`
+	}
+)
+
+func newJSONEchoServer(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/empty":
+			w.WriteHeader(http.StatusNoContent)
+			return
+		case "/blank":
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+
+		body, _ := io.ReadAll(r.Body)
+		if len(body) == 0 {
+			body = []byte("null")
+		}
+		w.Header().Set("X-Total-Count", "20")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(received{
+			Method:      r.Method,
+			Query:       r.URL.RawQuery,
+			ContentType: r.Header.Get("Content-Type"),
+			Accept:      r.Header.Get("Accept"),
+			Body:        body,
+			Headers:     map[string]string{"X-Trace": r.Header.Get("X-Trace")},
+		})
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestJSONHelpers(t *testing.T) {
+	ctx := context.Background()
+	client := NewClient(WithBaseURL(newJSONEchoServer(t).URL))
+	product := createProduct{Title: "shirt", Price: 9.5}
+
+	tests := []struct {
+		name           string
+		call           func(opts ...RequestOption) (received, error)
+		expectedMethod string
+		expectedBody   string
+	}{
+		{
+			name: "GetJSON",
+			call: func(opts ...RequestOption) (received, error) {
+				return GetJSON[received](ctx, client, "/products", opts...)
+			},
+			expectedMethod: http.MethodGet,
+			expectedBody:   "null",
+		},
+		{
+			name: "PostJSON",
+			call: func(opts ...RequestOption) (received, error) {
+				return PostJSON[createProduct, received](ctx, client, "/products", product, opts...)
+			},
+			expectedMethod: http.MethodPost,
+			expectedBody:   `{"title":"shirt","price":9.5}`,
+		},
+		{
+			name: "PutJSON",
+			call: func(opts ...RequestOption) (received, error) {
+				return PutJSON[createProduct, received](ctx, client, "/products/1", product, opts...)
+			},
+			expectedMethod: http.MethodPut,
+			expectedBody:   `{"title":"shirt","price":9.5}`,
+		},
+		{
+			name: "PatchJSON",
+			call: func(opts ...RequestOption) (received, error) {
+				return PatchJSON[map[string]float64, received](ctx, client, "/products/1", map[string]float64{"price": 5}, opts...)
+			},
+			expectedMethod: http.MethodPatch,
+			expectedBody:   `{"price":5}`,
+		},
+		{
+			name: "DeleteJSON",
+			call: func(opts ...RequestOption) (received, error) {
+				return DeleteJSON[received](ctx, client, "/products/1", opts...)
+			},
+			expectedMethod: http.MethodDelete,
+			expectedBody:   "null",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var resp Response
+			got, err := tt.call(WithQuery("limit", "5"), WithHeader("X-Trace", "abc"), CaptureResponse(&resp))
+			require.NoError(t, err)
+
+			assert.Equal(t, tt.expectedMethod, got.Method)
+			assert.Equal(t, "limit=5", got.Query)
+			assert.Equal(t, "application/json", got.Accept)
+			assert.Equal(t, "abc", got.Headers["X-Trace"])
+			assert.JSONEq(t, tt.expectedBody, string(got.Body))
+			if tt.expectedBody != "null" {
+				assert.Equal(t, "application/json", got.ContentType)
+			}
+
+			assert.Equal(t, http.StatusCreated, resp.StatusCode)
+			assert.Equal(t, "20", resp.Header.Get("X-Total-Count"))
+		})
+	}
+
+	t.Run("Leaves the result empty for an empty body", func(t *testing.T) {
+		var resp Response
+		got, err := DeleteJSON[*received](ctx, client, "/empty", CaptureResponse(&resp))
+		require.NoError(t, err)
+		assert.Nil(t, got)
+		assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+	})
+
+	t.Run("Fails on an empty body the caller did not allow", func(t *testing.T) {
+		_, err := GetJSON[types.Product](ctx, client, "/blank")
+		assert.ErrorContains(t, err, "decoding response: EOF")
+
+		got, err := DeleteJSON[*received](ctx, client, "/blank", AllowEmptyBody())
+		require.NoError(t, err)
+		assert.Nil(t, got)
+	})
+
+	t.Run("Rejects a body that cannot be encoded", func(t *testing.T) {
+		_, err := PostJSON[chan int, received](ctx, client, "/products", make(chan int))
+		assert.ErrorContains(t, err, "encoding request")
+	})
+
+	t.Run("Captures the response of an error status", func(t *testing.T) {
+		server, _ := newEchoServer(t, http.StatusNotFound)
+		var resp Response
+		_, err := GetJSON[received](ctx, NewClient(WithBaseURL(server.URL)), "/products/99", CaptureResponse(&resp))
+		assert.True(t, IsNotFound(err))
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	})
+}
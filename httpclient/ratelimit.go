@@ -0,0 +1,241 @@
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/brianwu291/go-learn/cache"
+)
+
+type (
+	// RateLimit is a token bucket per host refilling Rate tokens a second
+	// up to Burst. Every attempt, retries included, takes a token.
+	RateLimit struct {
+		Rate  float64
+		Burst int
+		// MaxWait bounds how long an attempt waits for a token, zero fails
+		// at once when the bucket is empty
+		MaxWait time.Duration
+	}
+
+	// limiter reserves a token for host and tells how long to wait for
+	// it. Buckets are kept as GCRA theoretical arrival times, tat, the
+	// time the bucket is full again, in microseconds.
+	limiter interface {
+		reserve(ctx context.Context, host string) (time.Duration, error)
+	}
+
+	localLimiter struct {
+		limit gcraLimit
+		now   func() time.Time
+
+		mu   sync.Mutex
+		tats map[string]int64
+	}
+
+	// distributedLimiter shares the buckets through the cache, so every
+	// instance draws from one budget per host. Time is read from the cache
+	// server, pods with skewed clocks would otherwise move the buckets.
+	distributedLimiter struct {
+		client cache.Client
+		keys   cache.KeyFamily
+		limit  gcraLimit
+	}
+
+	gcraLimit struct {
+		// interval is the time one token takes to refill
+		interval int64
+		burst    int64
+		maxWait  int64
+	}
+)
+
+const (
+	// KEYS[1] bucket, ARGV[1] interval, ARGV[2] burst, ARGV[3] max wait,
+	// all in microseconds, now is the server TIME. Returns {1, wait} with
+	// the token reserved or {0, wait} when the wait is too long.
+	// replicate_commands lets Redis before 5 write after reading TIME.
+	reserveTokenScript = `
+    redis.replicate_commands()
+    local time = redis.call('TIME')
+    local now = tonumber(time[1]) * 1000000 + tonumber(time[2])
+    local interval = tonumber(ARGV[1])
+    local burst = tonumber(ARGV[2])
+    local max_wait = tonumber(ARGV[3])
+
+    local tat = math.max(tonumber(redis.call('GET', KEYS[1]) or "0"), now)
+    local new_tat = tat + interval
+    local wait = math.max(new_tat - burst * interval - now, 0)
+    if wait > max_wait then
+        return {0, wait}
+    end
+
+    redis.call('SET', KEYS[1], string.format('%.0f', new_tat), 'PX', math.ceil((new_tat - now) / 1000) + 1)
+    return {1, wait}
+  `
+)
+
+var (
+	ErrRateLimited = errors.New("outbound rate limit exceeded")
+
+	outboundRateLimitFamily = cache.KeyFamily{
+		Name:        "outboundRateLimit",
+		Version:     1,
+		Description: "token buckets of upstream hosts shared by every instance",
+	}
+)
+
+// WithRateLimit throttles attempts per upstream host within this process
+func WithRateLimit(limit RateLimit) Option {
+	l := &localLimiter{
+		limit: limit.gcra(),
+		now:   time.Now,
+		tats:  make(map[string]int64),
+	}
+	return func(c *Client) {
+		c.limiter = l
+	}
+}
+
+// WithDistributedRateLimit is WithRateLimit with the buckets kept in
+// client, so all instances share one outbound budget. When the cache
+// fails, attempts go out unthrottled.
+func WithDistributedRateLimit(client cache.Client, cacheKeys *cache.KeyBuilder, limit RateLimit) Option {
+	if registrar, ok := client.(cache.ScriptRegistrar); ok {
+		registrar.RegisterScript(reserveTokenScript, reserveTokenFunc)
+	}
+
+	l := &distributedLimiter{
+		client: client,
+		keys:   cacheKeys.Register(outboundRateLimitFamily),
+		limit:  limit.gcra(),
+	}
+	return func(c *Client) {
+		c.limiter = l
+	}
+}
+
+// throttle waits for a token of host, or fails with ErrRateLimited when
+// that would take longer than MaxWait
+func (c *Client) throttle(ctx context.Context, host string) error {
+	if c.limiter == nil {
+		return nil
+	}
+
+	delay, err := c.limiter.reserve(ctx, host)
+	if err != nil {
+		return err
+	}
+	if delay > 0 {
+		return wait(ctx, delay)
+	}
+	return nil
+}
+
+func (l *localLimiter) reserve(ctx context.Context, host string) (time.Duration, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	tat, wait, ok := l.limit.reserve(l.tats[host], l.now().UnixMicro())
+	if !ok {
+		return 0, fmt.Errorf("%w: %s needs a %s wait", ErrRateLimited, host, micros(wait))
+	}
+	l.tats[host] = tat
+	return micros(wait), nil
+}
+
+func (l *distributedLimiter) reserve(ctx context.Context, host string) (time.Duration, error) {
+	key := l.keys.Key(host)
+	result, err := l.client.Eval(ctx, reserveTokenScript, []string{key}, []interface{}{
+		l.limit.interval, l.limit.burst, l.limit.maxWait,
+	})
+	if err != nil {
+		fmt.Printf("outbound rate limit skipped for %s, cache unavailable: %v\n", host, err)
+		return 0, nil
+	}
+
+	reserved, wait, ok := parseReservation(result)
+	if !ok {
+		fmt.Printf("outbound rate limit skipped for %s, unexpected script result: %v\n", host, result)
+		return 0, nil
+	}
+	if !reserved {
+		return 0, fmt.Errorf("%w: %s needs a %s wait", ErrRateLimited, host, wait)
+	}
+	return wait, nil
+}
+
+// parseReservation reads the {reserved, wait} reply of reserveTokenScript
+func parseReservation(result interface{}) (reserved bool, wait time.Duration, ok bool) {
+	results, ok := result.([]interface{})
+	if !ok || len(results) != 2 {
+		return false, 0, false
+	}
+	flag, ok := results[0].(int64)
+	if !ok {
+		return false, 0, false
+	}
+	us, ok := results[1].(int64)
+	if !ok {
+		return false, 0, false
+	}
+	return flag == 1, micros(us), true
+}
+
+// reserve takes a token from the bucket full again at tat, now and the
+// result are microseconds. The wait is how long until the token is there.
+func (g gcraLimit) reserve(tat, now int64) (newTAT, wait int64, ok bool) {
+	newTAT = max(tat, now) + g.interval
+	wait = max(newTAT-g.burst*g.interval-now, 0)
+	if wait > g.maxWait {
+		return tat, wait, false
+	}
+	return newTAT, wait, true
+}
+
+func (limit RateLimit) gcra() gcraLimit {
+	if limit.Rate <= 0 {
+		panic(fmt.Errorf("httpclient: rate limit needs a positive rate, got %v", limit.Rate))
+	}
+	return gcraLimit{
+		interval: max(int64(float64(time.Second/time.Microsecond)/limit.Rate), 1),
+		burst:    int64(max(limit.Burst, 1)),
+		maxWait:  limit.MaxWait.Microseconds(),
+	}
+}
+
+// reserveTokenFunc is reserveTokenScript for clients emulating Eval, they
+// live in one process so its clock stands in for the server TIME
+func reserveTokenFunc(ctx context.Context, c cache.Client, keys []string, args []interface{}) (interface{}, error) {
+	now := time.Now().UnixMicro()
+	limit := gcraLimit{interval: args[0].(int64), burst: args[1].(int64), maxWait: args[2].(int64)}
+
+	var tat int64
+	value, err := c.Get(ctx, keys[0])
+	if err != nil && !cache.IsKeyNotFound(err) {
+		return nil, err
+	}
+	if err == nil {
+		if tat, err = strconv.ParseInt(value, 10, 64); err != nil {
+			return nil, err
+		}
+	}
+
+	newTAT, wait, ok := limit.reserve(tat, now)
+	if !ok {
+		return []interface{}{int64(0), wait}, nil
+	}
+	ttl := time.Duration(newTAT-now)*time.Microsecond + time.Millisecond
+	if err := c.Set(ctx, keys[0], strconv.FormatInt(newTAT, 10), ttl); err != nil {
+		return nil, err
+	}
+	return []interface{}{int64(1), wait}, nil
+}
+
+func micros(us int64) time.Duration {
+	return time.Duration(us) * time.Microsecond
+}
@@ -0,0 +1,185 @@
+package httpclient
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/brianwu291/go-learn/cache"
+	"github.com/brianwu291/go-learn/cache/cachetest"
+	"github.com/brianwu291/go-learn/cache/memory"
+	redisclient "github.com/brianwu291/go-learn/db/redis"
+)
+
+func TestGCRALimit_Reserve(t *testing.T) {
+	// a token every 100ms, 2 at once, waiting up to 150ms
+	limit := gcraLimit{interval: 100_000, burst: 2, maxWait: 150_000}
+
+	tests := []struct {
+		name         string
+		tat          int64
+		now          int64
+		expectedTAT  int64
+		expectedWait int64
+		expectedOK   bool
+	}{
+		{name: "Full bucket", tat: 0, now: 1_000_000, expectedTAT: 1_100_000, expectedOK: true},
+		{name: "Last token of the burst", tat: 1_100_000, now: 1_000_000, expectedTAT: 1_200_000, expectedOK: true},
+		{name: "Waits for the next token", tat: 1_200_000, now: 1_000_000, expectedTAT: 1_300_000, expectedWait: 100_000, expectedOK: true},
+		{name: "Wait too long", tat: 1_300_000, now: 1_000_000, expectedTAT: 1_300_000, expectedWait: 200_000},
+		{name: "Refilled meanwhile", tat: 1_300_000, now: 1_250_000, expectedTAT: 1_400_000, expectedOK: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tat, wait, ok := limit.reserve(tt.tat, tt.now)
+			assert.Equal(t, tt.expectedTAT, tat)
+			assert.Equal(t, tt.expectedWait, wait)
+			assert.Equal(t, tt.expectedOK, ok)
+		})
+	}
+}
+
+func TestClient_RateLimit(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Fails once the burst is used up", func(t *testing.T) {
+		server, received := newEchoServer(t, http.StatusOK)
+		client := NewClient(WithBaseURL(server.URL), WithRateLimit(RateLimit{Rate: 1, Burst: 2}))
+
+		require.NoError(t, client.Get(ctx, "/", nil))
+		require.NoError(t, client.Get(ctx, "/", nil))
+		assert.ErrorIs(t, client.Get(ctx, "/", nil), ErrRateLimited)
+		assert.Len(t, received(), 2)
+	})
+
+	t.Run("Waits for a token within MaxWait", func(t *testing.T) {
+		server, received := newEchoServer(t, http.StatusOK)
+		client := NewClient(WithBaseURL(server.URL), WithRateLimit(RateLimit{Rate: 20, MaxWait: time.Second}))
+
+		start := time.Now()
+		for i := 0; i < 3; i++ {
+			require.NoError(t, client.Get(ctx, "/", nil))
+		}
+		assert.GreaterOrEqual(t, time.Since(start), 90*time.Millisecond)
+		assert.Len(t, received(), 3)
+	})
+
+	t.Run("Stops waiting when the context is done", func(t *testing.T) {
+		server, _ := newEchoServer(t, http.StatusOK)
+		client := NewClient(WithBaseURL(server.URL), WithRateLimit(RateLimit{Rate: 0.1, MaxWait: time.Minute}))
+		require.NoError(t, client.Get(ctx, "/", nil))
+
+		timeoutCtx, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+		defer cancel()
+		assert.ErrorIs(t, client.Get(timeoutCtx, "/", nil), context.DeadlineExceeded)
+	})
+}
+
+// oddScriptClient answers every Eval with result
+type oddScriptClient struct {
+	cache.Client
+	result interface{}
+}
+
+func (c oddScriptClient) Eval(ctx context.Context, script string, keys []string, args []interface{}) (interface{}, error) {
+	return c.result, nil
+}
+
+func TestClient_DistributedRateLimit(t *testing.T) {
+	ctx := context.Background()
+
+	backends := []struct {
+		name      string
+		newClient func(t *testing.T) cache.Client
+	}{
+		{
+			name:      "In memory",
+			newClient: func(t *testing.T) cache.Client { return memory.NewClient() },
+		},
+		{
+			name: "Redis",
+			newClient: func(t *testing.T) cache.Client {
+				server := miniredis.RunT(t)
+				client, err := redisclient.NewClient(&cache.Config{Host: server.Host(), Port: server.Port()})
+				require.NoError(t, err)
+				t.Cleanup(func() { client.Close() })
+				return client
+			},
+		},
+	}
+
+	for _, backend := range backends {
+		t.Run(backend.name, func(t *testing.T) {
+			server, received := newEchoServer(t, http.StatusOK)
+			cacheClient := backend.newClient(t)
+			newInstance := func() *Client {
+				return NewClient(
+					WithBaseURL(server.URL),
+					WithDistributedRateLimit(cacheClient, cache.NewKeyBuilder("test", "test"), RateLimit{Rate: 1, Burst: 2}),
+				)
+			}
+			first, second := newInstance(), newInstance()
+
+			require.NoError(t, first.Get(ctx, "/", nil))
+			require.NoError(t, second.Get(ctx, "/", nil))
+			assert.ErrorIs(t, first.Get(ctx, "/", nil), ErrRateLimited)
+			assert.ErrorIs(t, second.Get(ctx, "/", nil), ErrRateLimited)
+			assert.Len(t, received(), 2)
+		})
+	}
+
+	t.Run("Refills by the Redis clock", func(t *testing.T) {
+		server, received := newEchoServer(t, http.StatusOK)
+		redisServer := miniredis.RunT(t)
+		cacheClient, err := redisclient.NewClient(&cache.Config{Host: redisServer.Host(), Port: redisServer.Port()})
+		require.NoError(t, err)
+		t.Cleanup(func() { cacheClient.Close() })
+		client := NewClient(
+			WithBaseURL(server.URL),
+			WithDistributedRateLimit(cacheClient, cache.NewKeyBuilder("test", "test"), RateLimit{Rate: 1}),
+		)
+
+		// far from the pod clock, a bucket kept by it would never refill
+		start := time.Now().Add(-24 * time.Hour)
+		redisServer.SetTime(start)
+		require.NoError(t, client.Get(ctx, "/", nil))
+		assert.ErrorIs(t, client.Get(ctx, "/", nil), ErrRateLimited)
+
+		redisServer.SetTime(start.Add(time.Second))
+		require.NoError(t, client.Get(ctx, "/", nil))
+		assert.Len(t, received(), 2)
+	})
+
+	t.Run("Lets requests through when the cache fails", func(t *testing.T) {
+		server, received := newEchoServer(t, http.StatusOK)
+		faulty := cachetest.NewFaultyClient(memory.NewClient(), cachetest.Faults{ErrorRate: 1})
+		client := NewClient(
+			WithBaseURL(server.URL),
+			WithDistributedRateLimit(faulty, cache.NewKeyBuilder("test", "test"), RateLimit{Rate: 1}),
+		)
+
+		for i := 0; i < 3; i++ {
+			require.NoError(t, client.Get(ctx, "/", nil))
+		}
+		assert.Len(t, received(), 3)
+	})
+
+	t.Run("Lets requests through on an unexpected script result", func(t *testing.T) {
+		for _, result := range []interface{}{nil, "OK", []interface{}{int64(1)}, []interface{}{"1", "0"}} {
+			server, received := newEchoServer(t, http.StatusOK)
+			client := NewClient(
+				WithBaseURL(server.URL),
+				WithDistributedRateLimit(oddScriptClient{memory.NewClient(), result}, cache.NewKeyBuilder("test", "test"), RateLimit{Rate: 1}),
+			)
+
+			require.NoError(t, client.Get(ctx, "/", nil))
+			assert.Len(t, received(), 1)
+		}
+	})
+}
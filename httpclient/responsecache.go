@@ -0,0 +1,300 @@
+package httpclient
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/brianwu291/go-learn/cache"
+)
+
+type (
+	ResponseCacheConfig struct {
+		// DefaultMaxAge is the freshness of responses without max-age, zero
+		// revalidates them on every request
+		DefaultMaxAge time.Duration
+		// StaleTTL keeps entries with an ETag or Last-Modified this long
+		// after they went stale, so they can still be revalidated
+		StaleTTL time.Duration
+		// MaxBodyBytes skips storing larger responses
+		MaxBodyBytes int64
+	}
+
+	// responseCache is a shared cache in the RFC 9111 sense, every
+	// instance reads what one stored, so private responses are skipped
+	responseCache struct {
+		client cache.Client
+		keys   cache.KeyFamily
+		config ResponseCacheConfig
+		now    func() time.Time
+	}
+
+	cachedResponse struct {
+		StatusCode int         `json:"statusCode"`
+		Header     http.Header `json:"header"`
+		Body       []byte      `json:"body"`
+		// FreshUntil is when the entry needs revalidation
+		FreshUntil time.Time `json:"freshUntil"`
+	}
+
+	cacheControl map[string]string
+
+	readCloser struct {
+		io.Reader
+		io.Closer
+	}
+)
+
+const (
+	defaultStaleTTL     = 24 * time.Hour
+	defaultMaxBodyBytes = 1 << 20
+)
+
+var (
+	responseCacheFamily = cache.KeyFamily{
+		Name:        "httpResponses",
+		Version:     1,
+		Description: "upstream GET responses by hashed URL, with validators for revalidation",
+	}
+
+	// storedHeaders are the response headers kept with an entry
+	storedHeaders = []string{"Content-Type", "Cache-Control", "ETag", "Last-Modified", "Expires"}
+)
+
+// WithResponseCache caches GET responses in client following their
+// Cache-Control: max-age and s-maxage set the freshness, no-store and
+// private skip storing, no-cache revalidates every time. Stale entries
+// with an ETag or Last-Modified are revalidated with If-None-Match or
+// If-Modified-Since, a 304 then serves the stored body.
+func WithResponseCache(client cache.Client, cacheKeys *cache.KeyBuilder, config ResponseCacheConfig) Option {
+	if config.StaleTTL <= 0 {
+		config.StaleTTL = defaultStaleTTL
+	}
+	if config.MaxBodyBytes <= 0 {
+		config.MaxBodyBytes = defaultMaxBodyBytes
+	}
+
+	return func(c *Client) {
+		c.responseCache = &responseCache{
+			client: client,
+			keys:   cacheKeys.Register(responseCacheFamily),
+			config: config,
+			now:    time.Now,
+		}
+	}
+}
+
+// do serves r from the cache when fresh, otherwise calls send and stores
+// the response when allowed. Cache failures are logged, the request then
+// goes upstream as if nothing was cached.
+func (rc *responseCache) do(ctx context.Context, r Request, url string, send func(r Request) (*http.Response, error)) (*http.Response, error) {
+	if r.Method != http.MethodGet || hasHeader(r.Headers, "Authorization") ||
+		parseCacheControl(headerValue(r.Headers, "Cache-Control")).has("no-store") {
+		return send(r)
+	}
+
+	key := rc.keys.Key(hashURL(url))
+	entry, found := rc.load(ctx, key)
+	if found && rc.now().Before(entry.FreshUntil) {
+		return entry.response(), nil
+	}
+
+	if found {
+		r = withValidators(r, entry.Header)
+	}
+	resp, err := send(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if found && resp.StatusCode == http.StatusNotModified {
+		drain(resp)
+		entry.refresh(resp.Header)
+		rc.store(ctx, key, entry, resp.Header)
+		return entry.response(), nil
+	}
+
+	if resp.StatusCode != http.StatusOK || !rc.storable(resp.Header) {
+		return resp, nil
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, rc.config.MaxBodyBytes+1))
+	if err != nil {
+		resp.Body.Close()
+		return nil, fmt.Errorf("reading response: %w", err)
+	}
+	if int64(len(body)) > rc.config.MaxBodyBytes {
+		// too large to store, the caller still reads all of it
+		resp.Body = readCloser{Reader: io.MultiReader(bytes.NewReader(body), resp.Body), Closer: resp.Body}
+		return resp, nil
+	}
+	resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	entry = cachedResponse{StatusCode: resp.StatusCode, Header: make(http.Header), Body: body}
+	entry.refresh(resp.Header)
+	rc.store(ctx, key, entry, resp.Header)
+	return resp, nil
+}
+
+func (rc *responseCache) load(ctx context.Context, key string) (cachedResponse, bool) {
+	data, err := rc.client.Get(ctx, key)
+	if err != nil {
+		if !cache.IsKeyNotFound(err) {
+			fmt.Printf("failed to read cached response %s: %+v\n", key, err)
+		}
+		return cachedResponse{}, false
+	}
+
+	var entry cachedResponse
+	if err := json.Unmarshal([]byte(data), &entry); err != nil {
+		fmt.Printf("failed to decode cached response %s: %+v\n", key, err)
+		return cachedResponse{}, false
+	}
+	return entry, true
+}
+
+func (rc *responseCache) store(ctx context.Context, key string, entry cachedResponse, header http.Header) {
+	entry.FreshUntil = rc.now().Add(rc.freshness(header))
+
+	ttl := entry.FreshUntil.Sub(rc.now())
+	if entry.Header.Get("ETag") != "" || entry.Header.Get("Last-Modified") != "" {
+		ttl += rc.config.StaleTTL
+	}
+	if ttl <= 0 {
+		return
+	}
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		fmt.Printf("failed to encode response for cache %s: %+v\n", key, err)
+		return
+	}
+	if err := rc.client.Set(ctx, key, data, ttl); err != nil {
+		fmt.Printf("failed to cache response %s: %+v\n", key, err)
+	}
+}
+
+// storable follows the response directives, responses differing per
+// request header other than Accept-Encoding are skipped as well
+func (rc *responseCache) storable(header http.Header) bool {
+	directives := parseCacheControl(header.Get("Cache-Control"))
+	if directives.has("no-store") || directives.has("private") {
+		return false
+	}
+	for _, vary := range header.Values("Vary") {
+		for _, name := range strings.Split(vary, ",") {
+			if !strings.EqualFold(strings.TrimSpace(name), "Accept-Encoding") {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// freshness prefers s-maxage, the shared cache limit, over max-age, and
+// takes off the Age the response already spent in other caches
+func (rc *responseCache) freshness(header http.Header) time.Duration {
+	directives := parseCacheControl(header.Get("Cache-Control"))
+	if directives.has("no-cache") {
+		return 0
+	}
+
+	maxAge := rc.config.DefaultMaxAge
+	if seconds, ok := directives.seconds("s-maxage"); ok {
+		maxAge = seconds
+	} else if seconds, ok := directives.seconds("max-age"); ok {
+		maxAge = seconds
+	}
+
+	if age, err := strconv.Atoi(header.Get("Age")); err == nil && age > 0 {
+		maxAge -= time.Duration(age) * time.Second
+	}
+	return max(maxAge, 0)
+}
+
+// refresh takes the stored headers from a 200 or the updated ones from a
+// 304
+func (e *cachedResponse) refresh(header http.Header) {
+	for _, name := range storedHeaders {
+		if value := header.Get(name); value != "" {
+			e.Header.Set(name, value)
+		}
+	}
+}
+
+func (e cachedResponse) response() *http.Response {
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", e.StatusCode, http.StatusText(e.StatusCode)),
+		StatusCode:    e.StatusCode,
+		Header:        e.Header.Clone(),
+		Body:          io.NopCloser(bytes.NewReader(e.Body)),
+		ContentLength: int64(len(e.Body)),
+	}
+}
+
+// withValidators copies r with the conditional headers for header
+func withValidators(r Request, header http.Header) Request {
+	headers := make(map[string]string, len(r.Headers)+2)
+	for name, value := range r.Headers {
+		headers[name] = value
+	}
+	if etag := header.Get("ETag"); etag != "" {
+		headers["If-None-Match"] = etag
+	}
+	if lastModified := header.Get("Last-Modified"); lastModified != "" {
+		headers["If-Modified-Since"] = lastModified
+	}
+	r.Headers = headers
+	return r
+}
+
+func parseCacheControl(value string) cacheControl {
+	directives := make(cacheControl)
+	for _, part := range strings.Split(value, ",") {
+		name, arg, _ := strings.Cut(strings.TrimSpace(part), "=")
+		if name != "" {
+			directives[strings.ToLower(name)] = strings.Trim(arg, `"`)
+		}
+	}
+	return directives
+}
+
+func (cc cacheControl) has(name string) bool {
+	_, ok := cc[name]
+	return ok
+}
+
+func (cc cacheControl) seconds(name string) (time.Duration, bool) {
+	seconds, err := strconv.Atoi(cc[name])
+	if err != nil || seconds < 0 {
+		return 0, false
+	}
+	return time.Duration(seconds) * time.Second, true
+}
+
+func hashURL(url string) string {
+	sum := sha256.Sum256([]byte(url))
+	return hex.EncodeToString(sum[:])
+}
+
+func hasHeader(headers map[string]string, name string) bool {
+	return headerValue(headers, name) != ""
+}
+
+func headerValue(headers map[string]string, name string) string {
+	for key, value := range headers {
+		if strings.EqualFold(key, name) {
+			return value
+		}
+	}
+	return ""
+}
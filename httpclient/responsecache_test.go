@@ -0,0 +1,179 @@
+package httpclient
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/brianwu291/go-learn/cache"
+	"github.com/brianwu291/go-learn/cache/cachetest"
+	"github.com/brianwu291/go-learn/cache/memory"
+)
+
+type product struct {
+	ID int `json:"id"`
+}
+
+// newCacheableServer answers a product with headers and a 304 when the
+// If-None-Match matches its ETag, it records the conditional headers
+func newCacheableServer(t *testing.T, headers map[string]string) (*httptest.Server, func() []string) {
+	t.Helper()
+
+	var mu sync.Mutex
+	var conditions []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		conditions = append(conditions, r.Header.Get("If-None-Match"))
+		mu.Unlock()
+
+		for name, value := range headers {
+			w.Header().Set(name, value)
+		}
+		if etag := headers["ETag"]; etag != "" && r.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"id":1}`))
+	}))
+	t.Cleanup(server.Close)
+
+	return server, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return conditions
+	}
+}
+
+func TestClient_ResponseCache(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name               string
+		responseHeaders    map[string]string
+		requestHeaders     map[string]string
+		advance            time.Duration
+		expectedConditions []string
+	}{
+		{
+			name:               "Serves a fresh response from the cache",
+			responseHeaders:    map[string]string{"Cache-Control": "max-age=60"},
+			expectedConditions: []string{""},
+		},
+		{
+			name:               "Goes upstream again once stale",
+			responseHeaders:    map[string]string{"Cache-Control": "max-age=60"},
+			advance:            time.Minute,
+			expectedConditions: []string{"", ""},
+		},
+		{
+			name:               "Takes off the upstream Age",
+			responseHeaders:    map[string]string{"Cache-Control": "max-age=60", "Age": "30"},
+			advance:            45 * time.Second,
+			expectedConditions: []string{"", ""},
+		},
+		{
+			name:               "Revalidates with the ETag",
+			responseHeaders:    map[string]string{"ETag": `"v1"`},
+			expectedConditions: []string{"", `"v1"`},
+		},
+		{
+			name:               "Revalidates no-cache responses",
+			responseHeaders:    map[string]string{"Cache-Control": "max-age=60, no-cache", "ETag": `"v1"`},
+			expectedConditions: []string{"", `"v1"`},
+		},
+		{
+			name:               "Does not store no-store responses",
+			responseHeaders:    map[string]string{"Cache-Control": "no-store", "ETag": `"v1"`},
+			expectedConditions: []string{"", ""},
+		},
+		{
+			name:               "Does not store private responses",
+			responseHeaders:    map[string]string{"Cache-Control": "private, max-age=60"},
+			expectedConditions: []string{"", ""},
+		},
+		{
+			name:               "Does not store responses varying by header",
+			responseHeaders:    map[string]string{"Cache-Control": "max-age=60", "Vary": "Accept-Encoding, Accept-Language"},
+			expectedConditions: []string{"", ""},
+		},
+		{
+			name:               "Skips authorized requests",
+			responseHeaders:    map[string]string{"Cache-Control": "max-age=60"},
+			requestHeaders:     map[string]string{"Authorization": "Bearer token"},
+			expectedConditions: []string{"", ""},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, conditions := newCacheableServer(t, tt.responseHeaders)
+			client := NewClient(
+				WithBaseURL(server.URL),
+				WithResponseCache(memory.NewClient(), cache.NewKeyBuilder("test", "test"), ResponseCacheConfig{}),
+			)
+			now := time.Now()
+			client.responseCache.now = func() time.Time { return now }
+
+			for i := 0; i < 2; i++ {
+				var result product
+				err := client.Do(ctx, Request{Method: http.MethodGet, Path: "/products/1", Headers: tt.requestHeaders}, &result)
+				require.NoError(t, err)
+				assert.Equal(t, 1, result.ID)
+				now = now.Add(tt.advance)
+			}
+
+			assert.Equal(t, tt.expectedConditions, conditions())
+		})
+	}
+
+	t.Run("Keys entries by query", func(t *testing.T) {
+		server, conditions := newCacheableServer(t, map[string]string{"Cache-Control": "max-age=60"})
+		client := NewClient(
+			WithBaseURL(server.URL),
+			WithResponseCache(memory.NewClient(), cache.NewKeyBuilder("test", "test"), ResponseCacheConfig{}),
+		)
+
+		for _, limit := range []string{"1", "2", "1"} {
+			require.NoError(t, client.Do(ctx, Request{Method: http.MethodGet, Path: "/products", Query: map[string]string{"limit": limit}}, nil))
+		}
+		assert.Len(t, conditions(), 2)
+	})
+
+	t.Run("Passes larger bodies through whole without storing them", func(t *testing.T) {
+		server, conditions := newCacheableServer(t, map[string]string{"Cache-Control": "max-age=60"})
+		client := NewClient(
+			WithBaseURL(server.URL),
+			WithResponseCache(memory.NewClient(), cache.NewKeyBuilder("test", "test"), ResponseCacheConfig{MaxBodyBytes: 4}),
+		)
+
+		for i := 0; i < 2; i++ {
+			var result product
+			require.NoError(t, client.Get(ctx, "/products/1", &result))
+			assert.Equal(t, 1, result.ID)
+		}
+		assert.Len(t, conditions(), 2)
+	})
+
+	t.Run("Goes upstream when the cache fails", func(t *testing.T) {
+		server, conditions := newCacheableServer(t, map[string]string{"Cache-Control": "max-age=60"})
+		faulty := cachetest.NewFaultyClient(memory.NewClient(), cachetest.Faults{ErrorRate: 1})
+		client := NewClient(
+			WithBaseURL(server.URL),
+			WithResponseCache(faulty, cache.NewKeyBuilder("test", "test"), ResponseCacheConfig{}),
+		)
+
+		for i := 0; i < 2; i++ {
+			var result product
+			require.NoError(t, client.Get(ctx, "/products/1", &result))
+			assert.Equal(t, 1, result.ID)
+		}
+		assert.Len(t, conditions(), 2)
+	})
+}
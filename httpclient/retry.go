@@ -0,0 +1,167 @@
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"slices"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+type (
+	// RetryPolicy retries idempotent requests that failed on the network or
+	// got a retryable status. Zero fields take the defaults.
+	RetryPolicy struct {
+		// MaxAttempts counts the first attempt too
+		MaxAttempts int
+		// BaseDelay doubles per retry up to MaxDelay, half of each delay
+		// is randomized so clients retrying together spread out
+		BaseDelay time.Duration
+		MaxDelay  time.Duration
+		// RetryableStatuses defaults to 408, 429, 500, 502, 503 and 504
+		RetryableStatuses []int
+		// RetryableError tells which failed attempts are retried, it
+		// defaults to IsRetryableNetworkError
+		RetryableError func(err error) bool
+	}
+)
+
+const (
+	defaultMaxAttempts = 3
+	defaultBaseDelay   = 100 * time.Millisecond
+	defaultMaxDelay    = 5 * time.Second
+
+	// maxDrainBytes bounds what is read from a discarded response so the
+	// connection can be reused
+	maxDrainBytes = 64 << 10
+)
+
+var defaultRetryableStatuses = []int{
+	http.StatusRequestTimeout,
+	http.StatusTooManyRequests,
+	http.StatusInternalServerError,
+	http.StatusBadGateway,
+	http.StatusServiceUnavailable,
+	http.StatusGatewayTimeout,
+}
+
+// WithRetry retries failed requests, see RetryPolicy
+func WithRetry(policy RetryPolicy) Option {
+	if policy.MaxAttempts <= 0 {
+		policy.MaxAttempts = defaultMaxAttempts
+	}
+	if policy.BaseDelay <= 0 {
+		policy.BaseDelay = defaultBaseDelay
+	}
+	if policy.MaxDelay <= 0 {
+		policy.MaxDelay = defaultMaxDelay
+	}
+	if policy.RetryableStatuses == nil {
+		policy.RetryableStatuses = defaultRetryableStatuses
+	}
+	if policy.RetryableError == nil {
+		policy.RetryableError = IsRetryableNetworkError
+	}
+
+	return func(c *Client) {
+		c.retry = &policy
+	}
+}
+
+// next decides whether attempt is retried and after how long. A
+// Retry-After longer than MaxDelay is not waited for, the response is
+// returned as it is.
+func (p *RetryPolicy) next(ctx context.Context, attempt int, resp *http.Response, err error) (time.Duration, bool) {
+	if ctx.Err() != nil {
+		return 0, false
+	}
+
+	delay := p.backoff(attempt)
+	if err != nil {
+		return delay, p.RetryableError(err)
+	}
+	if !slices.Contains(p.RetryableStatuses, resp.StatusCode) {
+		return 0, false
+	}
+
+	if retryAfter, ok := parseRetryAfter(resp.Header.Get("Retry-After")); ok {
+		if retryAfter > p.MaxDelay {
+			return 0, false
+		}
+		delay = max(delay, retryAfter)
+	}
+	return delay, true
+}
+
+func (p *RetryPolicy) backoff(attempt int) time.Duration {
+	delay := p.BaseDelay
+	for i := 1; i < attempt && delay < p.MaxDelay; i++ {
+		delay *= 2
+	}
+	delay = min(delay, p.MaxDelay)
+
+	half := delay / 2
+	if half <= 0 {
+		return delay
+	}
+	return half + time.Duration(rand.Int64N(int64(half)))
+}
+
+// IsRetryableNetworkError is true for failures another attempt may not
+// hit: timeouts, reset or refused connections and bodies cut short. The
+// caller's own cancellation is checked before.
+func IsRetryableNetworkError(err error) bool {
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	return errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// isIdempotent follows RFC 9110, repeating these has the same effect as
+// sending them once
+func isIdempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace,
+		http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// parseRetryAfter reads both forms, delay seconds and an HTTP date
+func parseRetryAfter(value string) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		return max(time.Until(at), 0), true
+	}
+	return 0, false
+}
+
+func drain(resp *http.Response) {
+	io.Copy(io.Discard, io.LimitReader(resp.Body, maxDrainBytes))
+	resp.Body.Close()
+}
+
+func wait(ctx context.Context, delay time.Duration) error {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,237 @@
+package httpclient
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newStatusServer answers with statuses in turn, the last one repeats,
+// and records every request body
+func newStatusServer(t *testing.T, headers http.Header, statuses ...int) (*httptest.Server, *int64, *[]string) {
+	t.Helper()
+
+	var requests int64
+	var bodies []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt64(&requests, 1)
+		body, _ := io.ReadAll(r.Body)
+		bodies = append(bodies, string(body))
+
+		status := statuses[min(int(n), len(statuses))-1]
+		for key, values := range headers {
+			w.Header()[key] = values
+		}
+		w.WriteHeader(status)
+		if status == http.StatusOK {
+			w.Write([]byte(`{"ok":true}`))
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server, &requests, &bodies
+}
+
+func TestClient_Retry(t *testing.T) {
+	ctx := context.Background()
+	fast := RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: 10 * time.Millisecond}
+
+	tests := []struct {
+		name             string
+		request          Request
+		statuses         []int
+		headers          http.Header
+		policy           *RetryPolicy
+		expectedRequests int64
+		expectErr        bool
+	}{
+		{
+			name:             "Retries a 502 until it succeeds",
+			request:          Request{Method: http.MethodGet, Path: "/"},
+			statuses:         []int{http.StatusBadGateway, http.StatusOK},
+			policy:           &fast,
+			expectedRequests: 2,
+		},
+		{
+			name:             "Gives up after max attempts",
+			request:          Request{Method: http.MethodGet, Path: "/"},
+			statuses:         []int{http.StatusServiceUnavailable},
+			policy:           &fast,
+			expectedRequests: 3,
+			expectErr:        true,
+		},
+		{
+			name:             "Does not retry a client error",
+			request:          Request{Method: http.MethodGet, Path: "/"},
+			statuses:         []int{http.StatusNotFound},
+			policy:           &fast,
+			expectedRequests: 1,
+			expectErr:        true,
+		},
+		{
+			name:             "Does not retry a POST by default",
+			request:          Request{Method: http.MethodPost, Path: "/", Body: []byte(`{"id":1}`)},
+			statuses:         []int{http.StatusBadGateway, http.StatusOK},
+			policy:           &fast,
+			expectedRequests: 1,
+			expectErr:        true,
+		},
+		{
+			name:             "Retries a POST marked idempotent with the same body",
+			request:          Request{Method: http.MethodPost, Path: "/", Body: []byte(`{"id":1}`), Idempotent: true},
+			statuses:         []int{http.StatusBadGateway, http.StatusOK},
+			policy:           &fast,
+			expectedRequests: 2,
+		},
+		{
+			name:             "Honors a short Retry-After",
+			request:          Request{Method: http.MethodGet, Path: "/"},
+			statuses:         []int{http.StatusTooManyRequests, http.StatusOK},
+			headers:          http.Header{"Retry-After": {"0"}},
+			policy:           &fast,
+			expectedRequests: 2,
+		},
+		{
+			name:             "Does not wait for a Retry-After beyond MaxDelay",
+			request:          Request{Method: http.MethodGet, Path: "/"},
+			statuses:         []int{http.StatusTooManyRequests, http.StatusOK},
+			headers:          http.Header{"Retry-After": {"120"}},
+			policy:           &fast,
+			expectedRequests: 1,
+			expectErr:        true,
+		},
+		{
+			name:             "Makes one attempt without a policy",
+			request:          Request{Method: http.MethodGet, Path: "/"},
+			statuses:         []int{http.StatusBadGateway, http.StatusOK},
+			expectedRequests: 1,
+			expectErr:        true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, requests, bodies := newStatusServer(t, tt.headers, tt.statuses...)
+			opts := []Option{WithBaseURL(server.URL)}
+			if tt.policy != nil {
+				opts = append(opts, WithRetry(*tt.policy))
+			}
+			client := NewClient(opts...)
+
+			var result map[string]bool
+			err := client.Do(ctx, tt.request, &result)
+
+			assert.Equal(t, tt.expectedRequests, atomic.LoadInt64(requests))
+			if tt.expectErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.True(t, result["ok"])
+			for _, body := range *bodies {
+				assert.Equal(t, string(tt.request.Body), body)
+			}
+		})
+	}
+
+	t.Run("Retries network errors", func(t *testing.T) {
+		server, requests, _ := newStatusServer(t, nil, http.StatusOK)
+		var failures int64
+		client := NewClient(WithBaseURL(server.URL), WithRetry(fast), WithHTTPClient(httpClientFunc(func(req *http.Request) (*http.Response, error) {
+			if atomic.AddInt64(&failures, 1) == 1 {
+				return nil, io.ErrUnexpectedEOF
+			}
+			return http.DefaultClient.Do(req)
+		})))
+
+		require.NoError(t, client.Get(ctx, "/", nil))
+		assert.Equal(t, int64(1), atomic.LoadInt64(requests))
+		assert.Equal(t, int64(2), atomic.LoadInt64(&failures))
+	})
+
+	t.Run("Retries the errors the policy picks", func(t *testing.T) {
+		failing := errors.New("proxy said no")
+		var attempts int64
+		client := NewClient(WithBaseURL("http://upstream.test"), WithRetry(RetryPolicy{
+			BaseDelay:      time.Millisecond,
+			RetryableError: func(err error) bool { return errors.Is(err, failing) },
+		}), WithHTTPClient(httpClientFunc(func(req *http.Request) (*http.Response, error) {
+			atomic.AddInt64(&attempts, 1)
+			return nil, failing
+		})))
+
+		assert.ErrorIs(t, client.Get(ctx, "/", nil), failing)
+		assert.Equal(t, int64(3), atomic.LoadInt64(&attempts))
+	})
+
+	t.Run("Stops waiting when the context is done", func(t *testing.T) {
+		server, requests, _ := newStatusServer(t, nil, http.StatusServiceUnavailable)
+		client := NewClient(WithBaseURL(server.URL), WithRetry(RetryPolicy{BaseDelay: time.Minute, MaxDelay: time.Minute}))
+
+		ctx, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+		defer cancel()
+
+		err := client.Get(ctx, "/", nil)
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.Equal(t, int64(1), atomic.LoadInt64(requests))
+	})
+}
+
+func TestIsRetryableNetworkError(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		expected bool
+	}{
+		{name: "Timeout", err: &url.Error{Op: "Get", URL: "/", Err: &net.OpError{Op: "dial", Err: os.ErrDeadlineExceeded}}, expected: true},
+		{name: "Connection reset", err: &url.Error{Op: "Get", URL: "/", Err: &net.OpError{Op: "read", Err: syscall.ECONNRESET}}, expected: true},
+		{name: "Connection refused", err: &url.Error{Op: "Get", URL: "/", Err: &net.OpError{Op: "dial", Err: syscall.ECONNREFUSED}}, expected: true},
+		{name: "Body cut short", err: io.ErrUnexpectedEOF, expected: true},
+		{name: "Unknown host", err: &url.Error{Op: "Get", URL: "/", Err: &net.DNSError{Err: "no such host", IsNotFound: true}}},
+		{name: "Bad certificate", err: &url.Error{Op: "Get", URL: "/", Err: &tls.CertificateVerificationError{Err: errors.New("expired")}}},
+		{name: "Anything else", err: errors.New("unsupported protocol scheme")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, IsRetryableNetworkError(tt.err))
+		})
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected time.Duration
+		ok       bool
+	}{
+		{value: "", ok: false},
+		{value: "3", expected: 3 * time.Second, ok: true},
+		{value: time.Now().Add(-time.Minute).UTC().Format(http.TimeFormat), expected: 0, ok: true},
+		{value: "soon", ok: false},
+	}
+
+	for _, tt := range tests {
+		delay, ok := parseRetryAfter(tt.value)
+		assert.Equal(t, tt.ok, ok, tt.value)
+		assert.Equal(t, tt.expected, delay, tt.value)
+	}
+}
+
+type httpClientFunc func(req *http.Request) (*http.Response, error)
+
+func (f httpClientFunc) Do(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
@@ -0,0 +1,259 @@
+package httpclient
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+type PaginateOptions struct {
+	// Limit is the page size sent as LimitParam, with the items seen so
+	// far as OffsetParam, for upstreams without Link headers. Zero follows
+	// Link headers only.
+	Limit       int
+	LimitParam  string
+	OffsetParam string
+	// MaxItems stops after this many items over all pages, zero reads
+	// until the last page
+	MaxItems int
+}
+
+const (
+	defaultLimitParam  = "limit"
+	defaultOffsetParam = "offset"
+)
+
+var (
+	// ErrForeignLink is returned for a next page link to another host,
+	// which would get this client's credentials
+	ErrForeignLink = errors.New("next page link leaves the base URL")
+	// ErrPaginationLoop is returned for a next page link to a page read
+	// already, which would be followed forever
+	ErrPaginationLoop = errors.New("next page link points back to a page read already")
+)
+
+// Stream decodes the elements of a JSON array body, or the values of an
+// NDJSON body, one at a time, so the whole list is never in memory. The
+// body is closed without reading the rest once the loop stops, ctx ends
+// or maxItems items were read, zero reads them all.
+func Stream[T any](ctx context.Context, c *Client, r Request, maxItems int) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		streamPage(ctx, c, r, maxItems, yield)
+	}
+}
+
+// Paginate streams the items of every page of a list, following the
+// Link rel="next" header of each page or, with opts.Limit set, offset
+// and limit query parameters until a page comes back short.
+func Paginate[T any](ctx context.Context, c *Client, r Request, opts PaginateOptions) iter.Seq2[T, error] {
+	if opts.LimitParam == "" {
+		opts.LimitParam = defaultLimitParam
+	}
+	if opts.OffsetParam == "" {
+		opts.OffsetParam = defaultOffsetParam
+	}
+
+	return func(yield func(T, error) bool) {
+		page := r
+		if opts.Limit > 0 {
+			page = withOffset(r, opts, 0)
+		}
+
+		var zero T
+		key, err := c.pageKey(ctx, page)
+		if err != nil {
+			yield(zero, err)
+			return
+		}
+
+		total := 0
+		visited := map[string]bool{key: true}
+		for {
+			remaining := 0
+			if opts.MaxItems > 0 {
+				remaining = opts.MaxItems - total
+			}
+
+			count, header, more := streamPage(ctx, c, page, remaining, yield)
+			total += count
+			if !more || count == 0 || (opts.MaxItems > 0 && total >= opts.MaxItems) {
+				return
+			}
+
+			if link := nextLink(header); link != "" {
+				next, err := c.nextPage(ctx, page, link)
+				if err != nil {
+					yield(zero, err)
+					return
+				}
+				key, err := c.pageKey(ctx, next)
+				if err != nil {
+					yield(zero, err)
+					return
+				}
+				if visited[key] {
+					yield(zero, fmt.Errorf("%w: %s", ErrPaginationLoop, next.Path))
+					return
+				}
+				visited[key] = true
+				page = next
+				continue
+			}
+
+			if opts.Limit == 0 || count < opts.Limit {
+				return
+			}
+			page = withOffset(r, opts, total)
+		}
+	}
+}
+
+// streamPage yields the items of one response, it returns how many it
+// yielded, the response headers and whether the caller wants more
+func streamPage[T any](ctx context.Context, c *Client, r Request, maxItems int, yield func(T, error) bool) (int, http.Header, bool) {
+	var zero T
+
+	resp, err := c.open(ctx, r)
+	if err != nil {
+		yield(zero, err)
+		return 0, nil, false
+	}
+	defer resp.Body.Close()
+
+	reader := bufio.NewReader(resp.Body)
+	first, err := firstByte(reader)
+	if errors.Is(err, io.EOF) {
+		return 0, resp.Header, true
+	}
+	if err != nil {
+		yield(zero, fmt.Errorf("reading response: %w", err))
+		return 0, resp.Header, false
+	}
+
+	decoder := json.NewDecoder(reader)
+	array := first == '['
+	if array {
+		if _, err := decoder.Token(); err != nil {
+			yield(zero, fmt.Errorf("decoding response: %w", err))
+			return 0, resp.Header, false
+		}
+	}
+
+	count := 0
+	for maxItems <= 0 || count < maxItems {
+		if err := ctx.Err(); err != nil {
+			yield(zero, err)
+			return count, resp.Header, false
+		}
+		if array && !decoder.More() {
+			return count, resp.Header, true
+		}
+
+		var item T
+		if err := decoder.Decode(&item); err != nil {
+			if !array && errors.Is(err, io.EOF) {
+				return count, resp.Header, true
+			}
+			yield(zero, fmt.Errorf("decoding item %d: %w", count, err))
+			return count, resp.Header, false
+		}
+		count += 1
+		if !yield(item, nil) {
+			return count, resp.Header, false
+		}
+	}
+	return count, resp.Header, true
+}
+
+// nextPage turns a next link, relative to the page it came with, into a
+// request below the base URL
+func (c *Client) nextPage(ctx context.Context, page Request, link string) (Request, error) {
+	req, err := c.newRequest(ctx, page)
+	if err != nil {
+		return Request{}, err
+	}
+	next, err := req.URL.Parse(link)
+	if err != nil {
+		return Request{}, fmt.Errorf("parsing next page link %q: %w", link, err)
+	}
+
+	path, ok := strings.CutPrefix(next.String(), c.baseURL)
+	if !ok || (path != "" && !strings.HasPrefix(path, "/") && !strings.HasPrefix(path, "?")) {
+		return Request{}, fmt.Errorf("%w: %s", ErrForeignLink, next.Redacted())
+	}
+
+	return Request{
+		Method:  page.Method,
+		Path:    path,
+		Headers: page.Headers,
+	}, nil
+}
+
+// pageKey tells pages apart by the URI they are requested at, the first
+// page carries its query in Query and next pages in Path, so the query is
+// sorted to compare the same either way
+func (c *Client) pageKey(ctx context.Context, r Request) (string, error) {
+	req, err := c.newRequest(ctx, r)
+	if err != nil {
+		return "", err
+	}
+	req.URL.RawQuery = req.URL.Query().Encode()
+	return req.URL.RequestURI(), nil
+}
+
+func withOffset(r Request, opts PaginateOptions, offset int) Request {
+	query := make(map[string]string, len(r.Query)+2)
+	for key, value := range r.Query {
+		query[key] = value
+	}
+	query[opts.LimitParam] = strconv.Itoa(opts.Limit)
+	query[opts.OffsetParam] = strconv.Itoa(offset)
+	r.Query = query
+	return r
+}
+
+// nextLink finds the rel="next" target in Link headers such as
+// <https://api.example.com/items?page=2>; rel="next", <...>; rel="last"
+func nextLink(header http.Header) string {
+	for _, value := range header.Values("Link") {
+		for _, link := range strings.Split(value, ",") {
+			target, params, ok := strings.Cut(strings.TrimSpace(link), ";")
+			if !ok || !strings.HasPrefix(target, "<") || !strings.HasSuffix(target, ">") {
+				continue
+			}
+			for _, param := range strings.Split(params, ";") {
+				name, rel, _ := strings.Cut(strings.TrimSpace(param), "=")
+				if !strings.EqualFold(name, "rel") {
+					continue
+				}
+				for _, r := range strings.Fields(strings.Trim(rel, `"`)) {
+					if strings.EqualFold(r, "next") {
+						return strings.Trim(target, "<>")
+					}
+				}
+			}
+		}
+	}
+	return ""
+}
+
+// firstByte returns the first byte that is not whitespace and leaves it
+// in reader
+func firstByte(reader *bufio.Reader) (byte, error) {
+	for {
+		b, err := reader.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		if b != ' ' && b != '\t' && b != '\n' && b != '\r' {
+			return b, reader.UnreadByte()
+		}
+	}
+}
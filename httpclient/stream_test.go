@@ -0,0 +1,276 @@
+package httpclient
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// collect reads seq until it ends or fails
+func collect[T any](seq func(yield func(T, error) bool)) ([]T, error) {
+	var items []T
+	for item, err := range seq {
+		if err != nil {
+			return items, err
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+func TestStream(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name          string
+		body          string
+		maxItems      int
+		expectedIDs   []int
+		expectedError string
+	}{
+		{name: "JSON array", body: ` [{"id":1}, {"id":2}, {"id":3}]`, expectedIDs: []int{1, 2, 3}},
+		{name: "NDJSON", body: "{\"id\":1}\n{\"id\":2}\n\n{\"id\":3}\n", expectedIDs: []int{1, 2, 3}},
+		{name: "Capped array", body: `[{"id":1},{"id":2},{"id":3}]`, maxItems: 2, expectedIDs: []int{1, 2}},
+		{name: "Capped NDJSON", body: "{\"id\":1}\n{\"id\":2}\n", maxItems: 1, expectedIDs: []int{1}},
+		{name: "Empty array", body: `[]`},
+		{name: "Empty body", body: ``},
+		{name: "Bad item", body: `[{"id":1},{"id":"two"}]`, expectedIDs: []int{1}, expectedError: "decoding item 1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte(tt.body))
+			}))
+			t.Cleanup(server.Close)
+			client := NewClient(WithBaseURL(server.URL))
+
+			items, err := collect(Stream[product](ctx, client, Request{Method: http.MethodGet, Path: "/"}, tt.maxItems))
+			if tt.expectedError != "" {
+				assert.ErrorContains(t, err, tt.expectedError)
+			} else {
+				require.NoError(t, err)
+			}
+
+			var ids []int
+			for _, item := range items {
+				ids = append(ids, item.ID)
+			}
+			assert.Equal(t, tt.expectedIDs, ids)
+		})
+	}
+
+	t.Run("Returns error statuses", func(t *testing.T) {
+		server, _ := newEchoServer(t, http.StatusNotFound)
+		_, err := collect(Stream[product](ctx, NewClient(WithBaseURL(server.URL)), Request{Method: http.MethodGet, Path: "/"}, 0))
+		assert.True(t, IsNotFound(err))
+	})
+
+	t.Run("Stops when the context is done", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(`[{"id":1},{"id":2},{"id":3}]`))
+		}))
+		t.Cleanup(server.Close)
+
+		cancelCtx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		var ids []int
+		var lastErr error
+		for item, err := range Stream[product](cancelCtx, NewClient(WithBaseURL(server.URL)), Request{Method: http.MethodGet, Path: "/"}, 0) {
+			if err != nil {
+				lastErr = err
+				break
+			}
+			ids = append(ids, item.ID)
+			cancel()
+		}
+		assert.Equal(t, []int{1}, ids)
+		assert.ErrorIs(t, lastErr, context.Canceled)
+	})
+}
+
+func TestPaginate(t *testing.T) {
+	ctx := context.Background()
+
+	// newPagedServer serves ids 1 to total, by offset and limit or by a
+	// page query with Link headers
+	newPagedServer := func(t *testing.T, total int, link func(page int) string) (*httptest.Server, *[]string) {
+		var queries []string
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			queries = append(queries, r.URL.RawQuery)
+			query := r.URL.Query()
+
+			from, to := 0, total
+			if link != nil {
+				page, _ := strconv.Atoi(query.Get("page"))
+				from, to = page*2, min(page*2+2, total)
+				if to < total {
+					w.Header().Set("Link", link(page+1))
+				}
+			} else if query.Has("limit") {
+				offset, _ := strconv.Atoi(query.Get("offset"))
+				limit, _ := strconv.Atoi(query.Get("limit"))
+				from, to = min(offset, total), min(offset+limit, total)
+			}
+
+			items := make([]string, 0, to-from)
+			for id := from + 1; id <= to; id++ {
+				items = append(items, fmt.Sprintf(`{"id":%d}`, id))
+			}
+			w.Write([]byte("[" + strings.Join(items, ",") + "]"))
+		}))
+		t.Cleanup(server.Close)
+		return server, &queries
+	}
+
+	tests := []struct {
+		name            string
+		link            func(page int) string
+		opts            PaginateOptions
+		expectedCount   int
+		expectedQueries []string
+	}{
+		{
+			name:            "Relative Link headers",
+			link:            func(page int) string { return fmt.Sprintf(`</items?page=%d>; rel="next"`, page) },
+			expectedCount:   5,
+			expectedQueries: []string{"", "page=1", "page=2"},
+		},
+		{
+			name: "Absolute Link headers among others",
+			link: func(page int) string {
+				return fmt.Sprintf(`<%s/items?page=9>; rel="last", <%s/items?page=%d>; rel="next"`, "BASE", "BASE", page)
+			},
+			expectedCount:   5,
+			expectedQueries: []string{"", "page=1", "page=2"},
+		},
+		{
+			name:            "Offset and limit",
+			opts:            PaginateOptions{Limit: 2},
+			expectedCount:   5,
+			expectedQueries: []string{"limit=2&offset=0", "limit=2&offset=2", "limit=2&offset=4"},
+		},
+		{
+			name:            "Offset and limit ending on a full page",
+			opts:            PaginateOptions{Limit: 5, LimitParam: "limit", OffsetParam: "offset"},
+			expectedCount:   5,
+			expectedQueries: []string{"limit=5&offset=0", "limit=5&offset=5"},
+		},
+		{
+			name:            "Capped over pages",
+			opts:            PaginateOptions{Limit: 2, MaxItems: 3},
+			expectedCount:   3,
+			expectedQueries: []string{"limit=2&offset=0", "limit=2&offset=2"},
+		},
+		{
+			name:            "Single page",
+			expectedCount:   5,
+			expectedQueries: []string{""},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var server *httptest.Server
+			link := tt.link
+			if link != nil {
+				link = func(page int) string { return strings.ReplaceAll(tt.link(page), "BASE", server.URL) }
+			}
+			server, queries := newPagedServer(t, 5, link)
+			client := NewClient(WithBaseURL(server.URL))
+
+			items, err := collect(Paginate[product](ctx, client, Request{Method: http.MethodGet, Path: "/items"}, tt.opts))
+			require.NoError(t, err)
+
+			require.Len(t, items, tt.expectedCount)
+			for i, item := range items {
+				assert.Equal(t, i+1, item.ID)
+			}
+			assert.Equal(t, tt.expectedQueries, *queries)
+		})
+	}
+
+	t.Run("Refuses links to other hosts", func(t *testing.T) {
+		server, queries := newPagedServer(t, 5, func(page int) string {
+			return `<https://elsewhere.example.com/items?page=1>; rel="next"`
+		})
+		client := NewClient(WithBaseURL(server.URL), WithBearerToken("secret"))
+
+		items, err := collect(Paginate[product](ctx, client, Request{Method: http.MethodGet, Path: "/items"}, PaginateOptions{}))
+		assert.ErrorIs(t, err, ErrForeignLink)
+		assert.Len(t, items, 2)
+		assert.Len(t, *queries, 1)
+	})
+}
+
+func TestPaginate_Loops(t *testing.T) {
+	tests := []struct {
+		name             string
+		query            map[string]string
+		links            map[string]string
+		expectedRequests int
+	}{
+		{
+			name:             "Link to itself",
+			links:            map[string]string{"": `</items>; rel="next"`},
+			expectedRequests: 1,
+		},
+		{
+			name:             "Link back to an earlier page",
+			links:            map[string]string{"": `</items?page=1>; rel="next"`, "1": `</items>; rel="next"`},
+			expectedRequests: 2,
+		},
+		{
+			name:             "Link back to a first page given as Query",
+			query:            map[string]string{"page": "1", "sort": "id"},
+			links:            map[string]string{"1": `</items?sort=id&page=2>; rel="next"`, "2": `</items?page=1&sort=id>; rel="next"`},
+			expectedRequests: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			requests := 0
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requests += 1
+				w.Header().Set("Link", tt.links[r.URL.Query().Get("page")])
+				w.Write([]byte(`[{"id":1}]`))
+			}))
+			t.Cleanup(server.Close)
+			client := NewClient(WithBaseURL(server.URL))
+
+			items, err := collect(Paginate[product](context.Background(), client, Request{Method: http.MethodGet, Path: "/items", Query: tt.query}, PaginateOptions{}))
+			assert.ErrorIs(t, err, ErrPaginationLoop)
+			assert.Equal(t, tt.expectedRequests, requests)
+			assert.Len(t, items, tt.expectedRequests)
+		})
+	}
+}
+
+func TestNextLink(t *testing.T) {
+	tests := []struct {
+		name     string
+		header   []string
+		expected string
+	}{
+		{name: "No header"},
+		{name: "Next only", header: []string{`</items?page=2>; rel="next"`}, expected: "/items?page=2"},
+		{name: "Unquoted among others", header: []string{`</items?page=1>; rel=prev, </items?page=3>; rel=next`}, expected: "/items?page=3"},
+		{name: "Several relations", header: []string{`</items?page=9>; rel="last"`, `</items?page=2>; title="two"; rel="next last"`}, expected: "/items?page=2"},
+		{name: "No next", header: []string{`</items?page=9>; rel="last"`}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := http.Header{"Link": tt.header}
+			assert.Equal(t, tt.expected, nextLink(header))
+		})
+	}
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	"time"
@@ -9,11 +10,22 @@ import (
 	dotEnv "github.com/joho/godotenv"
 
 	cache "github.com/brianwu291/go-learn/cache"
+	circuitbreaker "github.com/brianwu291/go-learn/circuitbreaker"
 	postgres "github.com/brianwu291/go-learn/db/postgres"
 	redis "github.com/brianwu291/go-learn/db/redis"
+	featureflag "github.com/brianwu291/go-learn/featureflag"
+	httpclient "github.com/brianwu291/go-learn/httpclient"
 	utils "github.com/brianwu291/go-learn/utils"
 
+	adminauth "github.com/brianwu291/go-learn/middlewares/adminauth"
+	flagguard "github.com/brianwu291/go-learn/middlewares/flagguard"
 	ratelimiter "github.com/brianwu291/go-learn/middlewares/ratelimiter"
+	requestid "github.com/brianwu291/go-learn/middlewares/requestid"
+
+	cacheadminhandler "github.com/brianwu291/go-learn/handlers/cacheadmin"
+	cacheadminservice "github.com/brianwu291/go-learn/services/cacheadmin"
+
+	featureflaghandler "github.com/brianwu291/go-learn/handlers/featureflag"
 
 	financialhandler "github.com/brianwu291/go-learn/handlers/financial"
 	financialservice "github.com/brianwu291/go-learn/services/financial"
@@ -37,15 +49,21 @@ var (
 		Limit:                   1000,
 		Duration:                15 * time.Minute,
 		ClientIdentifierOptions: []ratelimiter.ClientIdentifierOption{ratelimiter.ClientIP},
+		FailOpen:                true,
 	}
 
 	PublicAPIConfig = ratelimiter.Config{
 		Limit:                   5000,
 		Duration:                time.Hour,
 		ClientIdentifierOptions: []ratelimiter.ClientIdentifierOption{ratelimiter.ClientIP},
+		FailOpen:                true,
 	}
 )
 
+// AllProductsRateLimitFlag moves /fake-store/all/categories/products from
+// the public to the normal rate limit policy for the clients it is on for
+const AllProductsRateLimitFlag = "all-products-normal-rate-limit"
+
 func main() {
 	err := dotEnv.Load()
 	if err != nil {
@@ -63,24 +81,52 @@ func main() {
 	}
 	defer postgresDB.Close()
 
-	redisDB := utils.GetEnvAsInt("REDIS_DB", 0)	
-	cacheConfig := &cache.Config{
-		Host:     utils.GetEnv("REDIS_HOST", "localhost"),
-		Port:     utils.GetEnv("REDIS_PORT", "6379"),
-		Password: utils.GetEnv("REDIS_PASSWORD", ""),
-		DB:       redisDB,
-	}
+	cacheConfig := cache.NewConfigFromEnv()
 	cacheClient, err := redis.NewClient(cacheConfig)
 	if err != nil {
 		fmt.Printf("failed to initialize Redis cache: %v\n", err)
 		return
 	}
+	defer cacheClient.Close()
+
+	cacheKeys := cache.NewKeyBuilder(constants.CacheNamespace, utils.GetEnv("APP_ENV", "development"))
+	cacheMetrics := cache.NewMetrics()
+	resilientCache := cache.NewResilientClient(cacheClient, cache.ResilienceConfig{
+		Timeout: utils.GetEnvAsDuration("REDIS_OP_TIMEOUT", 500*time.Millisecond),
+		OperationTimeouts: map[string]time.Duration{
+			// admin scans walk the whole keyspace
+			"scan": utils.GetEnvAsDuration("REDIS_SCAN_TIMEOUT", 5*time.Second),
+		},
+		Breaker: circuitbreaker.Config{
+			ConsecutiveFailures: utils.GetEnvAsInt("REDIS_BREAKER_FAILURES", 5),
+			OpenTimeout:         utils.GetEnvAsDuration("REDIS_BREAKER_OPEN_TIMEOUT", 30*time.Second),
+		},
+	})
+	instrumentedCache := cache.NewInstrumentedClient(resilientCache, cacheKeys, cacheMetrics)
+	compressedCache := cache.NewCompressedClient(instrumentedCache, cache.CompressionConfig{
+		Threshold: utils.GetEnvAsInt("REDIS_COMPRESSION_THRESHOLD", 1024),
+	})
+
+	// flags are read from memory, the raw client carries the change
+	// notifications between instances
+	featureFlags := featureflag.NewStore(instrumentedCache, cacheClient, cacheKeys, featureflag.Config{
+		RefreshInterval: utils.GetEnvAsDuration("FEATURE_FLAG_REFRESH_INTERVAL", 30*time.Second),
+	})
+	if err := featureFlags.Start(context.Background()); err != nil {
+		fmt.Printf("feature flags start off, failed to load them: %+v\n", err)
+	}
+	featureFlagHandler := featureflaghandler.NewFeatureFlagHandler(featureFlags)
+	flagGuard := flagguard.NewFlagGuard(featureFlags, nil)
 
 	// Initialize rate limiter
-	rateLimiter := ratelimiter.NewRateLimiter(cacheClient)
+	rateLimiter := ratelimiter.NewRateLimiter(instrumentedCache, cacheKeys)
 
 	r := gin.Default()
+	// handlers pass the gin context on, let it reach the request context
+	// values such as the request ID
+	r.ContextWithFallback = true
 
+	r.Use(requestid.New(""))
 	r.Use(postgresDB.Middleware())
 	r.GET("/health", func(c *gin.Context) {
 		if healthyErr := postgresDB.Health(); healthyErr != nil {
@@ -91,14 +137,73 @@ func main() {
 		}
 		c.JSON(http.StatusOK, gin.H{"status": "ok"})
 	})
+	r.GET("/metrics/cache", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{
+			"breaker":     resilientCache.State().String(),
+			"compression": compressedCache.Stats(),
+			"stats":       cacheMetrics.Snapshot(),
+		})
+	})
 
 	financialService := financialservice.NewFinancialService()
 	financialHandler := financialhandler.NewFinancialHandler(financialService)
 
-	fakeStoreRepo := fakestorerepo.NewFakeStoreRepo()
-	fakeStoreService := fakestoreservice.NewFakeStoreService(cacheClient, fakeStoreRepo)
+	fakeStoreOptions := []httpclient.Option{
+		httpclient.WithRetry(httpclient.RetryPolicy{
+			MaxAttempts: utils.GetEnvAsInt("FAKESTORE_MAX_ATTEMPTS", 3),
+		}),
+		httpclient.WithCircuitBreaker(circuitbreaker.Config{
+			ConsecutiveFailures: utils.GetEnvAsInt("FAKESTORE_BREAKER_FAILURES", 5),
+			FailureRatio:        0.5,
+			MinRequests:         10,
+			Window:              time.Minute,
+			OpenTimeout:         utils.GetEnvAsDuration("FAKESTORE_BREAKER_OPEN_TIMEOUT", 30*time.Second),
+		}),
+		httpclient.WithInterceptors(httpclient.RequestID(""), httpclient.Logging()),
+		httpclient.WithResponseCache(compressedCache, cacheKeys, httpclient.ResponseCacheConfig{
+			DefaultMaxAge: utils.GetEnvAsDuration("FAKESTORE_RESPONSE_MAX_AGE", 0),
+		}),
+	}
+	// one outbound budget for all instances, so bursts of our traffic do
+	// not become bursts against the upstream, a rate of zero turns it off
+	if rate := utils.GetEnvAsInt("FAKESTORE_RATE_LIMIT", 10); rate > 0 {
+		fakeStoreOptions = append(fakeStoreOptions, httpclient.WithDistributedRateLimit(instrumentedCache, cacheKeys, httpclient.RateLimit{
+			Rate:    float64(rate),
+			Burst:   utils.GetEnvAsInt("FAKESTORE_RATE_BURST", 20),
+			MaxWait: utils.GetEnvAsDuration("FAKESTORE_RATE_MAX_WAIT", 2*time.Second),
+		}))
+	}
+	// the response cache is shared by every caller, so it is skipped once
+	// the client sends credentials and every read goes to the upstream
+	if username := utils.GetEnv("FAKESTORE_USERNAME", ""); username != "" {
+		fmt.Printf("fake store login enabled for %s, the shared response cache is off\n", username)
+		login := httpclient.Login(httpclient.NewClient(httpclient.WithBaseURL(constants.FakeStoreBaseURL)), constants.FakeStoreLoginPath, map[string]string{
+			"username": username,
+			"password": utils.GetEnv("FAKESTORE_PASSWORD", ""),
+		}, 0)
+		fakeStoreOptions = append(fakeStoreOptions, httpclient.WithTokenSource(httpclient.NewTokenSource(login)))
+	}
+	fakeStoreRepo := fakestorerepo.NewFakeStoreRepo(fakeStoreOptions...)
+	r.GET("/metrics/upstreams", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{
+			"fakeStore": fakeStoreRepo.CircuitStates(),
+		})
+	})
+	fakeStoreService := fakestoreservice.NewFakeStoreService(compressedCache, fakeStoreRepo, cacheKeys)
 	fakeStoreHandler := fakestorehandler.NewFakeStoreHandler(fakeStoreService)
 
+	// fill the cache before serving so the first requests after a deploy
+	// or a flush do not all go to the fake store API
+	cacheWarmer := cache.NewWarmer()
+	fakeStoreService.RegisterCacheLoaders(cacheWarmer)
+	warmupCtx, cancelWarmup := context.WithTimeout(context.Background(),
+		utils.GetEnvAsDuration("CACHE_WARMUP_TIMEOUT", 10*time.Second))
+	cacheWarmer.Run(warmupCtx)
+	cancelWarmup()
+
+	cacheAdminService := cacheadminservice.NewCacheAdminService(instrumentedCache, cacheKeys, cacheWarmer)
+	cacheAdminHandler := cacheadminhandler.NewCacheAdminHandler(cacheAdminService)
+
 	r.POST("/calculate",
 		rateLimiter.LimitRoute(StrictAPIConfig),
 		financialHandler.Calculate)
@@ -107,9 +212,30 @@ func main() {
 		rateLimiter.LimitRoute(NormalAPIConfig),
 		fakeStoreHandler.GetAllCategories)
 
+	// every call fans out to one upstream request per category, the
+	// tighter policy is rolled out behind a flag
 	r.GET("/fake-store/all/categories/products",
-		rateLimiter.LimitRoute(PublicAPIConfig),
+		flagGuard.Switch(AllProductsRateLimitFlag,
+			rateLimiter.LimitRoute(NormalAPIConfig),
+			rateLimiter.LimitRoute(PublicAPIConfig)),
 		fakeStoreHandler.GetAllCategoriesProducts)
 
+	r.GET("/fake-store/products/:id",
+		rateLimiter.LimitRoute(PublicAPIConfig),
+		fakeStoreHandler.GetProduct)
+
+	admin := r.Group("/admin",
+		adminauth.RequireToken(utils.GetEnv("ADMIN_TOKEN", "")),
+		rateLimiter.LimitRoute(StrictAPIConfig))
+	admin.GET("/cache/families", cacheAdminHandler.ListFamilies)
+	admin.DELETE("/cache/families/:name", cacheAdminHandler.PurgeFamily)
+	admin.GET("/cache/entries", cacheAdminHandler.GetEntry)
+	admin.DELETE("/cache/entries", cacheAdminHandler.PurgeEntry)
+	admin.POST("/cache/warmup", cacheAdminHandler.WarmUp)
+	admin.GET("/flags", featureFlagHandler.ListFlags)
+	admin.GET("/flags/:name", featureFlagHandler.GetFlag)
+	admin.PUT("/flags/:name", featureFlagHandler.PutFlag)
+	admin.DELETE("/flags/:name", featureFlagHandler.DeleteFlag)
+
 	r.Run()
 }
@@ -0,0 +1,34 @@
+package adminauth
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	types "github.com/brianwu291/go-learn/types"
+)
+
+const bearerPrefix = "Bearer "
+
+// RequireToken lets a request through only with "Authorization: Bearer
+// <token>". An empty token disables the routes, they answer 404 as if
+// they did not exist.
+func RequireToken(token string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if token == "" {
+			c.AbortWithStatus(http.StatusNotFound)
+			return
+		}
+
+		header := c.GetHeader("Authorization")
+		given, ok := strings.CutPrefix(header, bearerPrefix)
+		if !ok || subtle.ConstantTimeCompare([]byte(given), []byte(token)) != 1 {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, types.UnauthorizedResponse{Message: "invalid admin token"})
+			return
+		}
+
+		c.Next()
+	}
+}
@@ -0,0 +1,45 @@
+package adminauth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequireToken(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name           string
+		token          string
+		authorization  string
+		expectedStatus int
+	}{
+		{name: "Lets the right token through", token: "secret", authorization: "Bearer secret", expectedStatus: http.StatusOK},
+		{name: "Rejects a wrong token", token: "secret", authorization: "Bearer guess", expectedStatus: http.StatusUnauthorized},
+		{name: "Rejects a missing header", token: "secret", expectedStatus: http.StatusUnauthorized},
+		{name: "Rejects another scheme", token: "secret", authorization: "Basic secret", expectedStatus: http.StatusUnauthorized},
+		{name: "Hides the routes without a token", authorization: "Bearer ", expectedStatus: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := gin.New()
+			router.GET("/admin", RequireToken(tt.token), func(c *gin.Context) {
+				c.Status(http.StatusOK)
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/admin", nil)
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+		})
+	}
+}
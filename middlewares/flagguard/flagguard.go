@@ -0,0 +1,73 @@
+package flagguard
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	types "github.com/brianwu291/go-learn/types"
+)
+
+type (
+	// Flags is implemented by featureflag.Store
+	Flags interface {
+		Enabled(name, clientID string) bool
+	}
+
+	// ClientIDFunc picks the ID percentage rollouts and allowlists match
+	ClientIDFunc func(c *gin.Context) string
+
+	FlagGuard struct {
+		flags    Flags
+		clientID ClientIDFunc
+	}
+)
+
+// NewFlagGuard keys rollouts by clientID, by client IP when nil
+func NewFlagGuard(flags Flags, clientID ClientIDFunc) *FlagGuard {
+	if clientID == nil {
+		clientID = ByClientIP
+	}
+	return &FlagGuard{
+		flags:    flags,
+		clientID: clientID,
+	}
+}
+
+func ByClientIP(c *gin.Context) string {
+	return c.ClientIP()
+}
+
+// ByHeader uses a header such as X-Client-ID, the client IP when missing
+func ByHeader(name string) ClientIDFunc {
+	return func(c *gin.Context) string {
+		if id := c.GetHeader(name); id != "" {
+			return id
+		}
+		return c.ClientIP()
+	}
+}
+
+// Require answers 404 while the flag is off for the client, so a route
+// being rolled out looks like it does not exist yet
+func (g *FlagGuard) Require(flag string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !g.flags.Enabled(flag, g.clientID(c)) {
+			c.AbortWithStatusJSON(http.StatusNotFound, types.NotFoundResponse{Message: "not found"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// Switch runs on while the flag is on for the client and off otherwise,
+// e.g. to roll out a new rate limit policy
+func (g *FlagGuard) Switch(flag string, on, off gin.HandlerFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if g.flags.Enabled(flag, g.clientID(c)) {
+			on(c)
+			return
+		}
+		off(c)
+	}
+}
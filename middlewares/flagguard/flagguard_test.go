@@ -0,0 +1,67 @@
+package flagguard
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeFlags enables each flag for the listed client IDs
+type fakeFlags map[string][]string
+
+func (f fakeFlags) Enabled(name, clientID string) bool {
+	for _, id := range f[name] {
+		if id == clientID {
+			return true
+		}
+	}
+	return false
+}
+
+func TestFlagGuard(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	flags := fakeFlags{"beta": {"client-1"}}
+	guard := NewFlagGuard(flags, ByHeader("X-Client-ID"))
+
+	router := gin.New()
+	router.GET("/beta", guard.Require("beta"), func(c *gin.Context) {
+		c.String(http.StatusOK, "beta")
+	})
+	router.GET("/policy", guard.Switch("beta",
+		func(c *gin.Context) { c.Header("X-Policy", "new") },
+		func(c *gin.Context) { c.Header("X-Policy", "old") },
+	), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	tests := []struct {
+		name           string
+		path           string
+		clientID       string
+		expectedStatus int
+		expectedPolicy string
+	}{
+		{name: "Require lets a flagged client in", path: "/beta", clientID: "client-1", expectedStatus: http.StatusOK},
+		{name: "Require hides the route from others", path: "/beta", clientID: "client-2", expectedStatus: http.StatusNotFound},
+		{name: "Require falls back to the client IP", path: "/beta", expectedStatus: http.StatusNotFound},
+		{name: "Switch picks on for a flagged client", path: "/policy", clientID: "client-1", expectedStatus: http.StatusOK, expectedPolicy: "new"},
+		{name: "Switch picks off for others", path: "/policy", clientID: "client-2", expectedStatus: http.StatusOK, expectedPolicy: "old"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			if tt.clientID != "" {
+				req.Header.Set("X-Client-ID", tt.clientID)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			assert.Equal(t, tt.expectedPolicy, w.Header().Get("X-Policy"))
+		})
+	}
+}
@@ -14,6 +14,7 @@ import (
 type (
 	RateLimiter struct {
 		cacheClient cache.Client
+		keys        cache.KeyFamily
 	}
 
 	ClientIdentifierOption string
@@ -21,6 +22,9 @@ type (
 		Limit                   int64
 		Duration                time.Duration
 		ClientIdentifierOptions []ClientIdentifierOption
+		// FailOpen lets requests through when the cache is unreachable
+		// instead of failing them with a 500
+		FailOpen bool
 	}
 )
 
@@ -60,11 +64,18 @@ const (
 
 var (
 	ErrInvalidConfig = errors.New("invalid rate limit configuration")
+
+	rateLimitCacheFamily = cache.KeyFamily{
+		Name:        "ratelimit",
+		Version:     1,
+		Description: "request counters per route and client, expire with the window",
+	}
 )
 
-func NewRateLimiter(cacheClient cache.Client) *RateLimiter {
+func NewRateLimiter(cacheClient cache.Client, cacheKeys *cache.KeyBuilder) *RateLimiter {
 	return &RateLimiter{
 		cacheClient: cacheClient,
+		keys:        cacheKeys.Register(rateLimitCacheFamily),
 	}
 }
 
@@ -83,7 +94,7 @@ func getSafePath(c *gin.Context) string {
 }
 
 func (rl *RateLimiter) formatKey(path string, method string, clientIdentifiers []string) string {
-	return fmt.Sprintf("ratelimit:%s:%s:%s", path, method, strings.Join(clientIdentifiers, ":"))
+	return rl.keys.Key(path, append([]string{method}, clientIdentifiers...)...)
 }
 
 func (rl *RateLimiter) getClientIdentifiers(c *gin.Context, identifierOptions []ClientIdentifierOption) []string {
@@ -132,6 +143,11 @@ func (rl *RateLimiter) LimitRoute(config Config) gin.HandlerFunc {
 			[]string{key},
 			[]interface{}{config.Limit, int(config.Duration.Seconds())},
 		)
+		if err != nil && config.FailOpen && cache.IsConnectionError(err) {
+			fmt.Printf("rate limiter skipped for %s, cache unavailable: %v\n", key, err)
+			c.Next()
+			return
+		}
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{
 				"error": "Rate limiting error",
@@ -0,0 +1,111 @@
+package rateLimiter
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/brianwu291/go-learn/cache"
+	"github.com/brianwu291/go-learn/cache/cachetest"
+	"github.com/brianwu291/go-learn/db/redis"
+)
+
+func newTestRouter(t *testing.T, faults cachetest.Faults, config Config) *gin.Engine {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	server := miniredis.RunT(t)
+	redisClient, err := redis.NewClient(&cache.Config{Host: server.Host(), Port: server.Port()})
+	require.NoError(t, err)
+	t.Cleanup(func() { redisClient.Close() })
+
+	limiter := NewRateLimiter(
+		cachetest.NewFaultyClient(redisClient, faults),
+		cache.NewKeyBuilder("go-learn", "test"),
+	)
+
+	r := gin.New()
+	r.GET("/limited", limiter.LimitRoute(config), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+	return r
+}
+
+func TestRateLimiter_LimitRoute(t *testing.T) {
+	config := Config{
+		Limit:                   2,
+		Duration:                time.Minute,
+		ClientIdentifierOptions: []ClientIdentifierOption{ClientIP},
+	}
+	failOpen := config
+	failOpen.FailOpen = true
+
+	unavailable := cachetest.Faults{
+		Operations: map[string]error{"eval": cache.NewConnectionError(cachetest.ErrInjected)},
+	}
+	slow := cachetest.Faults{
+		Operations: map[string]error{"eval": cache.NewTimeoutError(nil)},
+	}
+	brokenScript := cachetest.Faults{
+		Operations: map[string]error{"eval": cache.NewScriptError(nil)},
+	}
+
+	tests := []struct {
+		name           string
+		faults         cachetest.Faults
+		config         Config
+		expectedStatus []int
+	}{
+		{
+			name:           "Limits a healthy cache",
+			config:         config,
+			expectedStatus: []int{http.StatusOK, http.StatusOK, http.StatusTooManyRequests},
+		},
+		{
+			name:           "Fails requests when the cache is unavailable",
+			faults:         unavailable,
+			config:         config,
+			expectedStatus: []int{http.StatusInternalServerError},
+		},
+		{
+			name:           "Lets requests through when failing open",
+			faults:         unavailable,
+			config:         failOpen,
+			expectedStatus: []int{http.StatusOK, http.StatusOK, http.StatusOK},
+		},
+		{
+			name:           "Treats timeouts as unavailable",
+			faults:         slow,
+			config:         failOpen,
+			expectedStatus: []int{http.StatusOK},
+		},
+		{
+			name:           "Fails open only for connection errors",
+			faults:         brokenScript,
+			config:         failOpen,
+			expectedStatus: []int{http.StatusInternalServerError},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newTestRouter(t, tt.faults, tt.config)
+
+			for i, expected := range tt.expectedStatus {
+				w := httptest.NewRecorder()
+				r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/limited", nil))
+
+				assert.Equal(t, expected, w.Code, "request %d", i+1)
+				if expected == http.StatusInternalServerError {
+					assert.Contains(t, w.Body.String(), "Rate limiting error")
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,43 @@
+package requestid
+
+import (
+	"github.com/gin-gonic/gin"
+
+	"github.com/brianwu291/go-learn/httpclient"
+)
+
+// maxIDLength bounds the IDs taken from clients, longer ones are replaced
+const maxIDLength = 128
+
+// New takes the request ID from header, DefaultRequestIDHeader when
+// empty, or makes one, echoes it on the response and puts it in the
+// request context, so outbound calls made while serving send the same ID
+func New(header string) gin.HandlerFunc {
+	if header == "" {
+		header = httpclient.DefaultRequestIDHeader
+	}
+
+	return func(c *gin.Context) {
+		id := c.GetHeader(header)
+		if !valid(id) {
+			id = httpclient.NewRequestID()
+		}
+
+		c.Header(header, id)
+		c.Request = c.Request.WithContext(httpclient.ContextWithRequestID(c.Request.Context(), id))
+		c.Next()
+	}
+}
+
+// valid allows printable ASCII only, the ID ends up in logs and headers
+func valid(id string) bool {
+	if id == "" || len(id) > maxIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] <= ' ' || id[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
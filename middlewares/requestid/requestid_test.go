@@ -0,0 +1,55 @@
+package requestid
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/brianwu291/go-learn/httpclient"
+)
+
+func TestNew(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name       string
+		inbound    string
+		expectedID string
+	}{
+		{name: "Keeps the inbound ID", inbound: "abc-123", expectedID: "abc-123"},
+		{name: "Makes one without a header"},
+		{name: "Replaces an ID with spaces", inbound: "abc 123"},
+		{name: "Replaces an overlong ID", inbound: strings.Repeat("a", maxIDLength+1)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := gin.New()
+			router.ContextWithFallback = true
+			var seen string
+			router.GET("/", New(""), func(c *gin.Context) {
+				seen, _ = httpclient.RequestIDFromContext(c)
+				c.Status(http.StatusOK)
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.inbound != "" {
+				req.Header.Set(httpclient.DefaultRequestIDHeader, tt.inbound)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			id := w.Header().Get(httpclient.DefaultRequestIDHeader)
+			if tt.expectedID != "" {
+				assert.Equal(t, tt.expectedID, id)
+			} else {
+				assert.Len(t, id, 32)
+			}
+			assert.Equal(t, id, seen, "handlers passing the gin context see the ID")
+		})
+	}
+}
@@ -0,0 +1,330 @@
+// Package queue runs background jobs on a Redis stream shared by every
+// worker in a consumer group. Delivery is at least once, handlers must be
+// safe to run again for the same job.
+package queue
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	mathrand "math/rand/v2"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/brianwu291/go-learn/cache"
+)
+
+type (
+	// Client is what the queue needs from the cache, db/redis.Client
+	// implements it
+	Client interface {
+		cache.Client
+		cache.Streams
+	}
+
+	Config struct {
+		// Name picks the stream, all keys share the {Name} hash slot
+		Name string
+		// Group is the consumer group, workers of one group split the jobs
+		Group string
+		// Consumer names this worker within the group, defaults to host and pid
+		Consumer string
+		// Concurrency is the number of jobs handled at once
+		Concurrency int
+		// MaxAttempts moves a job to the dead-letter stream after this many
+		// failed runs or deliveries
+		MaxAttempts int
+		// Backoff is the delay before retry attempt n, n starting at 1
+		Backoff func(attempt int) time.Duration
+		// VisibilityTimeout bounds a handler run. Jobs unacked for longer,
+		// e.g. because the worker died, are claimed by another worker.
+		VisibilityTimeout time.Duration
+		// ClaimInterval is how often stalled jobs are looked for
+		ClaimInterval time.Duration
+		// PollInterval is how often due retries are moved back to the stream
+		PollInterval time.Duration
+		// BlockTimeout bounds each wait for new jobs, and so how quickly Run
+		// notices shutdown
+		BlockTimeout time.Duration
+		// ShutdownTimeout is how long Run waits for running jobs on shutdown
+		// before cancelling their context
+		ShutdownTimeout time.Duration
+	}
+
+	Queue struct {
+		client  Client
+		config  Config
+		stream  string
+		delayed string
+		dead    string
+
+		mu       sync.RWMutex
+		handlers map[string]handlerFunc
+	}
+
+	// Job describes the job being handled, see JobFromContext
+	Job struct {
+		// ID stays the same across retries
+		ID   string
+		Type string
+		// Attempt is 1 on the first run
+		Attempt    int
+		EnqueuedAt time.Time
+	}
+
+	// JobType enqueues jobs with a typed payload
+	JobType[T any] struct {
+		queue *Queue
+		name  string
+	}
+
+	Stats struct {
+		// Queued counts jobs waiting or running
+		Queued  int64 `json:"queued"`
+		Delayed int64 `json:"delayed"`
+		Dead    int64 `json:"dead"`
+	}
+
+	handlerFunc func(ctx context.Context, payload json.RawMessage) error
+
+	// envelope is the stream entry, stored as JSON in the "job" field
+	envelope struct {
+		ID         string          `json:"id"`
+		Type       string          `json:"type"`
+		Payload    json.RawMessage `json:"payload"`
+		Attempt    int             `json:"attempt"`
+		EnqueuedAt time.Time       `json:"enqueuedAt"`
+		LastError  string          `json:"lastError,omitempty"`
+	}
+
+	permanentError struct {
+		err error
+	}
+
+	jobContextKey struct{}
+)
+
+const (
+	keyPrefix = "queue:"
+	jobField  = "job"
+
+	defaultGroup             = "workers"
+	defaultConcurrency       = 4
+	defaultMaxAttempts       = 5
+	defaultVisibilityTimeout = 5 * time.Minute
+	defaultClaimInterval     = 30 * time.Second
+	defaultPollInterval      = time.Second
+	defaultBlockTimeout      = time.Second
+	defaultShutdownTimeout   = 30 * time.Second
+
+	// bounds one round of claiming or promoting
+	batchSize = 100
+
+	// KEYS[1] delayed set, KEYS[2] stream, ARGV[1] now ms, ARGV[2] limit.
+	// Moves due retries back to the stream, returns how many moved.
+	promoteScript = `
+    local due = redis.call('ZRANGEBYSCORE', KEYS[1], '-inf', ARGV[1], 'LIMIT', 0, ARGV[2])
+    for _, job in ipairs(due) do
+        redis.call('ZREM', KEYS[1], job)
+        redis.call('XADD', KEYS[2], '*', 'job', job)
+    end
+    return #due
+  `
+)
+
+var (
+	// ErrInvalidConfig is returned by NewQueue for a config it cannot run
+	ErrInvalidConfig = errors.New("invalid queue configuration")
+	// ErrUnknownJobType is recorded for jobs no handler is registered for
+	ErrUnknownJobType = errors.New("unknown job type")
+	// ErrShutdownTimeout is returned by Run when running jobs had to be
+	// cancelled
+	ErrShutdownTimeout = errors.New("queue shutdown timed out")
+)
+
+func NewQueue(client Client, config Config) (*Queue, error) {
+	if config.Name == "" {
+		return nil, fmt.Errorf("%w: name is required", ErrInvalidConfig)
+	}
+	if config.Group == "" {
+		config.Group = defaultGroup
+	}
+	if config.Consumer == "" {
+		host, _ := os.Hostname()
+		config.Consumer = host + "-" + strconv.Itoa(os.Getpid())
+	}
+	if config.Concurrency <= 0 {
+		config.Concurrency = defaultConcurrency
+	}
+	if config.MaxAttempts <= 0 {
+		config.MaxAttempts = defaultMaxAttempts
+	}
+	if config.Backoff == nil {
+		config.Backoff = ExponentialBackoff(time.Second, 5*time.Minute)
+	}
+	if config.VisibilityTimeout <= 0 {
+		config.VisibilityTimeout = defaultVisibilityTimeout
+	}
+	if config.ClaimInterval <= 0 {
+		config.ClaimInterval = defaultClaimInterval
+	}
+	if config.PollInterval <= 0 {
+		config.PollInterval = defaultPollInterval
+	}
+	if config.BlockTimeout <= 0 {
+		config.BlockTimeout = defaultBlockTimeout
+	}
+	if config.ShutdownTimeout <= 0 {
+		config.ShutdownTimeout = defaultShutdownTimeout
+	}
+
+	stream := keyPrefix + "{" + config.Name + "}"
+	return &Queue{
+		client:   client,
+		config:   config,
+		stream:   stream,
+		delayed:  stream + ":delayed",
+		dead:     stream + ":dead",
+		handlers: make(map[string]handlerFunc),
+	}, nil
+}
+
+// Register adds the handler for a job type and returns the typed way to
+// enqueue it. Registering a name twice panics.
+func Register[T any](q *Queue, name string, handle func(ctx context.Context, payload T) error) *JobType[T] {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if _, ok := q.handlers[name]; ok {
+		panic(fmt.Errorf("%w: job type %s registered twice", ErrInvalidConfig, name))
+	}
+	q.handlers[name] = func(ctx context.Context, raw json.RawMessage) error {
+		var payload T
+		if err := json.Unmarshal(raw, &payload); err != nil {
+			return Permanent(fmt.Errorf("decoding %s payload: %w", name, err))
+		}
+		return handle(ctx, payload)
+	}
+
+	return &JobType[T]{queue: q, name: name}
+}
+
+// Enqueue adds a job and returns its ID
+func (t *JobType[T]) Enqueue(ctx context.Context, payload T) (string, error) {
+	return t.queue.enqueue(ctx, t.name, payload)
+}
+
+// Permanent marks a handler error as not worth retrying, the job goes
+// straight to the dead-letter stream
+func Permanent(err error) error {
+	return &permanentError{err: err}
+}
+
+func (e *permanentError) Error() string {
+	return e.err.Error()
+}
+
+func (e *permanentError) Unwrap() error {
+	return e.err
+}
+
+// JobFromContext returns the job a handler is running for
+func JobFromContext(ctx context.Context) (Job, bool) {
+	job, ok := ctx.Value(jobContextKey{}).(Job)
+	return job, ok
+}
+
+// ExponentialBackoff doubles the delay per attempt up to max, with up to
+// half of it randomized so retries of a burst spread out
+func ExponentialBackoff(base, max time.Duration) func(attempt int) time.Duration {
+	return func(attempt int) time.Duration {
+		delay := base
+		for i := 1; i < attempt && delay < max; i++ {
+			delay *= 2
+		}
+		if delay > max {
+			delay = max
+		}
+		return delay/2 + randomDuration(delay/2)
+	}
+}
+
+// Stats counts jobs per state
+func (q *Queue) Stats(ctx context.Context) (Stats, error) {
+	var stats Stats
+	var err error
+	if stats.Queued, err = q.client.XLen(ctx, q.stream); err != nil {
+		return Stats{}, err
+	}
+	if stats.Delayed, err = q.client.ZCard(ctx, q.delayed); err != nil {
+		return Stats{}, err
+	}
+	if stats.Dead, err = q.client.XLen(ctx, q.dead); err != nil {
+		return Stats{}, err
+	}
+	return stats, nil
+}
+
+func (q *Queue) enqueue(ctx context.Context, jobType string, payload interface{}) (string, error) {
+	raw, err := json.Marshal(payload)
+	if err != nil {
+		return "", fmt.Errorf("%w: encoding %s payload: %v", cache.ErrInvalidValue, jobType, err)
+	}
+	id, err := newJobID()
+	if err != nil {
+		return "", err
+	}
+
+	env := envelope{
+		ID:         id,
+		Type:       jobType,
+		Payload:    raw,
+		EnqueuedAt: time.Now().UTC(),
+	}
+	if err := q.add(ctx, q.stream, env, nil); err != nil {
+		return "", fmt.Errorf("enqueue %s: %w", jobType, err)
+	}
+	return id, nil
+}
+
+func (q *Queue) add(ctx context.Context, stream string, env envelope, extra map[string]interface{}) error {
+	data, err := json.Marshal(env)
+	if err != nil {
+		return fmt.Errorf("%w: encoding job %s: %v", cache.ErrInvalidValue, env.ID, err)
+	}
+
+	values := map[string]interface{}{jobField: string(data)}
+	for field, value := range extra {
+		values[field] = value
+	}
+	_, err = q.client.XAdd(ctx, stream, values)
+	return err
+}
+
+func (q *Queue) handler(jobType string) (handlerFunc, bool) {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+
+	handler, ok := q.handlers[jobType]
+	return handler, ok
+}
+
+func newJobID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("generating job id: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func randomDuration(max time.Duration) time.Duration {
+	if max <= 0 {
+		return 0
+	}
+	return time.Duration(mathrand.Int64N(int64(max)))
+}
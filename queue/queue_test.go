@@ -0,0 +1,290 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/brianwu291/go-learn/cache"
+	"github.com/brianwu291/go-learn/db/redis"
+)
+
+type invoice struct {
+	RentalID int64 `json:"rentalId"`
+}
+
+func newTestQueue(t *testing.T, config Config) (*Queue, *redis.Client) {
+	t.Helper()
+
+	server := miniredis.RunT(t)
+	client, err := redis.NewClient(&cache.Config{Host: server.Host(), Port: server.Port()})
+	require.NoError(t, err)
+	t.Cleanup(func() { client.Close() })
+
+	if config.Name == "" {
+		config.Name = "test"
+	}
+	if config.Backoff == nil {
+		config.Backoff = func(int) time.Duration { return 10 * time.Millisecond }
+	}
+	config.PollInterval = 10 * time.Millisecond
+	config.BlockTimeout = 20 * time.Millisecond
+
+	q, err := NewQueue(client, config)
+	require.NoError(t, err)
+	return q, client
+}
+
+// runQueue runs q in the background and returns a func stopping it
+func runQueue(t *testing.T, q *Queue) func() error {
+	t.Helper()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	result := make(chan error, 1)
+	go func() { result <- q.Run(ctx) }()
+
+	var once sync.Once
+	var err error
+	stop := func() error {
+		once.Do(func() {
+			cancel()
+			err = <-result
+		})
+		return err
+	}
+	t.Cleanup(func() { stop() })
+	return stop
+}
+
+func waitFor(t *testing.T, ch <-chan int64, n int) []int64 {
+	t.Helper()
+
+	var got []int64
+	for len(got) < n {
+		select {
+		case v := <-ch:
+			got = append(got, v)
+		case <-time.After(2 * time.Second):
+			t.Fatalf("got %d of %d jobs: %v", len(got), n, got)
+		}
+	}
+	return got
+}
+
+func TestQueue(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Handles typed jobs", func(t *testing.T) {
+		q, _ := newTestQueue(t, Config{Concurrency: 2})
+		handled := make(chan int64, 10)
+		invoices := Register(q, "invoice", func(ctx context.Context, payload invoice) error {
+			job, ok := JobFromContext(ctx)
+			assert.True(t, ok)
+			assert.Equal(t, 1, job.Attempt)
+			handled <- payload.RentalID
+			return nil
+		})
+
+		for id := int64(1); id <= 3; id++ {
+			_, err := invoices.Enqueue(ctx, invoice{RentalID: id})
+			require.NoError(t, err)
+		}
+		stop := runQueue(t, q)
+
+		assert.ElementsMatch(t, []int64{1, 2, 3}, waitFor(t, handled, 3))
+		require.NoError(t, stop())
+
+		stats, err := q.Stats(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, Stats{}, stats)
+	})
+
+	t.Run("Retries failed jobs with backoff", func(t *testing.T) {
+		q, _ := newTestQueue(t, Config{MaxAttempts: 5})
+		attempts := make(chan int64, 10)
+		invoices := Register(q, "invoice", func(ctx context.Context, payload invoice) error {
+			job, _ := JobFromContext(ctx)
+			attempts <- int64(job.Attempt)
+			switch job.Attempt {
+			case 1:
+				return errors.New("billing api down")
+			case 2:
+				panic("nil rental")
+			}
+			return nil
+		})
+
+		_, err := invoices.Enqueue(ctx, invoice{RentalID: 1})
+		require.NoError(t, err)
+		stop := runQueue(t, q)
+
+		assert.Equal(t, []int64{1, 2, 3}, waitFor(t, attempts, 3))
+		require.NoError(t, stop())
+
+		stats, err := q.Stats(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, Stats{}, stats)
+	})
+
+	t.Run("Dead-letters exhausted, permanent and unknown jobs", func(t *testing.T) {
+		q, _ := newTestQueue(t, Config{MaxAttempts: 2})
+		attempts := make(chan int64, 10)
+		failing := Register(q, "failing", func(ctx context.Context, payload invoice) error {
+			attempts <- payload.RentalID
+			if payload.RentalID == 2 {
+				return Permanent(errors.New("rental was cancelled"))
+			}
+			return errors.New("billing api down")
+		})
+
+		_, err := failing.Enqueue(ctx, invoice{RentalID: 1})
+		require.NoError(t, err)
+		_, err = failing.Enqueue(ctx, invoice{RentalID: 2})
+		require.NoError(t, err)
+		_, err = q.enqueue(ctx, "unregistered", invoice{RentalID: 3})
+		require.NoError(t, err)
+		stop := runQueue(t, q)
+
+		assert.ElementsMatch(t, []int64{1, 1, 2}, waitFor(t, attempts, 3))
+		require.Eventually(t, func() bool {
+			stats, err := q.Stats(ctx)
+			return err == nil && stats.Dead == 3
+		}, 2*time.Second, 10*time.Millisecond)
+		require.NoError(t, stop())
+
+		stats, err := q.Stats(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, Stats{Dead: 3}, stats)
+	})
+
+	t.Run("Claims jobs stalled on a dead worker", func(t *testing.T) {
+		q, client := newTestQueue(t, Config{
+			VisibilityTimeout: 50 * time.Millisecond,
+			ClaimInterval:     20 * time.Millisecond,
+		})
+		handled := make(chan int64, 10)
+		invoices := Register(q, "invoice", func(ctx context.Context, payload invoice) error {
+			handled <- payload.RentalID
+			return nil
+		})
+
+		_, err := invoices.Enqueue(ctx, invoice{RentalID: 7})
+		require.NoError(t, err)
+		require.NoError(t, client.XGroupCreate(ctx, q.stream, q.config.Group, "0"))
+		read, err := client.XReadGroup(ctx, q.stream, q.config.Group, "crashed", 1, 0)
+		require.NoError(t, err)
+		require.Len(t, read, 1)
+
+		stop := runQueue(t, q)
+
+		assert.Equal(t, []int64{7}, waitFor(t, handled, 1))
+		require.NoError(t, stop())
+
+		pending, err := client.XPending(ctx, q.stream, q.config.Group, 0, 10)
+		require.NoError(t, err)
+		assert.Empty(t, pending)
+	})
+
+	t.Run("Dead-letters jobs interrupted by every shutdown", func(t *testing.T) {
+		q, _ := newTestQueue(t, Config{
+			MaxAttempts:       2,
+			VisibilityTimeout: 100 * time.Millisecond,
+			ClaimInterval:     20 * time.Millisecond,
+			ShutdownTimeout:   10 * time.Millisecond,
+		})
+		started := make(chan int64, 10)
+		invoices := Register(q, "invoice", func(ctx context.Context, payload invoice) error {
+			started <- payload.RentalID
+			<-ctx.Done()
+			return ctx.Err()
+		})
+
+		_, err := invoices.Enqueue(ctx, invoice{RentalID: 1})
+		require.NoError(t, err)
+		// read once, then claimed once, each run cut off by shutdown
+		for i := 0; i < 2; i++ {
+			stop := runQueue(t, q)
+			waitFor(t, started, 1)
+			assert.ErrorIs(t, stop(), ErrShutdownTimeout)
+		}
+
+		stop := runQueue(t, q)
+		require.Eventually(t, func() bool {
+			stats, err := q.Stats(ctx)
+			return err == nil && stats.Dead == 1
+		}, 2*time.Second, 10*time.Millisecond)
+		require.NoError(t, stop())
+		assert.Empty(t, started, "not run a third time")
+	})
+
+	t.Run("Lets running jobs finish on shutdown", func(t *testing.T) {
+		tests := []struct {
+			name            string
+			shutdownTimeout time.Duration
+			release         bool
+			expectedErr     error
+			expectedQueued  int64
+		}{
+			{name: "finished in time", shutdownTimeout: time.Second, release: true, expectedQueued: 0},
+			{name: "cancelled after timeout", shutdownTimeout: 20 * time.Millisecond, expectedErr: ErrShutdownTimeout, expectedQueued: 1},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				q, _ := newTestQueue(t, Config{ShutdownTimeout: tt.shutdownTimeout})
+				started := make(chan int64, 1)
+				release := make(chan struct{})
+				invoices := Register(q, "invoice", func(ctx context.Context, payload invoice) error {
+					started <- payload.RentalID
+					select {
+					case <-release:
+						return nil
+					case <-ctx.Done():
+						return ctx.Err()
+					}
+				})
+
+				_, err := invoices.Enqueue(ctx, invoice{RentalID: 1})
+				require.NoError(t, err)
+				stop := runQueue(t, q)
+				waitFor(t, started, 1)
+
+				if tt.release {
+					time.AfterFunc(20*time.Millisecond, func() { close(release) })
+				}
+				assert.ErrorIs(t, stop(), tt.expectedErr)
+
+				stats, err := q.Stats(ctx)
+				require.NoError(t, err)
+				assert.Equal(t, tt.expectedQueued, stats.Queued, "a cancelled job stays pending")
+				assert.Zero(t, stats.Dead)
+			})
+		}
+	})
+}
+
+func TestExponentialBackoff(t *testing.T) {
+	backoff := ExponentialBackoff(time.Second, 10*time.Second)
+
+	tests := []struct {
+		attempt int
+		max     time.Duration
+	}{
+		{attempt: 1, max: time.Second},
+		{attempt: 2, max: 2 * time.Second},
+		{attempt: 3, max: 4 * time.Second},
+		{attempt: 10, max: 10 * time.Second},
+	}
+
+	for _, tt := range tests {
+		delay := backoff(tt.attempt)
+		assert.GreaterOrEqual(t, delay, tt.max/2, "attempt %d", tt.attempt)
+		assert.LessOrEqual(t, delay, tt.max, "attempt %d", tt.attempt)
+	}
+}
@@ -0,0 +1,280 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/brianwu291/go-learn/cache"
+)
+
+// Run handles jobs until ctx is done. It then stops taking new jobs and
+// waits up to ShutdownTimeout for running ones. Jobs read but not started
+// stay pending and are claimed by another worker after VisibilityTimeout.
+func (q *Queue) Run(ctx context.Context) error {
+	if err := q.client.XGroupCreate(ctx, q.stream, q.config.Group, "0"); err != nil {
+		return fmt.Errorf("creating consumer group %s: %w", q.config.Group, err)
+	}
+
+	// running jobs outlive ctx so they can finish and ack on shutdown
+	workCtx, cancelWork := context.WithCancel(context.WithoutCancel(ctx))
+	storeCtx := context.WithoutCancel(ctx)
+	defer cancelWork()
+
+	deliveries := make(chan cache.StreamMessage)
+
+	var workers sync.WaitGroup
+	for i := 0; i < q.config.Concurrency; i++ {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			for msg := range deliveries {
+				q.process(storeCtx, workCtx, msg)
+			}
+		}()
+	}
+
+	var producers sync.WaitGroup
+	producers.Add(3)
+	go func() {
+		defer producers.Done()
+		q.read(ctx, deliveries)
+	}()
+	go func() {
+		defer producers.Done()
+		every(ctx, q.config.ClaimInterval, func() { q.claim(ctx, deliveries) })
+	}()
+	go func() {
+		defer producers.Done()
+		every(ctx, q.config.PollInterval, func() { q.promote(ctx) })
+	}()
+
+	producers.Wait()
+	close(deliveries)
+
+	done := make(chan struct{})
+	go func() {
+		workers.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-time.After(q.config.ShutdownTimeout):
+		cancelWork()
+		<-done
+		return ErrShutdownTimeout
+	}
+}
+
+func every(ctx context.Context, interval time.Duration, fn func()) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			fn()
+		}
+	}
+}
+
+// read hands new jobs to the workers, only as many as they can take
+func (q *Queue) read(ctx context.Context, deliveries chan<- cache.StreamMessage) {
+	for ctx.Err() == nil {
+		messages, err := q.client.XReadGroup(ctx, q.stream, q.config.Group, q.config.Consumer,
+			int64(q.config.Concurrency), q.config.BlockTimeout)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			fmt.Printf("failed to read jobs from %s: %+v\n", q.stream, err)
+			sleep(ctx, q.config.PollInterval)
+			continue
+		}
+
+		for _, msg := range messages {
+			select {
+			case deliveries <- msg:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
+}
+
+// claim takes over jobs another worker read but never acked. Jobs
+// delivered MaxAttempts times without an ack are dead-lettered, they
+// probably crash the worker.
+func (q *Queue) claim(ctx context.Context, deliveries chan<- cache.StreamMessage) {
+	pending, err := q.client.XPending(ctx, q.stream, q.config.Group, q.config.VisibilityTimeout, batchSize)
+	if err != nil {
+		fmt.Printf("failed to list stalled jobs in %s: %+v\n", q.stream, err)
+		return
+	}
+
+	exhausted := make(map[string]bool)
+	ids := make([]string, 0, len(pending))
+	for _, entry := range pending {
+		ids = append(ids, entry.ID)
+		if entry.Deliveries >= int64(q.config.MaxAttempts) {
+			exhausted[entry.ID] = true
+		}
+	}
+
+	claimed, err := q.client.XClaim(ctx, q.stream, q.config.Group, q.config.Consumer, q.config.VisibilityTimeout, ids...)
+	if err != nil {
+		fmt.Printf("failed to claim stalled jobs in %s: %+v\n", q.stream, err)
+		return
+	}
+
+	for _, msg := range claimed {
+		if exhausted[msg.ID] {
+			env, _ := decodeEnvelope(msg)
+			q.deadLetter(ctx, msg.ID, env, errors.New("delivered too often without an ack"))
+			continue
+		}
+		select {
+		case deliveries <- msg:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// promote moves retries that are due back to the stream
+func (q *Queue) promote(ctx context.Context) {
+	now := strconv.FormatInt(time.Now().UnixMilli(), 10)
+	_, err := q.client.Eval(ctx, promoteScript, []string{q.delayed, q.stream}, []interface{}{now, batchSize})
+	if err != nil && !cache.IsKeyNotFound(err) {
+		fmt.Printf("failed to promote delayed jobs in %s: %+v\n", q.stream, err)
+	}
+}
+
+// process runs one job with workCtx and records the outcome with ctx, so
+// the result is stored even when shutdown cancels the job
+func (q *Queue) process(ctx, workCtx context.Context, msg cache.StreamMessage) {
+	env, err := decodeEnvelope(msg)
+	if err != nil {
+		q.deadLetter(ctx, msg.ID, env, err)
+		return
+	}
+
+	handler, ok := q.handler(env.Type)
+	if !ok {
+		q.deadLetter(ctx, msg.ID, env, fmt.Errorf("%w: %s", ErrUnknownJobType, env.Type))
+		return
+	}
+
+	job := Job{
+		ID:         env.ID,
+		Type:       env.Type,
+		Attempt:    env.Attempt + 1,
+		EnqueuedAt: env.EnqueuedAt,
+	}
+	jobCtx, cancel := context.WithTimeout(context.WithValue(workCtx, jobContextKey{}, job), q.config.VisibilityTimeout)
+	err = runHandler(jobCtx, handler, env.Payload)
+	cancel()
+
+	if err != nil && workCtx.Err() != nil {
+		// cut off by shutdown, it stays pending for another worker to
+		// claim. Attempt is not raised, but the claim is a delivery, so a
+		// job interrupted MaxAttempts times is dead-lettered like one that
+		// crashes the worker.
+		fmt.Printf("job %s of type %s interrupted by shutdown: %+v\n", env.ID, env.Type, err)
+		return
+	}
+	if err == nil {
+		q.ack(ctx, msg.ID)
+		return
+	}
+
+	env.Attempt += 1
+	env.LastError = err.Error()
+
+	var permanent *permanentError
+	if errors.As(err, &permanent) || env.Attempt >= q.config.MaxAttempts {
+		q.deadLetter(ctx, msg.ID, env, err)
+		return
+	}
+	q.retry(ctx, msg.ID, env)
+}
+
+// runHandler turns a panic into a failed attempt
+func runHandler(ctx context.Context, handler handlerFunc, payload json.RawMessage) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("job panicked: %v", r)
+		}
+	}()
+	return handler(ctx, payload)
+}
+
+// retry schedules the next attempt before acking, a crash in between runs
+// the job twice rather than losing it
+func (q *Queue) retry(ctx context.Context, id string, env envelope) {
+	data, err := json.Marshal(env)
+	if err != nil {
+		q.deadLetter(ctx, id, env, err)
+		return
+	}
+
+	due := time.Now().Add(q.config.Backoff(env.Attempt))
+	member := cache.ZMember{Member: string(data), Score: float64(due.UnixMilli())}
+	if _, err := q.client.ZAdd(ctx, q.delayed, member); err != nil {
+		fmt.Printf("failed to schedule retry of job %s: %+v\n", env.ID, err)
+		return
+	}
+	q.ack(ctx, id)
+}
+
+func (q *Queue) deadLetter(ctx context.Context, id string, env envelope, cause error) {
+	fmt.Printf("job %s of type %s moved to %s: %+v\n", env.ID, env.Type, q.dead, cause)
+
+	extra := map[string]interface{}{
+		"error":    cause.Error(),
+		"failedAt": time.Now().UTC().Format(time.RFC3339),
+		"sourceId": id,
+	}
+	if err := q.add(ctx, q.dead, env, extra); err != nil {
+		fmt.Printf("failed to dead-letter job %s: %+v\n", env.ID, err)
+		return
+	}
+	q.ack(ctx, id)
+}
+
+func (q *Queue) ack(ctx context.Context, id string) {
+	if _, err := q.client.XAck(ctx, q.stream, q.config.Group, id); err != nil {
+		fmt.Printf("failed to ack job entry %s: %+v\n", id, err)
+		return
+	}
+	if _, err := q.client.XDel(ctx, q.stream, id); err != nil {
+		fmt.Printf("failed to delete job entry %s: %+v\n", id, err)
+	}
+}
+
+func decodeEnvelope(msg cache.StreamMessage) (envelope, error) {
+	var env envelope
+	data, ok := msg.Values[jobField]
+	if !ok {
+		return env, fmt.Errorf("%w: entry %s has no job field", cache.ErrInvalidValue, msg.ID)
+	}
+	if err := json.Unmarshal([]byte(data), &env); err != nil {
+		return env, fmt.Errorf("%w: decoding entry %s: %v", cache.ErrInvalidValue, msg.ID, err)
+	}
+	return env, nil
+}
+
+func sleep(ctx context.Context, d time.Duration) {
+	select {
+	case <-ctx.Done():
+	case <-time.After(d):
+	}
+}
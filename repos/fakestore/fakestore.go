@@ -2,6 +2,7 @@ package fakestorerepo
 
 import (
 	"context"
+	"errors"
 	"fmt"
 
 	"github.com/brianwu291/go-learn/constants"
@@ -10,28 +11,41 @@ import (
 )
 
 type (
+	// FakeStoreRepo errors wrap *httpclient.HTTPError for upstream error
+	// statuses, check them with httpclient.IsNotFound and friends
 	FakeStoreRepo struct {
 		client *httpclient.Client
 	}
 )
 
+// ErrProductNotFound is returned for an id the fake store API does not
+// know, it answers those with a 200 and an empty body rather than a 404
+var ErrProductNotFound = errors.New("product not found")
+
 const (
 	categoryPath                = "/products/categories"
 	categoryProductPathTemplate = "/products/category/%s"
 	productPathTemplate         = "/products/%d"
 )
 
-func NewFakeStoreRepo() *FakeStoreRepo {
+// NewFakeStoreRepo talks to the public fake store API, opts are applied
+// after the defaults, e.g. to point it at a test server
+func NewFakeStoreRepo(opts ...httpclient.Option) *FakeStoreRepo {
 	return &FakeStoreRepo{
 		client: httpclient.NewClient(
-			httpclient.WithBaseURL(constants.FakeStoreBaseURL),
+			append([]httpclient.Option{httpclient.WithBaseURL(constants.FakeStoreBaseURL)}, opts...)...,
 		),
 	}
 }
 
+// CircuitStates reports the breaker state per host, empty without
+// httpclient.WithCircuitBreaker
+func (f *FakeStoreRepo) CircuitStates() map[string]string {
+	return f.client.CircuitStates()
+}
+
 func (f *FakeStoreRepo) GetCategories(ctx context.Context) ([]types.Category, error) {
-	var categories []types.Category
-	err := f.client.Get(ctx, categoryPath, &categories)
+	categories, err := httpclient.GetJSON[[]types.Category](ctx, f.client, categoryPath)
 	if err != nil {
 		return nil, fmt.Errorf("get all categories failed: %w", err)
 	}
@@ -39,9 +53,8 @@ func (f *FakeStoreRepo) GetCategories(ctx context.Context) ([]types.Category, er
 }
 
 func (f *FakeStoreRepo) GetProductsByCategory(ctx context.Context, category types.Category) ([]types.Product, error) {
-	var products []types.Product
 	categoryProductPath := fmt.Sprintf(categoryProductPathTemplate, category)
-	err := f.client.Get(ctx, categoryProductPath, &products)
+	products, err := httpclient.GetJSON[[]types.Product](ctx, f.client, categoryProductPath)
 	if err != nil {
 		return nil, fmt.Errorf("get category: %s products failed: %w", category, err)
 	}
@@ -49,11 +62,13 @@ func (f *FakeStoreRepo) GetProductsByCategory(ctx context.Context, category type
 }
 
 func (f *FakeStoreRepo) GetProduct(ctx context.Context, id int64) (*types.Product, error) {
-	var product types.Product
 	productPath := fmt.Sprintf(productPathTemplate, id)
-	err := f.client.Get(ctx, productPath, &product)
+	product, err := httpclient.GetJSON[types.Product](ctx, f.client, productPath, httpclient.AllowEmptyBody())
 	if err != nil {
 		return nil, fmt.Errorf("get product failed %d: %w", id, err)
 	}
+	if product.ID == 0 {
+		return nil, fmt.Errorf("%w: %d", ErrProductNotFound, id)
+	}
 	return &product, nil
 }
@@ -0,0 +1,110 @@
+package fakestorerepo
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/brianwu291/go-learn/cache"
+	"github.com/brianwu291/go-learn/cache/memory"
+	"github.com/brianwu291/go-learn/httpclient"
+	"github.com/brianwu291/go-learn/httpclient/httpclienttest"
+	"github.com/brianwu291/go-learn/types"
+)
+
+// The cassettes in testdata/cassettes are replayed offline, run with
+// HTTPCLIENT_RECORD=1 to record them again against the fake store API.
+// None of them is a recording yet, each test says what is synthetic in the
+// cassette it replays, drop that note once the cassette is recorded.
+
+func newCassetteRepo(t *testing.T, cassette string, config httpclienttest.RecorderConfig, opts ...httpclient.Option) *FakeStoreRepo {
+	t.Helper()
+
+	recorder := httpclienttest.Open(t, cassette, config)
+	return NewFakeStoreRepo(append([]httpclient.Option{
+		httpclient.WithHTTPClient(recorder),
+		httpclient.WithInterceptors(httpclient.RequestID("")),
+	}, opts...)...)
+}
+
+// synthetic: categories.json is written by hand, the body lists the four
+// categories the API serves and its request ID and ETag are made up
+func TestFakeStoreRepo_GetCategories(t *testing.T) {
+	repo := newCassetteRepo(t, "categories", httpclienttest.RecorderConfig{})
+
+	categories, err := repo.GetCategories(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []types.Category{"electronics", "jewelery", "men's clothing", "women's clothing"}, categories)
+}
+
+// synthetic: category_products.json is written by hand and holds two of
+// the four jewelery products with shortened descriptions, so the test only
+// checks what holds for any page of the category
+func TestFakeStoreRepo_GetProductsByCategory(t *testing.T) {
+	repo := newCassetteRepo(t, "category_products", httpclienttest.RecorderConfig{})
+
+	products, err := repo.GetProductsByCategory(context.Background(), "jewelery")
+	require.NoError(t, err)
+	require.NotEmpty(t, products)
+	for _, product := range products {
+		assert.Equal(t, types.Category("jewelery"), product.Category)
+		assert.Positive(t, product.Price)
+	}
+	assert.Equal(t, int64(5), products[0].ID)
+}
+
+func TestFakeStoreRepo_GetProduct(t *testing.T) {
+	ctx := context.Background()
+
+	// synthetic: the first interaction of product.json is written by hand,
+	// without the X-Request-Id the client sends
+	t.Run("Existing product", func(t *testing.T) {
+		repo := newCassetteRepo(t, "product", httpclienttest.RecorderConfig{})
+
+		product, err := repo.GetProduct(ctx, 1)
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), product.ID)
+		assert.Equal(t, "Fjallraven - Foldsack No. 1 Backpack, Fits 15 Laptops", product.Title)
+	})
+
+	// synthetic: written by hand after the API's answer to unknown ids, a
+	// 200 with an empty body, record it again with HTTPCLIENT_RECORD=1
+	t.Run("Unknown product", func(t *testing.T) {
+		repo := newCassetteRepo(t, "product_unknown", httpclienttest.RecorderConfig{})
+
+		product, err := repo.GetProduct(ctx, 9999)
+		assert.ErrorIs(t, err, ErrProductNotFound)
+		assert.Nil(t, product)
+	})
+
+	// synthetic: the ETag and the 304 in product.json are made up, the test
+	// checks the client revalidates, not what the API answers
+	t.Run("Revalidates a cached product", func(t *testing.T) {
+		var conditions []string
+		recordConditions := func(next httpclient.Executor) httpclient.Executor {
+			return func(req *http.Request) (*http.Response, error) {
+				conditions = append(conditions, req.Header.Get("If-None-Match"))
+				return next(req)
+			}
+		}
+		// the 304 only answers a request carrying the recorded ETag
+		repo := newCassetteRepo(t, "product",
+			httpclienttest.RecorderConfig{Matcher: httpclienttest.MatchHeaders(httpclienttest.MatchMethodAndURL, "If-None-Match")},
+			httpclient.WithResponseCache(memory.NewClient(), cache.NewKeyBuilder("test", "test"), httpclient.ResponseCacheConfig{}),
+			httpclient.WithInterceptors(recordConditions),
+		)
+
+		first, err := repo.GetProduct(ctx, 1)
+		require.NoError(t, err)
+		second, err := repo.GetProduct(ctx, 1)
+		require.NoError(t, err)
+		assert.Equal(t, first, second)
+
+		require.Len(t, conditions, 2)
+		assert.Empty(t, conditions[0])
+		assert.NotEmpty(t, conditions[1], "the second request revalidates")
+	})
+}
@@ -0,0 +1,190 @@
+package cacheadminservice
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/brianwu291/go-learn/cache"
+	types "github.com/brianwu291/go-learn/types"
+)
+
+type (
+	CacheAdminService interface {
+		ListFamilies(ctx context.Context) ([]types.CacheFamily, error)
+		GetEntry(ctx context.Context, key string) (types.CacheEntry, error)
+		// PurgeKey deletes one key of a registered family
+		PurgeKey(ctx context.Context, key string) (types.CachePurgeResult, error)
+		// PurgeFamily deletes every key of the family, the current version only
+		PurgeFamily(ctx context.Context, name string) (types.CachePurgeResult, error)
+		WarmUp(ctx context.Context) []cache.WarmupResult
+	}
+
+	cacheAdminService struct {
+		cacheClient cache.Client
+		cacheKeys   *cache.KeyBuilder
+		warmer      *cache.Warmer
+	}
+)
+
+// purgeBatchSize bounds the DELs sent in one pipeline
+const purgeBatchSize = 500
+
+const (
+	typeString    = "string"
+	typeHash      = "hash"
+	typeSet       = "set"
+	typeSortedSet = "zset"
+)
+
+var (
+	// ErrUnknownFamily is returned for a family name that is not registered
+	ErrUnknownFamily = errors.New("unknown cache key family")
+	// ErrForeignKey is returned for keys outside every registered family,
+	// the admin API never touches keys it does not own
+	ErrForeignKey = errors.New("key belongs to no registered cache family")
+	// ErrProtectedFamily is returned when purging a protected family, its
+	// keys are the only copy of the data
+	ErrProtectedFamily = errors.New("cache family is protected from purges")
+)
+
+func NewCacheAdminService(cacheClient cache.Client, cacheKeys *cache.KeyBuilder, warmer *cache.Warmer) *cacheAdminService {
+	return &cacheAdminService{
+		cacheClient: cacheClient,
+		cacheKeys:   cacheKeys,
+		warmer:      warmer,
+	}
+}
+
+func (s *cacheAdminService) ListFamilies(ctx context.Context) ([]types.CacheFamily, error) {
+	families := s.cacheKeys.Families()
+	result := make([]types.CacheFamily, 0, len(families))
+	for _, family := range families {
+		keys, err := cache.ScanKeys(ctx, s.cacheClient, family.Pattern())
+		if err != nil {
+			return nil, fmt.Errorf("scanning %s: %w", family.Name, err)
+		}
+
+		result = append(result, types.CacheFamily{
+			Name:        family.Name,
+			Version:     family.Version,
+			TTLSeconds:  int64(family.TTL / time.Second),
+			Description: family.Description,
+			Pattern:     family.Pattern(),
+			Keys:        int64(len(keys)),
+			Protected:   family.Protected,
+		})
+	}
+	return result, nil
+}
+
+func (s *cacheAdminService) GetEntry(ctx context.Context, key string) (types.CacheEntry, error) {
+	family, ok := s.cacheKeys.FamilyOf(key)
+	if !ok {
+		return types.CacheEntry{}, fmt.Errorf("%w: %s", ErrForeignKey, key)
+	}
+
+	kind, size, err := s.sizeOf(ctx, key)
+	if err != nil {
+		return types.CacheEntry{}, err
+	}
+	ttl, err := s.cacheClient.TTL(ctx, key)
+	if err != nil {
+		return types.CacheEntry{}, err
+	}
+
+	return types.CacheEntry{
+		Key:        key,
+		Family:     family.Name,
+		Type:       kind,
+		TTLSeconds: toSeconds(ttl),
+		Size:       size,
+	}, nil
+}
+
+// sizeOf finds the type of key by trying the reads of each type in turn,
+// families such as feature flags and tag sets are not plain strings
+func (s *cacheAdminService) sizeOf(ctx context.Context, key string) (string, int64, error) {
+	value, err := s.cacheClient.Get(ctx, key)
+	if !cache.IsWrongType(err) {
+		return typeString, int64(len(value)), err
+	}
+
+	fields, err := s.cacheClient.HGetAll(ctx, key)
+	if !cache.IsWrongType(err) {
+		return typeHash, int64(len(fields)), err
+	}
+
+	members, err := s.cacheClient.SCard(ctx, key)
+	if !cache.IsWrongType(err) {
+		return typeSet, members, err
+	}
+
+	members, err = s.cacheClient.ZCard(ctx, key)
+	return typeSortedSet, members, err
+}
+
+func (s *cacheAdminService) PurgeKey(ctx context.Context, key string) (types.CachePurgeResult, error) {
+	family, ok := s.cacheKeys.FamilyOf(key)
+	if !ok {
+		return types.CachePurgeResult{}, fmt.Errorf("%w: %s", ErrForeignKey, key)
+	}
+	if family.Protected {
+		return types.CachePurgeResult{}, fmt.Errorf("%w: %s", ErrProtectedFamily, family.Name)
+	}
+
+	deleted, err := s.cacheClient.Del(ctx, key)
+	if err != nil {
+		return types.CachePurgeResult{}, err
+	}
+	fmt.Printf("purged cache key %s\n", key)
+	return types.CachePurgeResult{Deleted: deleted}, nil
+}
+
+func (s *cacheAdminService) PurgeFamily(ctx context.Context, name string) (types.CachePurgeResult, error) {
+	family, ok := s.cacheKeys.Family(name)
+	if !ok {
+		return types.CachePurgeResult{}, fmt.Errorf("%w: %s", ErrUnknownFamily, name)
+	}
+	if family.Protected {
+		return types.CachePurgeResult{}, fmt.Errorf("%w: %s", ErrProtectedFamily, name)
+	}
+
+	keys, err := cache.ScanKeys(ctx, s.cacheClient, family.Pattern())
+	if err != nil {
+		return types.CachePurgeResult{}, err
+	}
+
+	// one DEL per key, a multi key DEL fails with CROSSSLOT on a cluster
+	var result types.CachePurgeResult
+	for start := 0; start < len(keys); start += purgeBatchSize {
+		pipe := s.cacheClient.Pipeline()
+		cmds := make([]cache.PipelineCmd, 0, purgeBatchSize)
+		for _, key := range keys[start:min(start+purgeBatchSize, len(keys))] {
+			cmds = append(cmds, pipe.Del(ctx, key))
+		}
+		err := pipe.Exec(ctx)
+		for _, cmd := range cmds {
+			result.Deleted += cmd.Val()
+		}
+		if err != nil {
+			return result, err
+		}
+	}
+	fmt.Printf("purged %d keys of cache family %s\n", result.Deleted, name)
+	return result, nil
+}
+
+func (s *cacheAdminService) WarmUp(ctx context.Context) []cache.WarmupResult {
+	return s.warmer.Run(ctx)
+}
+
+// toSeconds keeps the redis markers for a key without expiry (-1) and a
+// missing key (-2)
+func toSeconds(ttl time.Duration) int64 {
+	if ttl < 0 {
+		return int64(ttl)
+	}
+	return int64(ttl / time.Second)
+}
@@ -0,0 +1,166 @@
+package cacheadminservice
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/brianwu291/go-learn/cache"
+	"github.com/brianwu291/go-learn/cache/memory"
+	"github.com/brianwu291/go-learn/types"
+)
+
+func newTestService(t *testing.T) (*cacheAdminService, cache.KeyFamily, cache.KeyFamily) {
+	t.Helper()
+	ctx := context.Background()
+
+	client := memory.NewClient()
+	keys := cache.NewKeyBuilder("test", "dev")
+	categories := keys.Register(cache.KeyFamily{Name: "categories", Version: 1, TTL: time.Hour, Description: "all categories"})
+	products := keys.Register(cache.KeyFamily{Name: "product", Version: 2, TTL: time.Minute})
+	flags := keys.Register(cache.KeyFamily{Name: "flags", Version: 1, Protected: true})
+	tags := keys.Register(cache.KeyFamily{Name: "tags", Version: 1})
+
+	require.NoError(t, client.Set(ctx, categories.Key("all"), `["jewelery"]`, time.Hour))
+	for _, id := range []string{"1", "2", "3"} {
+		require.NoError(t, client.Set(ctx, products.Key(id), `{}`, time.Minute))
+	}
+	require.NoError(t, client.Set(ctx, "test:dev:product:v1:1", `{}`, 0))
+	require.NoError(t, client.Set(ctx, "unrelated", "value", 0))
+	_, err := client.HSet(ctx, flags.Key("all"), map[string]interface{}{"checkout": "on", "search": "off"})
+	require.NoError(t, err)
+	_, err = client.SAdd(ctx, tags.Key("set"), "a", "b", "c")
+	require.NoError(t, err)
+	_, err = client.ZAdd(ctx, tags.Key("zset"), cache.ZMember{Member: "a", Score: 1})
+	require.NoError(t, err)
+
+	return NewCacheAdminService(client, keys, cache.NewWarmer()), categories, products
+}
+
+func TestCacheAdminService_ListFamilies(t *testing.T) {
+	service, _, _ := newTestService(t)
+
+	families, err := service.ListFamilies(context.Background())
+
+	require.NoError(t, err)
+	assert.Equal(t, []types.CacheFamily{
+		{Name: "categories", Version: 1, TTLSeconds: 3600, Description: "all categories", Pattern: "test:dev:categories:v1:*", Keys: 1},
+		{Name: "flags", Version: 1, Pattern: "test:dev:flags:v1:*", Keys: 1, Protected: true},
+		{Name: "product", Version: 2, TTLSeconds: 60, Pattern: "test:dev:product:v2:*", Keys: 3},
+		{Name: "tags", Version: 1, Pattern: "test:dev:tags:v1:*", Keys: 2},
+	}, families)
+}
+
+func TestCacheAdminService_GetEntry(t *testing.T) {
+	service, categories, _ := newTestService(t)
+
+	tests := []struct {
+		name          string
+		key           string
+		expectedEntry types.CacheEntry
+		expectedErr   error
+	}{
+		{
+			name:          "Reports TTL and size",
+			key:           categories.Key("all"),
+			expectedEntry: types.CacheEntry{Key: categories.Key("all"), Family: "categories", Type: "string", TTLSeconds: 3600, Size: 12},
+		},
+		{
+			name:          "Counts the fields of a hash",
+			key:           "test:dev:flags:v1:all",
+			expectedEntry: types.CacheEntry{Key: "test:dev:flags:v1:all", Family: "flags", Type: "hash", TTLSeconds: -1, Size: 2},
+		},
+		{
+			name:          "Counts the members of a set",
+			key:           "test:dev:tags:v1:set",
+			expectedEntry: types.CacheEntry{Key: "test:dev:tags:v1:set", Family: "tags", Type: "set", TTLSeconds: -1, Size: 3},
+		},
+		{
+			name:          "Counts the members of a sorted set",
+			key:           "test:dev:tags:v1:zset",
+			expectedEntry: types.CacheEntry{Key: "test:dev:tags:v1:zset", Family: "tags", Type: "zset", TTLSeconds: -1, Size: 1},
+		},
+		{
+			name:        "Misses a key that is not cached",
+			key:         categories.Key("none"),
+			expectedErr: cache.ErrKeyNotFound,
+		},
+		{
+			name:        "Refuses keys outside the registered families",
+			key:         "unrelated",
+			expectedErr: ErrForeignKey,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entry, err := service.GetEntry(context.Background(), tt.key)
+			if tt.expectedErr != nil {
+				assert.ErrorIs(t, err, tt.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expectedEntry, entry)
+		})
+	}
+}
+
+func TestCacheAdminService_Purge(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Purges one key", func(t *testing.T) {
+		service, _, products := newTestService(t)
+
+		result, err := service.PurgeKey(ctx, products.Key("1"))
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), result.Deleted)
+
+		_, err = service.PurgeKey(ctx, "unrelated")
+		assert.ErrorIs(t, err, ErrForeignKey)
+	})
+
+	t.Run("Purges the current version of a family", func(t *testing.T) {
+		service, _, products := newTestService(t)
+
+		result, err := service.PurgeFamily(ctx, "product")
+		require.NoError(t, err)
+		assert.Equal(t, int64(3), result.Deleted)
+
+		left, err := cache.ScanKeys(ctx, service.cacheClient, "test:dev:product:*")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"test:dev:product:v1:1"}, left)
+
+		_, err = service.GetEntry(ctx, products.Key("1"))
+		assert.ErrorIs(t, err, cache.ErrKeyNotFound)
+	})
+
+	t.Run("Purges keys of every type", func(t *testing.T) {
+		service, _, _ := newTestService(t)
+
+		result, err := service.PurgeFamily(ctx, "tags")
+		require.NoError(t, err)
+		assert.Equal(t, int64(2), result.Deleted)
+	})
+
+	t.Run("Leaves protected families alone", func(t *testing.T) {
+		service, _, _ := newTestService(t)
+
+		_, err := service.PurgeFamily(ctx, "flags")
+		assert.ErrorIs(t, err, ErrProtectedFamily)
+		_, err = service.PurgeKey(ctx, "test:dev:flags:v1:all")
+		assert.ErrorIs(t, err, ErrProtectedFamily)
+
+		_, err = service.GetEntry(ctx, "test:dev:flags:v1:all")
+		assert.NoError(t, err)
+	})
+
+	t.Run("Rejects an unknown family", func(t *testing.T) {
+		service, _, _ := newTestService(t)
+
+		_, err := service.PurgeFamily(ctx, "orders")
+		assert.ErrorIs(t, err, ErrUnknownFamily)
+	})
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"strconv"
 	"time"
 
 	"github.com/brianwu291/go-learn/cache"
@@ -16,29 +17,77 @@ type (
 		GetCategories(ctx context.Context, skipCache bool) ([]types.Category, error)
 		GetProductsByCategory(ctx context.Context, category types.Category) ([]types.Product, error)
 		GetProduct(ctx context.Context, id int64) (*types.Product, error)
+		// InvalidateCategory drops the cached product list and every cached
+		// product of the category
+		InvalidateCategory(ctx context.Context, category types.Category) error
 	}
 
 	fakeStoreService struct {
 		cacheClient cache.Client
+		taggedCache *cache.TaggedClient
 		repo        *fakestorerepo.FakeStoreRepo
+
+		cacheKeys            *cache.KeyBuilder
+		categoriesKeys       cache.KeyFamily
+		categoryProductsKeys cache.KeyFamily
+		productKeys          cache.KeyFamily
 	}
 )
 
-func NewFakeStoreService(cacheClient cache.Client, repo *fakestorerepo.FakeStoreRepo) *fakeStoreService {
+// Bump a version whenever the cached type changes shape, e.g. a new
+// field on types.Product, so old JSON is never decoded into the new struct
+var (
+	categoriesCacheFamily = cache.KeyFamily{
+		Name:        "fakeStore:categories",
+		Version:     1,
+		TTL:         time.Hour,
+		Description: "all product categories",
+	}
+	categoryProductsCacheFamily = cache.KeyFamily{
+		Name:        "fakeStore:categoryProducts",
+		Version:     1,
+		TTL:         time.Hour,
+		Description: "product list of one category",
+	}
+	productCacheFamily = cache.KeyFamily{
+		Name:        "fakeStore:product",
+		Version:     1,
+		TTL:         time.Hour,
+		Description: "single product by id",
+	}
+)
+
+func NewFakeStoreService(cacheClient cache.Client, repo *fakestorerepo.FakeStoreRepo, cacheKeys *cache.KeyBuilder) *fakeStoreService {
 	return &fakeStoreService{
 		cacheClient: cacheClient,
+		taggedCache: cache.NewTaggedClient(cacheClient),
 		repo:        repo,
+
+		cacheKeys:            cacheKeys,
+		categoriesKeys:       cacheKeys.Register(categoriesCacheFamily),
+		categoryProductsKeys: cacheKeys.Register(categoryProductsCacheFamily),
+		productKeys:          cacheKeys.Register(productCacheFamily),
 	}
 }
 
-func (s *fakeStoreService) GetCategories(ctx context.Context, skipCache bool) ([]types.Category, error) {
-	if skipCache {
-		return s.repo.GetCategories(ctx)
-	}
+// RegisterCacheLoaders adds the entries worth filling before the first
+// request to the warmer
+func (s *fakeStoreService) RegisterCacheLoaders(warmer *cache.Warmer) {
+	warmer.Register(categoriesCacheFamily.Name, func(ctx context.Context) error {
+		_, err := s.GetCategories(ctx, false)
+		return err
+	})
+}
 
-	const categoriesCacheKey = "fakeStore:categories:all"
+func (s *fakeStoreService) GetCategories(ctx context.Context, skipCache bool) ([]types.Category, error) {
+	categoriesCacheKey := s.categoriesKeys.Key("all")
 
-	if categories, err := s.getCachedCategories(ctx, categoriesCacheKey); err == nil {
+	if skipCache {
+		// drop the stale entry so it is refilled from the fresh result below
+		if _, err := s.cacheClient.Del(ctx, categoriesCacheKey); err != nil {
+			fmt.Printf("failed to invalidate cached categories: %+v", err)
+		}
+	} else if categories, err := s.getCachedCategories(ctx, categoriesCacheKey); err == nil {
 		return categories, nil
 	}
 
@@ -55,6 +104,7 @@ func (s *fakeStoreService) GetCategories(ctx context.Context, skipCache bool) ([
 func (s *fakeStoreService) getCachedCategories(ctx context.Context, key string) ([]types.Category, error) {
 	cachedData, err := s.cacheClient.Get(ctx, key)
 	if err != nil {
+		logCacheReadError(key, err)
 		return nil, err
 	}
 
@@ -74,15 +124,91 @@ func (s *fakeStoreService) cacheCategories(ctx context.Context, key string, cate
 		return
 	}
 
-	if err := s.cacheClient.Set(ctx, key, categoriesJson, time.Hour); err != nil {
+	if err := s.cacheClient.Set(ctx, key, categoriesJson, s.categoriesKeys.TTL); err != nil {
 		fmt.Printf("failed to cache categories: %+v", err)
 	}
 }
 
 func (s *fakeStoreService) GetProductsByCategory(ctx context.Context, category types.Category) ([]types.Product, error) {
-	return s.repo.GetProductsByCategory(ctx, category)
+	key := s.categoryProductsKeys.Key(string(category))
+
+	var products []types.Product
+	if err := s.getCachedJSON(ctx, key, &products); err == nil {
+		return products, nil
+	}
+
+	products, err := s.repo.GetProductsByCategory(ctx, category)
+	if err != nil {
+		return nil, err
+	}
+
+	s.cacheJSON(ctx, key, products, s.categoryProductsKeys.TTL, s.categoryTag(category))
+
+	return products, nil
 }
 
 func (s *fakeStoreService) GetProduct(ctx context.Context, id int64) (*types.Product, error) {
-	return s.repo.GetProduct(ctx, id)
+	key := s.productKeys.Key(strconv.FormatInt(id, 10))
+
+	var product types.Product
+	if err := s.getCachedJSON(ctx, key, &product); err == nil {
+		return &product, nil
+	}
+
+	fetched, err := s.repo.GetProduct(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	s.cacheJSON(ctx, key, fetched, s.productKeys.TTL, s.categoryTag(fetched.Category))
+
+	return fetched, nil
+}
+
+func (s *fakeStoreService) InvalidateCategory(ctx context.Context, category types.Category) error {
+	_, err := s.taggedCache.InvalidateTag(ctx, s.categoryTag(category))
+	return err
+}
+
+func (s *fakeStoreService) getCachedJSON(ctx context.Context, key string, v interface{}) error {
+	cachedData, err := s.cacheClient.Get(ctx, key)
+	if err != nil {
+		logCacheReadError(key, err)
+		return err
+	}
+
+	if err := json.Unmarshal([]byte(cachedData), v); err != nil {
+		fmt.Printf("failed to unmarshal cached %s: %+v", key, err)
+		return err
+	}
+
+	return nil
+}
+
+func (s *fakeStoreService) cacheJSON(ctx context.Context, key string, v interface{}, expiration time.Duration, tags ...string) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		fmt.Printf("failed to marshal %s for cache: %+v", key, err)
+		return
+	}
+
+	if err := s.taggedCache.SetWithTags(ctx, key, data, expiration, tags...); err != nil {
+		fmt.Printf("failed to cache %s: %+v", key, err)
+	}
+}
+
+// logCacheReadError stays quiet on a plain miss, the caller falls back to
+// the repo either way
+func logCacheReadError(key string, err error) {
+	switch {
+	case cache.IsKeyNotFound(err):
+	case cache.IsConnectionError(err):
+		fmt.Printf("cache unavailable while reading %s: %+v\n", key, err)
+	default:
+		fmt.Printf("failed to read %s from cache: %+v\n", key, err)
+	}
+}
+
+func (s *fakeStoreService) categoryTag(category types.Category) string {
+	return s.cacheKeys.Tag("fakeStore", "category", string(category))
 }
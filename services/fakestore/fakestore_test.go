@@ -0,0 +1,137 @@
+package fakestoreservice
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/brianwu291/go-learn/cache"
+	"github.com/brianwu291/go-learn/cache/cachetest"
+	"github.com/brianwu291/go-learn/cache/memory"
+	"github.com/brianwu291/go-learn/httpclient"
+	fakestorerepo "github.com/brianwu291/go-learn/repos/fakestore"
+	"github.com/brianwu291/go-learn/types"
+)
+
+var testCategories = []types.Category{"electronics", "jewelery"}
+
+// newTestRepo serves the categories endpoint and counts the requests
+func newTestRepo(t *testing.T, status int) (*fakestorerepo.FakeStoreRepo, *int64) {
+	t.Helper()
+
+	var requests int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&requests, 1)
+		if status != http.StatusOK {
+			w.WriteHeader(status)
+			return
+		}
+		json.NewEncoder(w).Encode(testCategories)
+	}))
+	t.Cleanup(server.Close)
+
+	return fakestorerepo.NewFakeStoreRepo(httpclient.WithBaseURL(server.URL)), &requests
+}
+
+func TestFakeStoreService_GetCategories(t *testing.T) {
+	ctx := context.Background()
+	unavailable := cache.NewConnectionError(cachetest.ErrInjected)
+
+	tests := []struct {
+		name             string
+		faults           cachetest.Faults
+		cached           string
+		repoStatus       int
+		expectedRequests int64
+		expectedCached   bool
+		expectErr        bool
+	}{
+		{
+			name:             "Serves a cache hit without the repo",
+			cached:           `["electronics","jewelery"]`,
+			repoStatus:       http.StatusOK,
+			expectedRequests: 0,
+			expectedCached:   true,
+		},
+		{
+			name:             "Falls back to the repo on a miss and caches the result",
+			repoStatus:       http.StatusOK,
+			expectedRequests: 1,
+			expectedCached:   true,
+		},
+		{
+			name:             "Falls back to the repo on a corrupt entry",
+			cached:           `not json`,
+			repoStatus:       http.StatusOK,
+			expectedRequests: 1,
+			expectedCached:   true,
+		},
+		{
+			name: "Falls back to the repo when the cache is down",
+			faults: cachetest.Faults{
+				Operations: map[string]error{"get": unavailable, "set": unavailable},
+			},
+			repoStatus:       http.StatusOK,
+			expectedRequests: 1,
+			expectedCached:   false,
+		},
+		{
+			name: "Falls back to the repo when the cache is slow",
+			faults: cachetest.Faults{
+				Operations: map[string]error{"get": cache.NewTimeoutError(nil)},
+			},
+			cached:           `["stale"]`,
+			repoStatus:       http.StatusOK,
+			expectedRequests: 1,
+			expectedCached:   true,
+		},
+		{
+			name: "Fails when both the cache and the repo fail",
+			faults: cachetest.Faults{
+				Operations: map[string]error{"get": unavailable, "set": unavailable},
+			},
+			repoStatus:       http.StatusServiceUnavailable,
+			expectedRequests: 1,
+			expectErr:        true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := memory.NewClient()
+			keys := cache.NewKeyBuilder("go-learn", "test")
+			repo, requests := newTestRepo(t, tt.repoStatus)
+			service := NewFakeStoreService(cachetest.NewFaultyClient(store, tt.faults), repo, keys)
+
+			key := service.categoriesKeys.Key("all")
+			if tt.cached != "" {
+				require.NoError(t, store.Set(ctx, key, tt.cached, time.Minute))
+			}
+
+			categories, err := service.GetCategories(ctx, false)
+
+			assert.Equal(t, tt.expectedRequests, atomic.LoadInt64(requests))
+			if tt.expectErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, testCategories, categories)
+
+			cached, err := store.Get(ctx, key)
+			if !tt.expectedCached {
+				assert.True(t, cache.IsKeyNotFound(err))
+				return
+			}
+			require.NoError(t, err)
+			assert.JSONEq(t, `["electronics","jewelery"]`, cached)
+		})
+	}
+}
@@ -5,6 +5,14 @@ type (
 		Message string `json:"message"`
 	}
 
+	UnauthorizedResponse struct {
+		Message string `json:"message"`
+	}
+
+	NotFoundResponse struct {
+		Message string `json:"message"`
+	}
+
 	InternalServerErrorResponse struct {
 		Message string `json:"message"`
 	}
@@ -35,4 +43,32 @@ type (
 		Rate  float64 `json:"rate"`
 		Count int64   `json:"count"`
 	}
+
+	// CacheFamily describes a registered cache key family and how many keys
+	// it holds right now
+	CacheFamily struct {
+		Name        string `json:"name"`
+		Version     int    `json:"version"`
+		TTLSeconds  int64  `json:"ttlSeconds"`
+		Description string `json:"description"`
+		Pattern     string `json:"pattern"`
+		Keys        int64  `json:"keys"`
+		Protected   bool   `json:"protected"`
+	}
+
+	// CacheEntry describes one cached key. TTLSeconds is -1 for a key
+	// without expiry, Size is the stored value length in bytes for a
+	// string and the number of fields or members for a hash, set or
+	// sorted set.
+	CacheEntry struct {
+		Key        string `json:"key"`
+		Family     string `json:"family"`
+		Type       string `json:"type"`
+		TTLSeconds int64  `json:"ttlSeconds"`
+		Size       int64  `json:"size"`
+	}
+
+	CachePurgeResult struct {
+		Deleted int64 `json:"deleted"`
+	}
 )
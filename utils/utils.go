@@ -3,6 +3,8 @@ package utils
 import (
 	"os"
 	"strconv"
+	"strings"
+	"time"
 )
 
 func GetEnv(key, fallback string) string {
@@ -20,3 +22,39 @@ func GetEnvAsInt(key string, fallback int) int {
 	}
 	return fallback
 }
+
+func GetEnvAsBool(key string, fallback bool) bool {
+	strValue := GetEnv(key, "")
+	if value, err := strconv.ParseBool(strValue); err == nil {
+		return value
+	}
+	return fallback
+}
+
+// GetEnvAsDuration parses values like "500ms" or "5s"
+func GetEnvAsDuration(key string, fallback time.Duration) time.Duration {
+	strValue := GetEnv(key, "")
+	if value, err := time.ParseDuration(strValue); err == nil {
+		return value
+	}
+	return fallback
+}
+
+// GetEnvAsSlice splits the value by sep and drops empty items
+func GetEnvAsSlice(key, sep string, fallback []string) []string {
+	strValue := GetEnv(key, "")
+	if strValue == "" {
+		return fallback
+	}
+
+	result := make([]string, 0)
+	for _, item := range strings.Split(strValue, sep) {
+		if trimmed := strings.TrimSpace(item); trimmed != "" {
+			result = append(result, trimmed)
+		}
+	}
+	if len(result) == 0 {
+		return fallback
+	}
+	return result
+}